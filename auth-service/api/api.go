@@ -1,19 +1,14 @@
 package api
 
 import (
-	"database/sql"
-	"encoding/json"
-	"errors"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
-	"github.com/sendgrid/sendgrid-go"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -23,78 +18,69 @@ const (
 )
 
 // RegisterRoutes initializes the api endpoints and maps the requests to specific functions
-func RegisterRoutes(router *mux.Router) error {
+// The handlers keep their accounts in store and send their emails through m
+func RegisterRoutes(router *mux.Router, store UserStore, m Mailer) error {
+	users = store
+	passkeys = store
+	mailer = m
+
 	router.HandleFunc("/api/auth/signup", signup).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/auth/signin", signin).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/auth/logout", logout).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/auth/verify", verify).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/auth/sendreset", sendReset).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/auth/resetpw", resetPassword).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/api/auth/csrf", issueCSRFToken).Methods(http.MethodGet, http.MethodOptions)
+	registerPasskeyRoutes(router)
 
-	// Load sendgrid credentials
-	err := godotenv.Load()
-	if err != nil {
-		return err
-	}
-
-	sendgridKey = os.Getenv("SENDGRID_KEY")
-	sendgridClient = sendgrid.NewSendClient(sendgridKey)
 	return nil
 }
 
 func signup(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "content-type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-
 	//Obtain the credentials from the request body
 	credential := Credentials{}
-	err := json.NewDecoder(r.Body).Decode(&credential)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !problem.DecodeJSON(w, r, &credential) {
+		return
 	}
 
-	if credential.Username == "" || credential.Password == "" || credential.Email == "" {
-		w.WriteHeader(400)
+	fields := validate.Struct(credential)
+	fields.Require("username", credential.Username)
+	fields.Require("email", credential.Email)
+	fields.Require("password", credential.Password)
+	validate.Var(&fields, "username", credential.Username, newUsernameRule)
+	validate.Var(&fields, "password", credential.Password, newPasswordRule)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
 		return
 	}
 
-
 	//Check if the username already exists
-	var exists bool
-	err = DB.QueryRow("SELECT EXISTS (SELECT username FROM users WHERE username=?)", credential.Username).Scan(&exists)
-	
+	exists, err := users.UsernameExists(r.Context(), credential.Username)
+
 	//Check for error
 	if err != nil {
-		http.Error(w, errors.New("error checking if username exists").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error checking if username exists", err)
 		return
 	}
 
 	//Check boolean returned from query
 	if exists == true {
-		http.Error(w, errors.New("this username is taken").Error(), http.StatusConflict)
+		problem.Error(w, r, http.StatusConflict, CodeUsernameTaken, "this username is taken")
 		return
 	}
 
 	//Check if the email already exists
-	err = DB.QueryRow("SELECT EXISTS (SELECT username FROM users WHERE email=?)", credential.Email).Scan(&exists)
-	
+	exists, err = users.EmailExists(r.Context(), credential.Email)
+
 	//Check for error
 	if err != nil {
-		http.Error(w, errors.New("error checking if email exists").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error checking if email exists", err)
 		return
 	}
 
 	//Check boolean returned from query
 	if exists == true {
-		http.Error(w, errors.New("this email is taken").Error(), http.StatusConflict)
+		problem.Error(w, r, http.StatusConflict, CodeEmailTaken, "this email is taken")
 		return
 	}
 
@@ -103,8 +89,7 @@ func signup(w http.ResponseWriter, r *http.Request) {
 
 	//Check for errors during hashing process
 	if err != nil {
-		http.Error(w, errors.New("error during hashing process").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error during hashing process", err)
 		return
 	}
 
@@ -115,81 +100,33 @@ func signup(w http.ResponseWriter, r *http.Request) {
 	verify_token := GetRandomBase62(verifyTokenSize)
 
 	//Store credentials in database
-	_, err = DB.Query("INSERT INTO users (username, email, hashedPassword, verified, resetToken, verifiedToken, userID) VALUES (?,?,?, True, NULL, ?, ?)", 
-					   credential.Username, credential.Email, hashed_password, verify_token, userID)
-	
-	//Check for errors in storing the credentials
-	if err != nil {
-		http.Error(w, errors.New("error in storing the credentials").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
-		return
-	}
-
-	//Generate an access token, expiry dates are in Unix time
-	accessExpiresAt := time.Now().Add(time.Minute * 15) //set for 15 minutes
-	var accessToken string
-	accessToken, err = setClaims(AuthClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			Subject:   "access",
-			ExpiresAt: accessExpiresAt.Unix(),
-			Issuer:    defaultJWTIssuer,
-			IssuedAt:  time.Now().Unix(),
-		},
+	err = users.CreateUser(r.Context(), User{
+		UserID:         userID,
+		Username:       credential.Username,
+		Email:          credential.Email,
+		HashedPassword: string(hashed_password),
+		Verified:       true,
+		VerifiedToken:  verify_token,
 	})
-	
-	//Check for error in generating an access token
+
+	//Check for errors in storing the credentials
 	if err != nil {
-		http.Error(w, errors.New("error in generating an access token").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error in storing the credentials", err)
 		return
 	}
+	signups.Inc()
 
-
-	//Set the cookie, name it "access_token"
-	http.SetCookie(w, &http.Cookie{
-		Name:    "access_token",
-		Value:   accessToken,
-		Expires: accessExpiresAt,
-		// Leave these next three values commented for now
-		// Secure: true,
-		// HttpOnly: true,
-		// SameSite: http.SameSiteNoneMode,
-		Path: "/",
-	})
-
-	//Generate refresh token
-	var refreshExpiresAt = time.Now().Add(DefaultRefreshJWTExpiry)
-	var refreshToken string
-	refreshToken, err = setClaims(AuthClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			Subject:   "refresh",
-			ExpiresAt: refreshExpiresAt.Unix(),
-			Issuer:    defaultJWTIssuer,
-			IssuedAt: time.Now().Unix(),
-		},
-	})
-
+	//Generate an access token and a refresh token and set them as cookies
+	err = setAuthCookies(w, userID)
 	if err != nil {
-		http.Error(w, errors.New("error creating refreshToken").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error in generating tokens", err)
 		return
 	}
 
-	//set the refresh token ("refresh_token") as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:    "refresh_token",
-		Value:   refreshToken,
-		Expires: refreshExpiresAt,
-		Path: "/",
-	})
-
 	// Send verification email
-	err = SendEmail(credential.Email, "Email Verification", "user-signup.html", map[string]interface{}{"Token": verify_token})
+	err = sendEmail(credential.Email, "Email Verification", "user-signup.html", map[string]interface{}{"Token": verify_token})
 	if err != nil {
-		http.Error(w, errors.New("error sending verification email").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error sending verification email", err)
 		return
 	}
 
@@ -198,20 +135,11 @@ func signup(w http.ResponseWriter, r *http.Request) {
 }
 
 func signin(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-
 	//Store the credentials in a instance of Credentials + //Check for errors in storing credntials
 	credential := Credentials{}
-	err := json.NewDecoder(r.Body).Decode(&credential)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !validate.DecodeJSON(w, r, &credential) {
+		signins.WithLabelValues("password", "invalid_request").Inc()
+		return
 	}
 
 	// if credential.Username == "" || credential.Password == "" || credential.Email == ""{
@@ -223,177 +151,97 @@ func signin(w http.ResponseWriter, r *http.Request) {
 
 	//Get the hashedPassword and userId of the user
 	//team notes: might be trouble later
-	var hashedPassword, userID string
-	err = DB.QueryRow("SELECT hashedPassword, userId FROM users WHERE username = ? || email = ?", credential.Username, credential.Email).Scan(&hashedPassword, &userID)
+	user, err := users.FindUser(r.Context(), credential.Username, credential.Email)
 	// process errors associated with emails
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, errors.New("this email is not associated with an account").Error(), http.StatusNotFound)
+		if err == ErrUserNotFound {
+			problem.Error(w, r, http.StatusNotFound, CodeUserNotFound, "this email is not associated with an account")
+			signins.WithLabelValues("password", "unknown_user").Inc()
 		} else {
-			http.Error(w, errors.New("error retrieving information with this email").Error(), http.StatusInternalServerError)
-			log.Print(err.Error())
+			problem.Internal(w, r, "error retrieving information with this email", err)
+			signins.WithLabelValues("password", "error").Inc()
 		}
 		return
 	}
 
 	// Check if hashed password matches the one corresponding to the email + Check error in comparing hashed passwords
 
-	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(credential.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.HashedPassword), []byte(credential.Password))
 	if err != nil {
-		http.Error(w, errors.New("incorrect password").Error(), http.StatusUnauthorized)
-		log.Print(err.Error())
-	}
-
-	//Generate an access token  and set it as a cookie (Look at signup and feel free to copy paste!)
-	//Generate an access token, expiry dates are in Unix time
-	accessExpiresAt := time.Now().Add(time.Minute * 15) //set for 15 minutes
-	var accessToken string
-	accessToken, err = setClaims(AuthClaims{
-		UserID: credential.Username,
-		StandardClaims: jwt.StandardClaims{
-			Subject:   "access",
-			ExpiresAt: accessExpiresAt.Unix(),
-			Issuer:    defaultJWTIssuer,
-			IssuedAt:  time.Now().Unix(),
-		},
-	})
-	
-	//Check for error in generating an access token
-	if err != nil {
-		http.Error(w, errors.New("error in generating an access token").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Error(w, r, http.StatusUnauthorized, CodeWrongPassword, "incorrect password")
+		slog.WarnContext(r.Context(), "incorrect password", "err", err)
+		signins.WithLabelValues("password", "wrong_password").Inc()
 		return
 	}
 
-	//Set the cookie, name it "access_token"
-	http.SetCookie(w, &http.Cookie{
-		Name:    "access_token",
-		Value:   accessToken,
-		Expires: accessExpiresAt,
-		// Leave these next three values commented for now
-		// Secure: true,
-		// HttpOnly: true,
-		// SameSite: http.SameSiteNoneMode,
-		Path: "/",
-	})
-
-	//Generate a refresh token and set it as a cookie (Look at signup and feel free to copy paste!)
-	//Generate refresh token
-	var refreshExpiresAt = time.Now().Add(DefaultRefreshJWTExpiry)
-	var refreshToken string 
-	refreshToken, err = setClaims(AuthClaims{
-		UserID: userID,
-		StandardClaims: jwt.StandardClaims{
-			Subject:   "refresh",
-			ExpiresAt: refreshExpiresAt.Unix(),
-			Issuer:    defaultJWTIssuer,
-			IssuedAt: time.Now().Unix(),
-		},
-	})
-
+	//Generate an access token and a refresh token and set them as cookies
+	err = setAuthCookies(w, user.UserID)
 	if err != nil {
-		http.Error(w, errors.New("error creating refreshToken").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error in generating tokens", err)
+		signins.WithLabelValues("password", "error").Inc()
 		return
 	}
-
-	//set the refresh token ("refresh_token") as a cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:    "refresh_token",
-		Value:   refreshToken,
-		Expires: refreshExpiresAt,
-		Path: "/",
-	})
+	signins.WithLabelValues("password", "success").Inc()
 	//max notes: add header?
 	w.WriteHeader(200)
 	return
 }
 
 func logout(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-
 	// logging out causes expiration time of cookie to be set to now
 
 	//Set the access_token and refresh_token to have an empty value and set their expiration date to anytime in the past
 
 	var expiresAt = time.Now()
-	http.SetCookie(w, &http.Cookie{Name: "access_token", Value: "", Expires: expiresAt})
-	http.SetCookie(w, &http.Cookie{Name: "refresh_token", Value: "" , Expires: expiresAt})
+	http.SetCookie(w, cookieAttributes.Apply(&http.Cookie{Name: "access_token", Value: "", Expires: expiresAt, Path: "/"}))
+	http.SetCookie(w, cookieAttributes.Apply(&http.Cookie{Name: "refresh_token", Value: "", Expires: expiresAt, Path: "/"}))
 	return
 }
 
 func verify(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-
 	//max notes: will this return the verified token? it's Token in the verification email and token here
 	token, ok := r.URL.Query()["token"]
 	// check that valid token exists
 	if !ok || len(token[0]) < 1 {
-		http.Error(w, errors.New("Url Param 'token' is missing").Error(), http.StatusBadRequest)
-		log.Print(errors.New("Url Param 'token' is missing").Error())
+		fields := problem.Fields{}
+		fields.Require("token", "")
+		problem.Invalid(w, r, fields)
 		return
 	}
 
 	//Obtain the user with the verifiedToken from the query parameter and set their verification status to the integer "1"
-	//team note: Replace Into vs UPDATE
-	result, err := DB.Exec("UPDATE users SET verified = 1 WHERE verifiedToken = ?", token)
+	err := users.Verify(r.Context(), token[0])
 
 	//Check for errors in executing the previous query
-	//max notes: probably haven't covered all use cases yet
-	if err != nil {
-		http.Error(w, errors.New("Something went wrong").Error(),http.StatusBadRequest)
-		log.Print(err.Error())
+	if err == ErrTokenNotFound {
+		problem.Error(w, r, http.StatusBadRequest, CodeInvalidVerifyToken, "cannot find that verification token")
 		return
 	}
-	//team notes: added to deal with no verification/ might need to take out if problems
-	r2, err := result.RowsAffected()
-	if r2 == 0 {
-		http.Error(w, errors.New("Cannot find that verification token").Error(), http.StatusNotFound)
-		log.Print(err.Error())
+	if err != nil {
+		problem.Internal(w, r, "error verifying the user", err)
 		return
-	} 
+	}
 	w.WriteHeader(200)
 	return
 }
 
 
 func sendReset(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-
 	//Get the email from the body (decode into an instance of Credentials)
 	credential := Credentials{}
-	err := json.NewDecoder(r.Body).Decode(&credential)
 	
 	//check for errors decoding the object
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !validate.DecodeJSON(w, r, &credential) {
+		return
 	}
 
 	//check for other miscallenous errors that may occur
 	//what is considered an invalid input for an email?
 	//max notes: are there other invalid inputs?
 	if credential.Email == ""{
-		w.WriteHeader(400)
+		fields := problem.Fields{}
+		fields.Require("email", credential.Email)
+		problem.Invalid(w, r, fields)
 		return
 	}
 
@@ -403,55 +251,50 @@ func sendReset(w http.ResponseWriter, r *http.Request) {
 	token := GetRandomBase62(resetTokenSize)
 
 	//Obtain the user with the specified email and set their resetToken to the token we generated
-	//team note: Replace Into vs UPDATE
-	_, err = DB.Query("UPDATE users SET resetToken = ? WHERE email = ?", token, credential.Email)
-	// _, err = DB.Query("YOUR CODE HERE", /*YOUR CODE HERE*/, /*YOUR CODE HERE*/)
-	
+	err := users.SetResetToken(r.Context(), credential.Email, token)
+
 	//Check for errors executing the queries
-	//max notes: right error?
+	if err == ErrUserNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodeUserNotFound, "this email is not associated with an account")
+		return
+	}
 	if err != nil {
-		http.Error(w, errors.New("error finding user").Error(), http.StatusNotFound)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error storing the reset token", err)
 		return
 	}
 
 	// Send verification email
-	err = SendEmail(credential.Email, "BearChat Password Reset", "password-reset.html", map[string]interface{}{"Token": token})
+	err = sendEmail(credential.Email, "BearChat Password Reset", "password-reset.html", map[string]interface{}{"Token": token})
 	if err != nil {
-		http.Error(w, errors.New("error sending verification email").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error sending verification email", err)
 		return
 	}
 	return
 }
 
 func resetPassword(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Access-Control-Allow-Origin", "localhost:3000")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	if (*r).Method == "OPTIONS" {
-		return
-	}
-	
 	//get token from query params
 	//team notes: problems with uppercase vs. lowercase?
 	token := r.URL.Query().Get("token")
 
 	//get the username, email, and password from the body
 	credential := Credentials{}
-	err := json.NewDecoder(r.Body).Decode(&credential)
 
 
 	//Check for errors decoding the body
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !problem.DecodeJSON(w, r, &credential) {
+		return
 	}
 
 	//Check for invalid inputs, return an error if input is invalid
-	if credential.Username == "" || credential.Password == "" || credential.Email == "" {
-		w.WriteHeader(400)
+	fields := validate.Struct(credential)
+	fields.Require("token", token)
+	fields.Require("username", credential.Username)
+	fields.Require("email", credential.Email)
+	fields.Require("password", credential.Password)
+	validate.Var(&fields, "password", credential.Password, newPasswordRule)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
 		return
 	}
 
@@ -459,42 +302,27 @@ func resetPassword(w http.ResponseWriter, r *http.Request) {
 	email := credential.Email
 	username := credential.Username
 	password := credential.Password
-	var exists bool
-	//check if the username and token pair exist
-	err = DB.QueryRow("SELECT EXISTS (SELECT username, resetToken FROM users WHERE username = ?, resetToken = ?", username, token).Scan(&exists)
-
-	//Check for errors executing the query	
-	if err != nil {
-		http.Error(w, errors.New("error checking if username exists").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
-		return
-	}
-
-	//Check exists boolean. Call an error if the username-token pair doesn't exist
-	if exists != true {
-		http.Error(w, errors.New("this username is taken").Error(), http.StatusConflict)
-		return
-	}
-
-
 
 	//Hash the new password
 	hashed_password, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
 	//Check for errors in hashing the new password
 	if err != nil {
-		http.Error(w, errors.New("error during hashing process").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error during hashing process", err)
 		return
 	}
 
 
 	//input new password and clear the reset token (set the token equal to empty string)
-	//team note: Replace Into vs UPDATE
-	_, err = DB.Exec("UPDATE users SET hashedPassword = ?, resetToken = '' WHERE username = ?, email = ?", hashed_password, username, email)
+	//the update only happens when the username, email and token belong together
+	err = users.ResetPassword(r.Context(), username, email, token, string(hashed_password))
+	if err == ErrTokenNotFound {
+		problem.Error(w, r, http.StatusBadRequest, CodeInvalidResetToken, "invalid reset token")
+		return
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error resetting the password", err)
+		return
 	}
 
 	//put the user in the redis cache to invalidate all current sessions (NOT IN SCOPE FOR PROJECT), leave this comment for future reference
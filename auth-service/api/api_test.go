@@ -0,0 +1,329 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/gorilla/mux"
+)
+
+//sentEmail is an email recorded by fakeMailer
+type sentEmail struct {
+	recipient string
+	subject   string
+	template  string
+	token     string
+}
+
+//fakeMailer records emails instead of sending them
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (m *fakeMailer) SendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, _ := data["Token"].(string)
+	m.sent = append(m.sent, sentEmail{recipient: recipient, subject: subject, template: templatePath, token: token})
+	return nil
+}
+
+func (m *fakeMailer) last(t *testing.T) sentEmail {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	return m.sent[len(m.sent)-1]
+}
+
+//newAuthServer starts every auth route against an in-memory store
+func newAuthServer(t *testing.T) (*httptest.Server, *MemoryUserStore, *fakeMailer) {
+	previous := csrfProtector
+	csrfProtector = csrf.New([]byte("test-secret"), cookies.Development)
+	t.Cleanup(func() { csrfProtector = previous })
+
+	store := NewMemoryUserStore()
+	m := &fakeMailer{}
+	router := mux.NewRouter()
+	err := RegisterRoutes(router, store, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every response must match openapi.yaml
+	server := httptest.NewServer(openapi.Validate(doc, router, func(err error) { t.Error(err) }))
+	t.Cleanup(server.Close)
+	return server, store, m
+}
+
+func cookie(resp *http.Response, name string) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func signupOski(t *testing.T, server *httptest.Server) *http.Response {
+	t.Helper()
+	resp, body := post(t, http.DefaultClient, server.URL+"/api/auth/signup", `{"username":"oski","email":"oski@berkeley.edu","password":"gobears!"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("signup: expected 201 but was %d: %s", resp.StatusCode, body)
+	}
+	return resp
+}
+
+func TestSignup(t *testing.T) {
+	server, store, m := newAuthServer(t)
+
+	resp := signupOski(t, server)
+	if cookie(resp, "access_token") == nil || cookie(resp, "refresh_token") == nil {
+		t.Fatal("signup didn't set the auth cookies")
+	}
+	user, err := store.FindUser(context.Background(), "oski", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID := accessTokenUserID(t, resp); userID != user.UserID {
+		t.Fatalf("expected access token for %s but got %s", user.UserID, userID)
+	}
+	if user.HashedPassword == "gobears!" {
+		t.Fatal("password was stored in plain text")
+	}
+	email := m.last(t)
+	if email.recipient != "oski@berkeley.edu" || email.template != "user-signup.html" || email.token != user.VerifiedToken {
+		t.Fatalf("unexpected verification email %+v", email)
+	}
+}
+
+func TestSignupRejectsDuplicatesAndMissingFields(t *testing.T) {
+	server, _, _ := newAuthServer(t)
+	signupOski(t, server)
+
+	cases := []struct {
+		body   string
+		status int
+	}{
+		{`{"username":"oski","email":"other@berkeley.edu","password":"gobears!"}`, http.StatusConflict},
+		{`{"username":"other","email":"oski@berkeley.edu","password":"gobears!"}`, http.StatusConflict},
+		{`{"username":"other","email":"other@berkeley.edu"}`, http.StatusBadRequest},
+		{`not json`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, body := post(t, http.DefaultClient, server.URL+"/api/auth/signup", c.body)
+		if resp.StatusCode != c.status {
+			t.Errorf("signup %s: expected %d but was %d: %s", c.body, c.status, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestSignupValidatesTheCredentials(t *testing.T) {
+	server, _, _ := newAuthServer(t)
+
+	cases := []struct {
+		body  string
+		field string
+		code  string
+	}{
+		{`{"username":"oski","email":"oski at berkeley","password":"gobears!"}`, "email", "invalid"},
+		{`{"username":"os","email":"oski@berkeley.edu","password":"gobears!"}`, "username", "too_short"},
+		{`{"username":"oski_the_bear_of_berkeley","email":"oski@berkeley.edu","password":"gobears!"}`, "username", "too_long"},
+		{`{"username":"oski bear","email":"oski@berkeley.edu","password":"gobears!"}`, "username", "invalid"},
+		{`{"username":"oski","email":"oski@berkeley.edu","password":"bears"}`, "password", "too_short"},
+		//73 bytes but 25 characters, bcrypt hashes at most 72 bytes
+		{`{"username":"oski","email":"oski@berkeley.edu","password":"a` + strings.Repeat("熊", 24) + `"}`, "password", "too_long"},
+		{`{"username":"oski","email":"oski@berkeley.edu","password":"gobears!","admin":true}`, "admin", "unknown"},
+		{`{"username":7,"email":"oski@berkeley.edu","password":"gobears!"}`, "username", "invalid_type"},
+	}
+	for _, c := range cases {
+		resp, body := post(t, http.DefaultClient, server.URL+"/api/auth/signup", c.body)
+		p := problem.Problem{}
+		json.Unmarshal([]byte(body), &p)
+		if resp.StatusCode != http.StatusBadRequest || p.Code != problem.CodeValidation || len(p.Errors) != 1 ||
+			p.Errors[0].Field != c.field || p.Errors[0].Code != c.code {
+			t.Errorf("signup %s: expected %s to be %s but got %d: %s", c.body, c.field, c.code, resp.StatusCode, body)
+		}
+	}
+
+	huge := `{"username":"oski","email":"oski@berkeley.edu","password":"` + strings.Repeat("a", int(problem.MaxBodyBytes)) + `"}`
+	resp, body := post(t, http.DefaultClient, server.URL+"/api/auth/signup", huge)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a huge body but was %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestSignin(t *testing.T) {
+	server, _, _ := newAuthServer(t)
+	signupOski(t, server)
+
+	for _, body := range []string{`{"username":"oski","password":"gobears!"}`, `{"email":"oski@berkeley.edu","password":"gobears!"}`} {
+		resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/signin", body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("signin %s: expected 200 but was %d", body, resp.StatusCode)
+		}
+		if cookie(resp, "access_token") == nil {
+			t.Fatalf("signin %s didn't set the access token", body)
+		}
+	}
+
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/signin", `{"username":"oski","password":"wrong"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password but was %d", resp.StatusCode)
+	}
+	if cookie(resp, "access_token") != nil {
+		t.Fatal("a wrong password must not set the access token")
+	}
+
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/signin", `{"username":"nobody","password":"gobears!"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown user but was %d", resp.StatusCode)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	server, _, _ := newAuthServer(t)
+
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/logout", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	for _, name := range []string{"access_token", "refresh_token"} {
+		c := cookie(resp, name)
+		if c == nil || c.Value != "" || c.Expires.After(time.Now()) {
+			t.Fatalf("logout didn't clear %s: %+v", name, c)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	server, store, m := newAuthServer(t)
+	signupOski(t, server)
+	token := m.last(t).token
+
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/verify?token="+token, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	user, _ := store.FindUser(context.Background(), "oski", "")
+	if !user.Verified {
+		t.Fatal("user wasn't verified")
+	}
+
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/verify?token=dummy", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown token but was %d", resp.StatusCode)
+	}
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/verify", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a token but was %d", resp.StatusCode)
+	}
+}
+
+func TestSendReset(t *testing.T) {
+	server, _, m := newAuthServer(t)
+	signupOski(t, server)
+
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/sendreset", `{"email":"oski@berkeley.edu"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	if email := m.last(t); email.template != "password-reset.html" || email.token == "" {
+		t.Fatalf("unexpected reset email %+v", email)
+	}
+
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/sendreset", `{"email":"nobody@berkeley.edu"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown email but was %d", resp.StatusCode)
+	}
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/sendreset", `{}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without an email but was %d", resp.StatusCode)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	server, _, m := newAuthServer(t)
+	signupOski(t, server)
+	post(t, http.DefaultClient, server.URL+"/api/auth/sendreset", `{"email":"oski@berkeley.edu"}`)
+	token := m.last(t).token
+
+	body := `{"username":"oski","email":"oski@berkeley.edu","password":"newpassword"}`
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/resetpw?token=wrong", body)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a wrong token but was %d", resp.StatusCode)
+	}
+
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/resetpw?token="+token, body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/signin", `{"username":"oski","password":"newpassword"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("signin with the new password: expected 200 but was %d", resp.StatusCode)
+	}
+	post(t, http.DefaultClient, server.URL+"/api/auth/sendreset", `{"email":"oski@berkeley.edu"}`)
+	multibyte := `{"username":"oski","email":"oski@berkeley.edu","password":"` + strings.Repeat("熊", 30) + `"}`
+	resp, problemBody := post(t, http.DefaultClient, server.URL+"/api/auth/resetpw?token="+m.last(t).token, multibyte)
+	if resp.StatusCode != http.StatusBadRequest || !strings.Contains(problemBody, `"too_long"`) {
+		t.Fatalf("expected 400 too_long for a password over 72 bytes but was %d: %s", resp.StatusCode, problemBody)
+	}
+
+	//the token is single use
+	resp, _ = post(t, http.DefaultClient, server.URL+"/api/auth/resetpw?token="+token, body)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 when reusing the token but was %d", resp.StatusCode)
+	}
+}
+
+func TestIssueCSRFToken(t *testing.T) {
+	server, _, _ := newAuthServer(t)
+
+	resp, err := http.Get(server.URL + "/api/auth/csrf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	body := map[string]string{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	c := cookie(resp, csrf.CookieName)
+	if c == nil || body["csrfToken"] == "" || c.Value != body["csrfToken"] {
+		t.Fatalf("expected matching cookie and body tokens, got %+v and %v", c, body)
+	}
+}
+
+//TestOpenAPI checks that openapi.yaml documents exactly the routes of RegisterRoutes
+func TestOpenAPI(t *testing.T) {
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	err = RegisterRoutes(router, NewMemoryUserStore(), &fakeMailer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openapi.CheckRoutes(doc, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+)
+
+//Config holds the auth-service settings, see the config package for how they are loaded
+type Config struct {
+	config.Common     `yaml:",inline"`
+	database.Settings `yaml:",inline"`
+
+	DatabaseDSN     string        `env:"DATABASE_DSN" yaml:"database_dsn" default:"root:root@tcp(172.28.1.2:3306)/auth" required:"true" secret:"dsn"`
+	JWTIssuer       string        `env:"JWT_ISSUER" yaml:"jwt_issuer" default:"CalChat"`
+	AccessTokenTTL  time.Duration `env:"ACCESS_TOKEN_TTL" yaml:"access_token_ttl" default:"15m"`
+	RefreshTokenTTL time.Duration `env:"REFRESH_TOKEN_TTL" yaml:"refresh_token_ttl" default:"720h"`
+
+	SendgridKey    string `env:"SENDGRID_KEY" yaml:"sendgrid_key" required:"true" secret:"true"`
+	MailSender     string `env:"MAIL_SENDER" yaml:"mail_sender" default:"maxmir@berkeley.edu"`
+	MailSenderName string `env:"MAIL_SENDER_NAME" yaml:"mail_sender_name" default:"Cloud9 Test"`
+
+	WebAuthnRPID    string   `env:"WEBAUTHN_RP_ID" yaml:"webauthn_rp_id" default:"localhost"`
+	WebAuthnRPName  string   `env:"WEBAUTHN_RP_NAME" yaml:"webauthn_rp_name" default:"BearChat"`
+	WebAuthnOrigins []string `env:"WEBAUTHN_RP_ORIGINS" yaml:"webauthn_rp_origins" default:"http://localhost:3000"`
+}
+
+//Configure applies cfg to the mailer, tokens, cookies and passkeys
+func Configure(cfg Config) error {
+	initMailer(cfg)
+
+	jwtKey = []byte(cfg.JWTSecret)
+	defaultJWTIssuer = cfg.JWTIssuer
+	DefaultAccessJWTExpiry = cfg.AccessTokenTTL
+	DefaultRefreshJWTExpiry = cfg.RefreshTokenTTL
+
+	cookieAttributes = cfg.Cookies()
+	csrfProtector = csrf.New([]byte(cfg.CSRFSecret), cookieAttributes)
+
+	return initWebAuthn(cfg)
+}
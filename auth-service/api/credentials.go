@@ -1,8 +1,16 @@
 package api
 
 //Credentials respresents the user login object
+//Which fields are required depends on the route, the lengths follow the users table and bcrypt,
+//which hashes at most 72 bytes
 type Credentials struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
\ No newline at end of file
+	Username string `json:"username" validate:"omitempty,max=20,username"`
+	Email    string `json:"email" validate:"omitempty,max=320,email"`
+	Password string `json:"password" validate:"omitempty,maxbytes=72"`
+}
+
+//The rules for new accounts and passwords, on top of those of Credentials
+const (
+	newUsernameRule = "omitempty,min=3"
+	newPasswordRule = "omitempty,min=8"
+)
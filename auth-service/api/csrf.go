@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+)
+
+var (
+	cookieAttributes = cookies.Development
+	csrfProtector    *csrf.Protector
+)
+
+//CSRF is middleware rejecting cookie-authenticated mutations without a valid CSRF token
+func CSRF(next http.Handler) http.Handler {
+	return csrfProtector.Protect(next)
+}
+
+func issueCSRFToken(w http.ResponseWriter, r *http.Request) {
+	csrfProtector.IssueToken(w, r)
+}
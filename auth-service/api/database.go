@@ -1,12 +1,15 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"log"
-	"fmt"
 	"time"
-	//MySQL driver
-	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 //DB represents the connection to the MySQL database
@@ -14,35 +17,169 @@ var (
 	DB *sql.DB
 )
 
-//InitDB creates the MySQL database connection
-func InitDB() *sql.DB {
-
+//InitDB creates the MySQL database connection, waiting for the server to come up
+func InitDB(ctx context.Context, dsn string, settings database.Settings) (*sql.DB, error) {
 	log.Println("attempting connections")
 
 	var err error
-	
-	// Open a SQL connection to the docker container hosting the database server
-	// Assign the connection to the "DB" variable
-	// Look at how it's done in the other microservices!
-	// team note: changed the username and ipaddress to match direct string
-	dbType := "mysql"
-	username := "root"
-	password := "root"
-	ipAddress := "tcp(172.28.1.2:3306)"
-	dbName := "/auth"
-	
-	DB, err = sql.Open(dbType, fmt.Sprintf("%s:%s@%s%s", username, password, ipAddress, dbName))
-	//"root:root@tcp(172.28.1.2:3306)/postsDB?parseTime=true"
-
-
-	
-	_, err = DB.Query("SELECT * FROM users")
-	for err != nil {
-		log.Println("couldnt connect, waiting 20 seconds before retrying")
-		time.Sleep(20*time.Second)
-		// Connect again, use the same connection function as you did above ^
-		DB, err = sql.Open(dbType, fmt.Sprintf("%s:%s@%s%s", username, password, ipAddress, dbName))
-	}
-
-	return DB
-}
\ No newline at end of file
+	DB, err = database.Open(ctx, "mysql", dsn, settings)
+	if err != nil {
+		return nil, err
+	}
+	database.Publish("auth", DB)
+	metrics.RegisterDB("auth", DB)
+	return DB, nil
+}
+
+//MySQLUserStore keeps users in the users table and their passkeys in the passkeys table
+type MySQLUserStore struct {
+	db *sql.DB
+}
+
+//NewMySQLUserStore creates a UserStore backed by db
+func NewMySQLUserStore(db *sql.DB) *MySQLUserStore {
+	return &MySQLUserStore{db: db}
+}
+
+func (s *MySQLUserStore) UsernameExists(ctx context.Context, username string) (bool, error) {
+	defer metrics.TimeQuery("auth", "UsernameExists")()
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT username FROM users WHERE username = ?)", username).Scan(&exists)
+	return exists, err
+}
+
+func (s *MySQLUserStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	defer metrics.TimeQuery("auth", "EmailExists")()
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT username FROM users WHERE email = ?)", email).Scan(&exists)
+	return exists, err
+}
+
+func (s *MySQLUserStore) CreateUser(ctx context.Context, user User) error {
+	defer metrics.TimeQuery("auth", "CreateUser")()
+	_, err := s.db.ExecContext(ctx, "INSERT INTO users (username, email, hashedPassword, verified, resetToken, verifiedToken, userId) VALUES (?,?,?,?, NULL, ?, ?)",
+		user.Username, user.Email, user.HashedPassword, user.Verified, user.VerifiedToken, user.UserID)
+	return err
+}
+
+func (s *MySQLUserStore) FindUser(ctx context.Context, username string, email string) (User, error) {
+	defer metrics.TimeQuery("auth", "FindUser")()
+	user := User{}
+	err := s.db.QueryRowContext(ctx, "SELECT userId, username, email, hashedPassword, verified FROM users WHERE username = ? OR email = ? LIMIT 1", username, email).
+		Scan(&user.UserID, &user.Username, &user.Email, &user.HashedPassword, &user.Verified)
+	if err == sql.ErrNoRows {
+		return User{}, ErrUserNotFound
+	}
+	return user, err
+}
+
+func (s *MySQLUserStore) Verify(ctx context.Context, token string) error {
+	defer metrics.TimeQuery("auth", "Verify")()
+	//MySQL reports changed rows, so verifying twice can't rely on RowsAffected
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT username FROM users WHERE verifiedToken = ?)", token).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTokenNotFound
+	}
+	_, err = s.db.ExecContext(ctx, "UPDATE users SET verified = 1 WHERE verifiedToken = ?", token)
+	return err
+}
+
+func (s *MySQLUserStore) SetResetToken(ctx context.Context, email string, token string) error {
+	defer metrics.TimeQuery("auth", "SetResetToken")()
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET resetToken = ? WHERE email = ?", token, email)
+	return matched(result, err, ErrUserNotFound)
+}
+
+func (s *MySQLUserStore) ResetPassword(ctx context.Context, username string, email string, token string, hashedPassword string) error {
+	defer metrics.TimeQuery("auth", "ResetPassword")()
+	result, err := s.db.ExecContext(ctx, "UPDATE users SET hashedPassword = ?, resetToken = '' WHERE username = ? AND email = ? AND resetToken = ?",
+		hashedPassword, username, email, token)
+	return matched(result, err, ErrTokenNotFound)
+}
+
+//matched turns an UPDATE that changed no rows into notFound
+func matched(result sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (s *MySQLUserStore) Username(ctx context.Context, userID string) (string, error) {
+	defer metrics.TimeQuery("auth", "Username")()
+	var username string
+	err := s.db.QueryRowContext(ctx, "SELECT username FROM users WHERE userId = ?", userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return username, err
+}
+
+func (s *MySQLUserStore) UserID(ctx context.Context, username string) (string, error) {
+	defer metrics.TimeQuery("auth", "UserID")()
+	var userID string
+	err := s.db.QueryRowContext(ctx, "SELECT userId FROM users WHERE username = ?", username).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	return userID, err
+}
+
+func (s *MySQLUserStore) Credentials(ctx context.Context, userID string) ([]webauthn.Credential, error) {
+	defer metrics.TimeQuery("auth", "Credentials")()
+	rows, err := s.db.QueryContext(ctx, "SELECT credential, signCount FROM passkeys WHERE userId = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []webauthn.Credential{}
+	for rows.Next() {
+		var (
+			encoded   []byte
+			signCount uint32
+		)
+		err = rows.Scan(&encoded, &signCount)
+		if err != nil {
+			return nil, err
+		}
+
+		credential := webauthn.Credential{}
+		err = json.Unmarshal(encoded, &credential)
+		if err != nil {
+			return nil, err
+		}
+		//the signCount column is authoritative, the JSON copy is only written at registration
+		credential.Authenticator.SignCount = signCount
+		credentials = append(credentials, credential)
+	}
+	return credentials, rows.Err()
+}
+
+func (s *MySQLUserStore) AddCredential(ctx context.Context, userID string, credential webauthn.Credential) error {
+	defer metrics.TimeQuery("auth", "AddCredential")()
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "INSERT INTO passkeys (credentialID, userId, credential, signCount, createdAt) VALUES (?,?,?,?,?)",
+		credential.ID, userID, encoded, credential.Authenticator.SignCount, time.Now())
+	return err
+}
+
+func (s *MySQLUserStore) UpdateSignCount(ctx context.Context, credentialID []byte, signCount uint32) error {
+	defer metrics.TimeQuery("auth", "UpdateSignCount")()
+	_, err := s.db.ExecContext(ctx, "UPDATE passkeys SET signCount = ?, lastUsedAt = ? WHERE credentialID = ?", signCount, time.Now(), credentialID)
+	return err
+}
@@ -0,0 +1,15 @@
+package api
+
+//The error codes of auth-service, next to the shared ones of the problem package
+const (
+	CodeUsernameTaken      = "username_taken"
+	CodeEmailTaken         = "email_taken"
+	CodeUserNotFound       = "user_not_found"
+	CodeWrongPassword      = "wrong_password"
+	CodeInvalidVerifyToken = "invalid_verification_token"
+	CodeInvalidResetToken  = "invalid_reset_token"
+	CodePasskeySession     = "passkey_session_expired"
+	CodePasskeyFailed      = "passkey_failed"
+	CodePasskeyCloned      = "passkey_cloned"
+	CodeNoPasskeys         = "no_passkeys"
+)
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"math/rand"
+	"net/http"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -10,7 +11,7 @@ import (
 
 var (
 	//DefaultAccessJWTExpiry is the default access token duration
-	DefaultAccessJWTExpiry = 01 * 1440 * time.Minute // refresh every 01 days
+	DefaultAccessJWTExpiry = 15 * time.Minute
 	//DefaultRefreshJWTExpiry is the default refresh token duration
 	DefaultRefreshJWTExpiry = 30 * 1440 * time.Minute // refresh every 30 days
 	defaultJWTIssuer        = "CalChat"
@@ -55,4 +56,53 @@ func GetRandomBase62(length int) string {
 		r[i] = base62[rand.Intn(len(base62))]
 	}
 	return string(r)
-}
\ No newline at end of file
+}
+//setAuthCookies issues a fresh access and refresh token for userID and sets them as cookies
+func setAuthCookies(w http.ResponseWriter, userID string) error {
+	//Generate an access token, expiry dates are in Unix time
+	accessExpiresAt := time.Now().Add(DefaultAccessJWTExpiry)
+	accessToken, err := setClaims(AuthClaims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "access",
+			ExpiresAt: accessExpiresAt.Unix(),
+			Issuer:    defaultJWTIssuer,
+			IssuedAt:  time.Now().Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	//Set the cookie, name it "access_token"
+	http.SetCookie(w, cookieAttributes.Apply(&http.Cookie{
+		Name:    "access_token",
+		Value:   accessToken,
+		Expires: accessExpiresAt,
+		Path:    "/",
+	}))
+
+	//Generate refresh token
+	refreshExpiresAt := time.Now().Add(DefaultRefreshJWTExpiry)
+	refreshToken, err := setClaims(AuthClaims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "refresh",
+			ExpiresAt: refreshExpiresAt.Unix(),
+			Issuer:    defaultJWTIssuer,
+			IssuedAt:  time.Now().Unix(),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	//set the refresh token ("refresh_token") as a cookie
+	http.SetCookie(w, cookieAttributes.Apply(&http.Cookie{
+		Name:    "refresh_token",
+		Value:   refreshToken,
+		Expires: refreshExpiresAt,
+		Path:    "/",
+	}))
+	return nil
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+//MemoryUserStore is an in-memory UserStore, used for tests and local development
+type MemoryUserStore struct {
+	mu          sync.Mutex
+	users       map[string]User
+	resetTokens map[string]string
+	credentials map[string][]webauthn.Credential
+}
+
+//NewMemoryUserStore creates an empty MemoryUserStore
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users:       make(map[string]User),
+		resetTokens: make(map[string]string),
+		credentials: make(map[string][]webauthn.Credential),
+	}
+}
+
+func (s *MemoryUserStore) UsernameExists(ctx context.Context, username string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Username == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryUserStore) EmailExists(ctx context.Context, email string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Email == email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *MemoryUserStore) CreateUser(ctx context.Context, user User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[user.UserID]; ok {
+		return errors.New("duplicate userId")
+	}
+	s.users[user.UserID] = user
+	return nil
+}
+
+func (s *MemoryUserStore) FindUser(ctx context.Context, username string, email string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range s.users {
+		if user.Username == username || user.Email == email {
+			return user, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+func (s *MemoryUserStore) Verify(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, user := range s.users {
+		if user.VerifiedToken == token {
+			user.Verified = true
+			s.users[userID] = user
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+func (s *MemoryUserStore) SetResetToken(ctx context.Context, email string, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, user := range s.users {
+		if user.Email == email {
+			s.resetTokens[userID] = token
+			return nil
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (s *MemoryUserStore) ResetPassword(ctx context.Context, username string, email string, token string, hashedPassword string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, user := range s.users {
+		if user.Username == username && user.Email == email && token != "" && s.resetTokens[userID] == token {
+			user.HashedPassword = hashedPassword
+			s.users[userID] = user
+			delete(s.resetTokens, userID)
+			return nil
+		}
+	}
+	return ErrTokenNotFound
+}
+
+func (s *MemoryUserStore) Username(ctx context.Context, userID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[userID]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+	return user.Username, nil
+}
+
+func (s *MemoryUserStore) UserID(ctx context.Context, username string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, user := range s.users {
+		if user.Username == username {
+			return userID, nil
+		}
+	}
+	return "", ErrUserNotFound
+}
+
+func (s *MemoryUserStore) Credentials(ctx context.Context, userID string) ([]webauthn.Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]webauthn.Credential{}, s.credentials[userID]...), nil
+}
+
+func (s *MemoryUserStore) AddCredential(ctx context.Context, userID string, credential webauthn.Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials[userID] = append(s.credentials[userID], credential)
+	return nil
+}
+
+func (s *MemoryUserStore) UpdateSignCount(ctx context.Context, credentialID []byte, signCount uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, credentials := range s.credentials {
+		for i := range credentials {
+			if string(credentials[i].ID) == string(credentialID) {
+				credentials[i].Authenticator.SignCount = signCount
+				return nil
+			}
+		}
+	}
+	return errors.New("credential not found")
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+//the business metrics of auth-service, the HTTP and database ones come from the metrics package
+var (
+	signups = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bearchat_signups_total",
+		Help: "Accounts created.",
+	})
+
+	signins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_signins_total",
+		Help: "Sign in attempts by method (password or passkey) and outcome.",
+	}, []string{"method", "outcome"})
+
+	emailsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_emails_sent_total",
+		Help: "Emails handed to the mailer by template and outcome.",
+	}, []string{"template", "outcome"})
+)
+
+//sendEmail sends through the mailer and counts the email
+func sendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error {
+	err := mailer.SendEmail(recipient, subject, templatePath, data)
+	outcome := "sent"
+	if err != nil {
+		outcome = "failed"
+	}
+	emailsSent.WithLabelValues(templatePath, outcome).Inc()
+	return err
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/gorilla/mux"
+)
+
+const (
+	passkeySessionCookie = "passkey_session"
+	passkeySessionExpiry = 5 * time.Minute
+)
+
+var (
+	webAuthn *webauthn.WebAuthn
+	sessions = passkeySessions{sessions: make(map[string]passkeySession)}
+)
+
+//initWebAuthn configures the relying party used for passkey ceremonies
+func initWebAuthn(cfg Config) error {
+	var err error
+	webAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.WebAuthnRPName,
+		RPOrigins:     cfg.WebAuthnOrigins,
+	})
+	return err
+}
+
+func registerPasskeyRoutes(router *mux.Router) {
+	router.HandleFunc("/api/auth/passkey/register/begin", beginPasskeyRegistration).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/api/auth/passkey/register/finish", finishPasskeyRegistration).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/api/auth/passkey/login/begin", beginPasskeyLogin).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/api/auth/passkey/login/finish", finishPasskeyLogin).Methods(http.MethodPost, http.MethodOptions)
+}
+
+//passkeyUser adapts a BearChat user to the webauthn.User interface
+type passkeyUser struct {
+	id          string
+	name        string
+	credentials []webauthn.Credential
+}
+
+func (u passkeyUser) WebAuthnID() []byte                         { return []byte(u.id) }
+func (u passkeyUser) WebAuthnName() string                       { return u.name }
+func (u passkeyUser) WebAuthnDisplayName() string                { return u.name }
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+func loadPasskeyUser(ctx context.Context, userID string) (passkeyUser, error) {
+	username, err := passkeys.Username(ctx, userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	credentials, err := passkeys.Credentials(ctx, userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	return passkeyUser{id: userID, name: username, credentials: credentials}, nil
+}
+
+//passkeySession is the server side state kept between the begin and finish step of a ceremony
+type passkeySession struct {
+	userID    string
+	data      webauthn.SessionData
+	expiresAt time.Time
+}
+
+type passkeySessions struct {
+	mu       sync.Mutex
+	sessions map[string]passkeySession
+}
+
+//start stores the ceremony state and hands the browser a cookie pointing at it
+func (s *passkeySessions) start(w http.ResponseWriter, userID string, data *webauthn.SessionData) error {
+	raw := make([]byte, 32)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return err
+	}
+	id := base64.RawURLEncoding.EncodeToString(raw)
+	expiresAt := time.Now().Add(passkeySessionExpiry)
+
+	s.mu.Lock()
+	for key, session := range s.sessions {
+		if time.Now().After(session.expiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[id] = passkeySession{userID: userID, data: *data, expiresAt: expiresAt}
+	s.mu.Unlock()
+
+	http.SetCookie(w, cookieAttributes.Apply(&http.Cookie{
+		Name:    passkeySessionCookie,
+		Value:   id,
+		Expires: expiresAt,
+		Path:    "/api/auth/passkey",
+	}))
+	return nil
+}
+
+//finish removes and returns the ceremony state, so every challenge can only be answered once
+func (s *passkeySessions) finish(r *http.Request) (passkeySession, error) {
+	cookie, err := r.Cookie(passkeySessionCookie)
+	if err != nil {
+		return passkeySession{}, errors.New("missing passkey session")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[cookie.Value]
+	delete(s.sessions, cookie.Value)
+	if !ok || time.Now().After(session.expiresAt) {
+		return passkeySession{}, errors.New("passkey session expired")
+	}
+	return session, nil
+}
+
+func beginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	//Only signed in users can attach a passkey to their account
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated, "sign in to register a passkey")
+		return
+	}
+	claims, err := getClaims(cookie.Value)
+	if err != nil {
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "invalid or expired access token")
+		return
+	}
+
+	user, err := loadPasskeyUser(r.Context(), claims.UserID)
+	if err == ErrUserNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodeUserNotFound, "this user does not exist")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error loading user", err)
+		return
+	}
+
+	//Exclude passkeys the user already registered so authenticators don't create duplicates
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, credential := range user.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	options, data, err := webAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		problem.Internal(w, r, "error starting passkey registration", err)
+		return
+	}
+
+	err = sessions.start(w, user.id, data)
+	if err != nil {
+		problem.Internal(w, r, "error starting passkey session", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+func finishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	//webauthn reads the body itself
+	r.Body = http.MaxBytesReader(w, r.Body, problem.MaxBodyBytes)
+	session, err := sessions.finish(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, CodePasskeySession, err.Error())
+		return
+	}
+
+	user, err := loadPasskeyUser(r.Context(), session.userID)
+	if err != nil {
+		problem.Internal(w, r, "error loading user", err)
+		return
+	}
+
+	credential, err := webAuthn.FinishRegistration(user, session.data, r)
+	if err != nil {
+		slog.WarnContext(r.Context(), "passkey registration failed", "err", err)
+		problem.Error(w, r, http.StatusBadRequest, CodePasskeyFailed, "passkey registration failed")
+		return
+	}
+
+	err = passkeys.AddCredential(r.Context(), user.id, *credential)
+	if err != nil {
+		problem.Internal(w, r, "error storing passkey", err)
+		return
+	}
+
+	w.WriteHeader(201)
+}
+
+func beginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	//The username is optional, without it the browser offers any discoverable passkey for this site
+	credential := Credentials{}
+	if r.ContentLength != 0 {
+		if !validate.DecodeJSON(w, r, &credential) {
+			return
+		}
+	}
+
+	var (
+		userID  string
+		options *protocol.CredentialAssertion
+		data    *webauthn.SessionData
+		err     error
+	)
+	if credential.Username == "" {
+		options, data, err = webAuthn.BeginDiscoverableLogin()
+	} else {
+		userID, err = passkeys.UserID(r.Context(), credential.Username)
+		if err == ErrUserNotFound {
+			problem.Error(w, r, http.StatusNotFound, CodeUserNotFound, "this username is not associated with an account")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, "error loading user", err)
+			return
+		}
+
+		var user passkeyUser
+		user, err = loadPasskeyUser(r.Context(), userID)
+		if err == nil && len(user.credentials) == 0 {
+			problem.Error(w, r, http.StatusNotFound, CodeNoPasskeys, "this account has no passkeys")
+			return
+		}
+		if err == nil {
+			options, data, err = webAuthn.BeginLogin(user)
+		}
+	}
+	if err != nil {
+		problem.Internal(w, r, "error starting passkey login", err)
+		return
+	}
+
+	err = sessions.start(w, userID, data)
+	if err != nil {
+		problem.Internal(w, r, "error starting passkey session", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(options)
+}
+
+func finishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	//webauthn reads the body itself
+	r.Body = http.MaxBytesReader(w, r.Body, problem.MaxBodyBytes)
+	session, err := sessions.finish(r)
+	if err != nil {
+		problem.Error(w, r, http.StatusBadRequest, CodePasskeySession, err.Error())
+		return
+	}
+
+	var (
+		userID     = session.userID
+		credential *webauthn.Credential
+	)
+	if userID == "" {
+		//Discoverable login: the authenticator tells us who the user is through the user handle
+		var user webauthn.User
+		user, credential, err = webAuthn.FinishPasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			return loadPasskeyUser(r.Context(), string(userHandle))
+		}, session.data, r)
+		if err == nil {
+			userID = string(user.WebAuthnID())
+		}
+	} else {
+		var user passkeyUser
+		user, err = loadPasskeyUser(r.Context(), userID)
+		if err == nil {
+			credential, err = webAuthn.FinishLogin(user, session.data, r)
+		}
+	}
+	if err != nil {
+		slog.WarnContext(r.Context(), "passkey login failed", "err", err)
+		problem.Error(w, r, http.StatusUnauthorized, CodePasskeyFailed, "passkey login failed")
+		signins.WithLabelValues("passkey", "failed").Inc()
+		return
+	}
+
+	//A counter that didn't move forward means the private key may have been cloned
+	if credential.Authenticator.CloneWarning {
+		problem.Error(w, r, http.StatusUnauthorized, CodePasskeyCloned, "passkey signature counter did not increase")
+		signins.WithLabelValues("passkey", "cloned").Inc()
+		return
+	}
+
+	err = passkeys.UpdateSignCount(r.Context(), credential.ID, credential.Authenticator.SignCount)
+	if err != nil {
+		problem.Internal(w, r, "error updating passkey", err)
+		signins.WithLabelValues("passkey", "error").Inc()
+		return
+	}
+
+	err = setAuthCookies(w, userID)
+	if err != nil {
+		problem.Internal(w, r, "error in generating tokens", err)
+		signins.WithLabelValues("passkey", "error").Inc()
+		return
+	}
+	signins.WithLabelValues("passkey", "success").Inc()
+	w.WriteHeader(200)
+}
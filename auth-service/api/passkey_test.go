@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/descope/virtualwebauthn"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	testUserID   = "5b1c9a36-5f0e-4c1b-9a55-8f7d3c1f4b2e"
+	testUsername = "oski"
+)
+
+var testRP = virtualwebauthn.RelyingParty{Name: "BearChat", ID: "localhost", Origin: "http://localhost:3000"}
+
+//newPasskeyServer starts the passkey routes against an in-memory store with a signed in client
+func newPasskeyServer(t *testing.T) (*httptest.Server, *http.Client, *MemoryUserStore) {
+	err := initWebAuthn(Config{
+		WebAuthnRPID:    testRP.ID,
+		WebAuthnRPName:  testRP.Name,
+		WebAuthnOrigins: []string{testRP.Origin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewMemoryUserStore()
+	store.CreateUser(context.Background(), User{UserID: testUserID, Username: testUsername, Email: "oski@berkeley.edu"})
+	previous := passkeys
+	passkeys = store
+	t.Cleanup(func() { passkeys = previous })
+
+	router := mux.NewRouter()
+	registerPasskeyRoutes(router)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Jar: jar}
+
+	accessToken, err := setClaims(AuthClaims{
+		UserID: testUserID,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   "access",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			Issuer:    defaultJWTIssuer,
+			IssuedAt:  time.Now().Unix(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverURL, _ := url.Parse(server.URL)
+	jar.SetCookies(serverURL, []*http.Cookie{{Name: "access_token", Value: accessToken, Path: "/"}})
+
+	return server, client, store
+}
+
+func post(t *testing.T, client *http.Client, url string, body string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(respBody)
+}
+
+//registerPasskey runs a full registration ceremony and returns the authenticator holding the new credential
+func registerPasskey(t *testing.T, server *httptest.Server, client *http.Client) (virtualwebauthn.Authenticator, virtualwebauthn.Credential) {
+	t.Helper()
+	resp, body := post(t, client, server.URL+"/api/auth/passkey/register/begin", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("register begin: expected 200 but was %d: %s", resp.StatusCode, body)
+	}
+	options, err := virtualwebauthn.ParseAttestationOptions(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.UserID != testUserID || options.UserName != testUsername {
+		t.Fatalf("unexpected user in attestation options: %q %q", options.UserID, options.UserName)
+	}
+
+	authenticator := virtualwebauthn.NewAuthenticator()
+	credential := virtualwebauthn.NewCredential(virtualwebauthn.KeyTypeEC2)
+	attestation := virtualwebauthn.CreateAttestationResponse(testRP, authenticator, credential, *options)
+
+	resp, body = post(t, client, server.URL+"/api/auth/passkey/register/finish", attestation)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("register finish: expected 201 but was %d: %s", resp.StatusCode, body)
+	}
+
+	authenticator.Options.UserHandle = []byte(testUserID)
+	authenticator.AddCredential(credential)
+	return authenticator, credential
+}
+
+//login runs a login ceremony and returns the finish response
+func login(t *testing.T, server *httptest.Server, client *http.Client, authenticator virtualwebauthn.Authenticator, credential virtualwebauthn.Credential, beginBody string) *http.Response {
+	t.Helper()
+	resp, body := post(t, client, server.URL+"/api/auth/passkey/login/begin", beginBody)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login begin: expected 200 but was %d: %s", resp.StatusCode, body)
+	}
+	options, err := virtualwebauthn.ParseAssertionOptions(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion := virtualwebauthn.CreateAssertionResponse(testRP, authenticator, credential, *options)
+
+	resp, _ = post(t, client, server.URL+"/api/auth/passkey/login/finish", assertion)
+	return resp
+}
+
+func accessTokenUserID(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "access_token" {
+			claims, err := getClaims(cookie.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return claims.UserID
+		}
+	}
+	t.Fatal("access_token cookie not set")
+	return ""
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	server, client, store := newPasskeyServer(t)
+	authenticator, credential := registerPasskey(t, server, client)
+
+	stored, _ := store.Credentials(context.Background(), testUserID)
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 stored passkey but got %d", len(stored))
+	}
+
+	credential.Counter = 7
+	resp := login(t, server, client, authenticator, credential, `{"username":"oski"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login finish: expected 200 but was %d", resp.StatusCode)
+	}
+	if userID := accessTokenUserID(t, resp); userID != testUserID {
+		t.Fatalf("expected access token for %s but got %s", testUserID, userID)
+	}
+
+	stored, _ = store.Credentials(context.Background(), testUserID)
+	if stored[0].Authenticator.SignCount != 7 {
+		t.Fatalf("expected signCount 7 but was %d", stored[0].Authenticator.SignCount)
+	}
+}
+
+func TestPasskeyDiscoverableLogin(t *testing.T) {
+	server, client, _ := newPasskeyServer(t)
+	authenticator, credential := registerPasskey(t, server, client)
+
+	resp := login(t, server, client, authenticator, credential, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login finish: expected 200 but was %d", resp.StatusCode)
+	}
+	if userID := accessTokenUserID(t, resp); userID != testUserID {
+		t.Fatalf("expected access token for %s but got %s", testUserID, userID)
+	}
+}
+
+func TestPasskeyLoginRejectsStaleSignCount(t *testing.T) {
+	server, client, _ := newPasskeyServer(t)
+	authenticator, credential := registerPasskey(t, server, client)
+
+	credential.Counter = 10
+	resp := login(t, server, client, authenticator, credential, `{"username":"oski"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("login finish: expected 200 but was %d", resp.StatusCode)
+	}
+
+	//A cloned authenticator would replay an older counter value
+	credential.Counter = 3
+	resp = login(t, server, client, authenticator, credential, `{"username":"oski"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a stale signCount but was %d", resp.StatusCode)
+	}
+}
+
+func TestPasskeyLoginWithUnknownCredential(t *testing.T) {
+	server, client, _ := newPasskeyServer(t)
+	authenticator, _ := registerPasskey(t, server, client)
+
+	other := virtualwebauthn.NewCredential(virtualwebauthn.KeyTypeEC2)
+	resp := login(t, server, client, authenticator, other, "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unregistered passkey but was %d", resp.StatusCode)
+	}
+}
+
+func TestPasskeySessionIsSingleUse(t *testing.T) {
+	server, client, _ := newPasskeyServer(t)
+
+	resp, _ := post(t, client, server.URL+"/api/auth/passkey/register/finish", "{}")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a passkey session but was %d", resp.StatusCode)
+	}
+
+	resp, _ = post(t, client, server.URL+"/api/auth/passkey/login/begin", `{"username":"nobody"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown username but was %d", resp.StatusCode)
+	}
+}
+
+func TestPasskeyRegistrationRequiresSignIn(t *testing.T) {
+	server, _, _ := newPasskeyServer(t)
+
+	resp, _ := post(t, http.DefaultClient, server.URL+"/api/auth/passkey/register/begin", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+}
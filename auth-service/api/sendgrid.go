@@ -2,6 +2,9 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"html/template"
 	"os"
 
@@ -10,17 +13,50 @@ import (
 )
 
 var (
-	sendgridKey    string
 	sendgridClient *sendgrid.Client
-	defaultSender  = mail.NewEmail("Cloud9 Test", "maxmir@berkeley.edu")
+	defaultSender  *mail.Email
 	defaultScheme  = "http"
 )
 
-//InitMailer initalizes the sendgrid client
-func InitMailer() {
-	// load environmental variables
-	sendgridKey = os.Getenv("SENDGRID_KEY")
-	sendgridClient = sendgrid.NewSendClient(sendgridKey)
+//initMailer initalizes the sendgrid client
+func initMailer(cfg Config) {
+	sendgridClient = sendgrid.NewSendClient(cfg.SendgridKey)
+	defaultSender = mail.NewEmail(cfg.MailSenderName, cfg.MailSender)
+}
+
+//templates are the email templates the handlers send, relative to the working directory
+var templates = []string{"user-signup.html", "password-reset.html"}
+
+//CheckMailer is the readiness check of the sendgrid mailer, it needs a sender and every template
+func CheckMailer(ctx context.Context) error {
+	if sendgridClient == nil || defaultSender == nil || defaultSender.Address == "" {
+		return errors.New("the mailer isn't configured")
+	}
+	for _, name := range templates {
+		_, err := os.Stat("./api/templates/" + name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SendgridMailer is the Mailer sending through the sendgrid client set up by Configure
+type SendgridMailer struct{}
+
+//SendEmail sends the email through sendgrid
+func (SendgridMailer) SendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error {
+	return SendEmail(recipient, subject, templatePath, data)
+}
+
+//LogMailer is the Mailer of local development, it prints the emails to stdout instead of sending them.
+//The logs redact tokens and addresses, so the emails are printed next to them rather than through them.
+type LogMailer struct{}
+
+//SendEmail prints the email with its template data, which holds the verification or reset token
+func (LogMailer) SendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error {
+	_, err := fmt.Fprintf(os.Stdout, "email to %s: %s (%s) %v\n", recipient, subject, templatePath, data)
+	return err
 }
 
 //SendEmail sends an email to the recipient with the specified subject
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+var (
+	//ErrUserNotFound is returned by a store when the requested user does not exist
+	ErrUserNotFound = errors.New("user not found")
+	//ErrTokenNotFound is returned by a UserStore when no user holds the given verification or reset token
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+//User is an account of the users table
+type User struct {
+	UserID         string
+	Username       string
+	Email          string
+	HashedPassword string
+	Verified       bool
+	VerifiedToken  string
+}
+
+//UserStore persists the accounts managed by auth-service
+type UserStore interface {
+	//UsernameExists reports whether username is taken
+	UsernameExists(ctx context.Context, username string) (bool, error)
+	//EmailExists reports whether email is taken
+	EmailExists(ctx context.Context, email string) (bool, error)
+	//CreateUser stores a new account
+	CreateUser(ctx context.Context, user User) error
+	//FindUser returns the user matching either username or email
+	FindUser(ctx context.Context, username string, email string) (User, error)
+	//Verify marks the user holding the verification token as verified
+	Verify(ctx context.Context, token string) error
+	//SetResetToken stores a password reset token for the user with email
+	SetResetToken(ctx context.Context, email string, token string) error
+	//ResetPassword replaces the password of the user if username, email and reset token match, and clears the token
+	ResetPassword(ctx context.Context, username string, email string, token string, hashedPassword string) error
+
+	PasskeyStore
+}
+
+//PasskeyStore persists the WebAuthn credentials registered by each user
+type PasskeyStore interface {
+	//Username returns the username belonging to userID
+	Username(ctx context.Context, userID string) (string, error)
+	//UserID returns the userID belonging to username
+	UserID(ctx context.Context, username string) (string, error)
+	//Credentials returns every passkey registered by userID
+	Credentials(ctx context.Context, userID string) ([]webauthn.Credential, error)
+	//AddCredential stores a newly registered passkey for userID
+	AddCredential(ctx context.Context, userID string, credential webauthn.Credential) error
+	//UpdateSignCount records the signature counter reported by the last successful login
+	UpdateSignCount(ctx context.Context, credentialID []byte, signCount uint32) error
+}
+
+//Mailer sends the verification and password reset emails
+type Mailer interface {
+	SendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error
+}
+
+//the stores used by the handlers, set by RegisterRoutes
+var (
+	users    UserStore
+	passkeys PasskeyStore
+	mailer   Mailer
+)
@@ -1,2 +1,2 @@
-docker build -t auth-service .
+docker build -t auth-service -f Dockerfile ..
 docker run -p 80:80 auth-service
\ No newline at end of file
@@ -1,44 +1,109 @@
 package main
 
 import (
+	"expvar"
 	"log"
-	"net/http"
-	_ "net/http"
+	"os"
 
 	"github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+	"github.com/BearCloud/fa20-project-dev/backend/auth-service/migrations"
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/migrate"
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 )
 
 func main() {
 
-	err := godotenv.Load()
+	//Load the configuration from the environment, .env and CONFIG_FILE
+	cfg := api.Config{}
+	config.MustLoad("auth-service", &cfg)
+
+	//Initialize the sendgrid client, tokens, cookies and passkeys
+	err := api.Configure(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	//SIGTERM stops the service, even while it waits for the database
+	ctx, stop := server.SignalContext()
+	defer stop()
+
+	//answer health probes while connecting, the api is served once it's ready
+	checker := health.New("auth-service")
+	srv := server.New(cfg.ListenAddr, checker, cfg.Server)
+
+	//export the spans of TRACING_EXPORTER, the last ones once everything else has stopped
+	flushTraces, err := tracing.Setup(ctx, "auth-service", cfg.Trace)
 	if err != nil {
 		log.Fatal(err.Error())
 	}
+	srv.OnShutdown("tracing", flushTraces)
+	migrating := len(os.Args) > 1 && os.Args[1] == "migrate"
+	if !migrating {
+		ctx = srv.Start(ctx)
+	}
 
-	//Initialize the sendgrid client
-	api.InitMailer()
+	//Connect to the database, retrying with backoff until it's up
+	DB, err := api.InitDB(ctx, cfg.DatabaseDSN, cfg.Settings)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
-	//Initialize our database connection
-	DB := api.InitDB()
-	defer DB.Close()
+	//"main migrate up|down [steps]|status" manages the schema and exits
+	if migrating {
+		err = migrate.Command(DB, migrations.FS, os.Args[2:])
+		DB.Close()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
 
-	//ping the database to make sure it's up
-	err = DB.Ping()
+	//bring the schema up to date before serving requests
+	err = migrate.Up(DB, migrations.FS)
 	if err != nil {
-		log.Println("pinging database")
-		panic(err.Error())
+		log.Fatal(err.Error())
 	}
+
 	// Create a new mux for routing api calls
 	router := mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(cfg.CORSOrigins, "GET, POST, OPTIONS"))
+	router.Use(api.CSRF)
+
+	//pool statistics are published through expvar, the Prometheus metrics on /metrics
+	router.Handle("/debug/vars", expvar.Handler())
+	router.Handle("/metrics", metrics.Handler())
 
-	err = api.RegisterRoutes(router)
+	//the specification of the API on /openapi.yaml and /openapi.json
+	openapi.Routes(router, api.OpenAPI)
+
+	err = api.RegisterRoutes(router, api.NewMySQLUserStore(DB), api.SendgridMailer{})
 	if err != nil {
 		log.Fatal("Error registering API endpoints")
 	}
 
+	//the pool is closed once the requests in flight are done
+	srv.OnShutdown("database", DB.Close)
+	checker.AddCheck("mysql", DB.PingContext)
+	checker.AddCheck("mailer", api.CheckMailer)
+	checker.Ready(router)
+
 	log.Println("starting go server")
-	http.ListenAndServe(":80", router)
+	err = srv.Run(ctx)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 
-}
\ No newline at end of file
+}
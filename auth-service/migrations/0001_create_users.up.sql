@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR(20),
+    email VARCHAR(320),
+    hashedPassword TEXT,
+    verified boolean,
+    resetToken TEXT,
+    verifiedToken TEXT,
+    userId VARCHAR(128) PRIMARY KEY
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS passkeys (
+    credentialID VARBINARY(1023) PRIMARY KEY,
+    userId VARCHAR(128),
+    credential TEXT,
+    signCount INT UNSIGNED,
+    createdAt DATETIME,
+    lastUsedAt DATETIME,
+    INDEX (userId)
+);
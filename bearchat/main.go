@@ -0,0 +1,159 @@
+// Command bearchat runs every BearChat API in a single process for local
+// development.
+//
+// The auth, posts, profile and friends routes are mounted on one server, with
+// their OpenAPI specifications on /openapi/{auth,posts,profiles,friends}.yaml, and
+// keep their data in the in-memory stores and friends graph, so neither MySQL
+// nor Neptune is needed. Emails are written to the log instead of being sent.
+// Everything is lost when the process exits.
+//
+//	cd bearchat && LISTEN_ADDR=:8080 go run .
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+
+	authapi "github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+	friendsapi "github.com/BearCloud/fa20-project-dev/backend/friends/api"
+	postsapi "github.com/BearCloud/fa20-project-dev/backend/posts/api"
+	profilesapi "github.com/BearCloud/fa20-project-dev/backend/profile/api"
+	"github.com/gorilla/mux"
+)
+
+//Config holds the settings of the all-in-one server itself, every API loads its own config
+//from the same environment so the service defaults and checks apply
+type Config struct {
+	config.Common `yaml:",inline"`
+}
+
+//unused are the required settings of services that bearchat replaces by a log or memory
+var unused = map[string]string{
+	"SENDGRID_KEY": "unused",
+	"NEPTUNE_URL":  "http://unused",
+}
+
+func main() {
+	//Load the configuration from the environment, .env and CONFIG_FILE
+	cfg := Config{}
+	config.MustLoad("bearchat", &cfg)
+	if cfg.Production() {
+		log.Fatal("bearchat keeps everything in memory and is meant for development only")
+	}
+
+	//emails are logged and the graph is in memory, so nobody has to set these
+	for name, value := range unused {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+	authCfg := authapi.Config{}
+	postsCfg := postsapi.Config{}
+	profilesCfg := profilesapi.Config{}
+	friendsCfg := friendsapi.Config{}
+	for _, serviceCfg := range []interface{}{&authCfg, &postsCfg, &profilesCfg, &friendsCfg} {
+		err := config.Load(serviceCfg)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+
+	err := authapi.Configure(authCfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	postsapi.Configure(postsCfg)
+	profilesapi.Configure(profilesCfg)
+	friendsapi.Configure(friendsCfg)
+
+	// Create a single mux for the routes of every service
+	router := mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(cfg.CORSOrigins, "GET, POST, PUT, DELETE, OPTIONS"))
+	router.Use(authapi.CSRF)
+
+	//Prometheus metrics
+	router.Handle("/metrics", metrics.Handler())
+
+	//the specifications of the four APIs, e.g. /openapi/auth.yaml or /openapi/posts.json
+	specs := map[string][]byte{
+		"auth":     authapi.OpenAPI,
+		"posts":    postsapi.OpenAPI,
+		"profiles": profilesapi.OpenAPI,
+		"friends":  friendsapi.OpenAPI,
+	}
+	for name, spec := range specs {
+		handler := openapi.Handler(spec)
+		router.Handle("/openapi/"+name+".yaml", handler).Methods(http.MethodGet)
+		router.Handle("/openapi/"+name+".json", handler).Methods(http.MethodGet)
+	}
+
+	err = authapi.RegisterRoutes(router, authapi.NewMemoryUserStore(), authapi.LogMailer{})
+	if err != nil {
+		log.Fatal("Error registering auth endpoints")
+	}
+	//the home feed reads the friends graph directly, there is no friends-service to call
+	graph := friendsapi.NewMemoryGraph()
+	friendsOf := postsapi.FriendsFunc(func(ctx context.Context, userID string, accessToken string) ([]string, error) {
+		return graph.Friends(ctx, userID)
+	})
+	//and the authors of the posts are read from the profile store
+	profileStore := profilesapi.NewMemoryProfileStore()
+	authors := postsapi.AuthorsFunc(func(ctx context.Context, userID string) (postsapi.Author, error) {
+		profile, err := profileStore.GetProfile(ctx, userID)
+		if err == profilesapi.ErrProfileNotFound {
+			return postsapi.Author{UUID: userID}, nil
+		}
+		if err != nil {
+			return postsapi.Author{}, err
+		}
+		return postsapi.Author{UUID: userID, FirstName: profile.Firstname, LastName: profile.Lastname}, nil
+	})
+	err = postsapi.RegisterRoutes(router, postsapi.NewMemoryPostStore(), friendsOf, nil, authors)
+	if err != nil {
+		log.Fatal("Error registering posts endpoints")
+	}
+	err = profilesapi.RegisterRoutes(router, profileStore)
+	if err != nil {
+		log.Fatal("Error registering profile endpoints")
+	}
+	err = friendsapi.RegisterRoutes(router, graph)
+	if err != nil {
+		log.Fatal("Error registering friends endpoints")
+	}
+
+	checker := health.New("bearchat")
+	checker.Ready(router)
+
+	log.Printf("bearchat listening on %s, data is kept in memory", cfg.ListenAddr)
+	//nothing balances across a development server, stop right away
+	ctx, stop := server.SignalContext()
+	defer stop()
+	cfg.Server.DrainDelay = 0
+	srv := server.New(cfg.ListenAddr, checker, cfg.Server)
+
+	//export the spans of TRACING_EXPORTER, the last ones once everything else has stopped
+	flushTraces, err := tracing.Setup(ctx, "bearchat", cfg.Trace)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	srv.OnShutdown("tracing", flushTraces)
+	err = srv.Run(srv.Start(ctx))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
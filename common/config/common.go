@@ -0,0 +1,97 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+)
+
+const (
+	developmentJWTSecret  = "my_secret_key"
+	developmentCSRFSecret = "bearchat-development-csrf-secret"
+)
+
+//Common holds the settings shared by every service, embed it in the service's config
+type Common struct {
+	Env         string   `env:"BEARCHAT_ENV" yaml:"env" default:"development"`
+	ListenAddr  string   `env:"LISTEN_ADDR" yaml:"listen_addr" default:":80"`
+	JWTSecret   string   `env:"JWT_SECRET" yaml:"jwt_secret" default:"my_secret_key" required:"true" secret:"true"`
+	CSRFSecret  string   `env:"CSRF_SECRET" yaml:"csrf_secret" default:"bearchat-development-csrf-secret" required:"true" secret:"true"`
+	CORSOrigins []string `env:"CORS_ORIGINS" yaml:"cors_origins" default:"http://localhost:3000"`
+
+	//The cookie settings override the defaults of the environment when set
+	CookieSecure   string `env:"COOKIE_SECURE" yaml:"cookie_secure"`
+	CookieHTTPOnly string `env:"COOKIE_HTTPONLY" yaml:"cookie_httponly"`
+	CookieSameSite string `env:"COOKIE_SAMESITE" yaml:"cookie_samesite"`
+	CookieDomain   string `env:"COOKIE_DOMAIN" yaml:"cookie_domain"`
+
+	//Server holds the timeouts of the HTTP server
+	Server server.Settings `yaml:",inline"`
+	//Log holds the level and format of the logs
+	Log logging.Settings `yaml:",inline"`
+	//Trace holds the exporter and sampling of the traces
+	Trace tracing.Settings `yaml:",inline"`
+}
+
+//Logging returns the log settings, MustLoad sets the logger up with them
+func (c Common) Logging() logging.Settings {
+	return c.Log
+}
+
+//Production reports whether the service runs in the production environment
+func (c Common) Production() bool {
+	return strings.EqualFold(c.Env, "production")
+}
+
+//Cookies returns the cookie attributes of the environment with the cookie overrides applied
+func (c Common) Cookies() cookies.Attributes {
+	attributes := cookies.Development
+	if c.Production() {
+		attributes = cookies.Production
+	}
+	if secure, err := strconv.ParseBool(c.CookieSecure); err == nil {
+		attributes.Secure = secure
+	}
+	if httpOnly, err := strconv.ParseBool(c.CookieHTTPOnly); err == nil {
+		attributes.HttpOnly = httpOnly
+	}
+	if c.CookieSameSite != "" {
+		attributes.SameSite, _ = cookies.ParseSameSite(c.CookieSameSite)
+	}
+	attributes.Domain = c.CookieDomain
+	return attributes
+}
+
+//Validate refuses cookie overrides browsers wouldn't take as meant, and to run production with
+//the development secrets
+func (c Common) Validate() error {
+	for _, flag := range []struct{ name, value string }{{"COOKIE_SECURE", c.CookieSecure}, {"COOKIE_HTTPONLY", c.CookieHTTPOnly}} {
+		if _, err := strconv.ParseBool(flag.value); flag.value != "" && err != nil {
+			return fmt.Errorf("config: %s must be true or false, not %q", flag.name, flag.value)
+		}
+	}
+	if _, err := cookies.ParseSameSite(c.CookieSameSite); err != nil {
+		return fmt.Errorf("config: COOKIE_SAMESITE: %v", err)
+	}
+	//browsers drop SameSite=None cookies that aren't Secure
+	if attributes := c.Cookies(); attributes.SameSite == http.SameSiteNoneMode && !attributes.Secure {
+		return errors.New("config: COOKIE_SAMESITE=none needs secure cookies, set COOKIE_SECURE=true")
+	}
+	if !c.Production() {
+		return nil
+	}
+	if c.JWTSecret == developmentJWTSecret {
+		return errors.New("config: JWT_SECRET must be changed in production")
+	}
+	if c.CSRFSecret == developmentCSRFSecret {
+		return errors.New("config: CSRF_SECRET must be changed in production")
+	}
+	return nil
+}
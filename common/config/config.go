@@ -0,0 +1,248 @@
+// Package config loads the settings of a BearChat service.
+//
+// Every service describes its settings as a struct whose fields carry tags:
+//
+//	env:"DATABASE_DSN"   the environment variable (and .env key) of the field
+//	yaml:"database_dsn"  the key in the optional YAML file
+//	default:"..."        the value used when nothing else sets the field
+//	required:"true"      Load fails when the field is still empty
+//	secret:"true"        the value is masked when printed, "dsn" only masks the password
+//
+// Values are applied in order: defaults, the YAML file named by CONFIG_FILE,
+// then the environment, which includes anything loaded from .env (or ENV_FILE).
+// Embedded structs such as Common are walked as well.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+//validator is implemented by configs that need checks beyond required fields
+type validator interface {
+	Validate() error
+}
+
+//Load fills target, a pointer to a config struct, and validates it
+func Load(target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return errors.New("config: target must be a pointer to a struct")
+	}
+
+	err := loadDotEnv()
+	if err != nil {
+		return err
+	}
+
+	err = walk(value.Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if def, ok := field.Tag.Lookup("default"); ok {
+			return set(v, def)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("config: reading %s: %v", path, err)
+		}
+		err = yaml.Unmarshal(contents, target)
+		if err != nil {
+			return fmt.Errorf("config: parsing %s: %v", path, err)
+		}
+	}
+
+	err = walk(value.Elem(), func(field reflect.StructField, v reflect.Value) error {
+		name := field.Tag.Get("env")
+		if name == "" {
+			return nil
+		}
+		if env, ok := os.LookupEnv(name); ok {
+			err := set(v, env)
+			if err != nil {
+				return fmt.Errorf("config: %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	walk(value.Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if field.Tag.Get("required") == "true" && v.IsZero() {
+			missing = append(missing, name(field))
+		}
+		return nil
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("config: missing required settings: %s", strings.Join(missing, ", "))
+	}
+
+	if v, ok := target.(validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+//MustLoad is Load for main functions, it exits when the config is invalid. Otherwise it sets
+//up the logger of service when the config embeds Common and logs the effective config.
+func MustLoad(service string, target interface{}) {
+	err := Load(target)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	if c, ok := target.(interface{ Logging() logging.Settings }); ok {
+		err = logging.Setup(service, c.Logging())
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+	}
+	log.Printf("%s configuration:\n%s", service, Redacted(target))
+}
+
+//loadDotEnv loads ENV_FILE, or .env when it exists, without overriding the real environment
+func loadDotEnv() error {
+	path := os.Getenv("ENV_FILE")
+	if path == "" {
+		path = ".env"
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	err := godotenv.Load(path)
+	if err != nil {
+		return fmt.Errorf("config: loading %s: %v", path, err)
+	}
+	return nil
+}
+
+//Redacted renders the effective configuration one setting per line with secrets masked
+func Redacted(target interface{}) string {
+	var lines []string
+	walk(reflect.ValueOf(target).Elem(), func(field reflect.StructField, v reflect.Value) error {
+		lines = append(lines, fmt.Sprintf("  %s=%s", name(field), redact(field.Tag.Get("secret"), format(v))))
+		return nil
+	})
+	return strings.Join(lines, "\n")
+}
+
+func redact(secret string, value string) string {
+	switch {
+	case value == "":
+		return value
+	case secret == "dsn":
+		//user:password@tcp(host)/db keeps everything but the password
+		at := strings.LastIndex(value, "@")
+		colon := strings.Index(value, ":")
+		if at < 0 || colon < 0 || colon > at {
+			return value
+		}
+		return value[:colon+1] + "****" + value[at:]
+	case secret != "":
+		return "****"
+	}
+	return value
+}
+
+func name(field reflect.StructField) string {
+	if env := field.Tag.Get("env"); env != "" {
+		return env
+	}
+	return field.Name
+}
+
+//walk calls fn for every settable leaf field, descending into embedded and nested structs
+func walk(v reflect.Value, fn func(reflect.StructField, reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Time{}) {
+			err := walk(fv, fn)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := fn(field, fv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+func format(v reflect.Value) string {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		return time.Duration(v.Int()).String()
+	case v.Kind() == reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
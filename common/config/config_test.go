@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+//service is the config of a service with only the common settings
+type service struct {
+	Common `yaml:",inline"`
+}
+
+func TestLoadAppliesTheDefaultsAndTheEnvironment(t *testing.T) {
+	t.Setenv("LISTEN_ADDR", ":8080")
+	t.Setenv("CORS_ORIGINS", "http://a.example,http://b.example")
+	cfg := service{}
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ListenAddr != ":8080" || cfg.Env != "development" || len(cfg.CORSOrigins) != 2 || cfg.CORSOrigins[1] != "http://b.example" {
+		t.Fatalf("unexpected config %+v", cfg.Common)
+	}
+}
+
+func TestProductionRefusesTheDevelopmentSecrets(t *testing.T) {
+	t.Setenv("BEARCHAT_ENV", "production")
+	if err := Load(&service{}); err == nil || !strings.Contains(err.Error(), "JWT_SECRET") {
+		t.Fatalf("expected the development JWT secret to be refused but got %v", err)
+	}
+	t.Setenv("JWT_SECRET", "production-jwt-secret")
+	t.Setenv("CSRF_SECRET", "production-csrf-secret")
+	cfg := service{}
+	if err := Load(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if attributes := cfg.Cookies(); !attributes.Secure || !attributes.HttpOnly {
+		t.Fatalf("expected secure cookies in production but got %+v", attributes)
+	}
+}
+
+func TestLoadRejectsInvalidCookieSettings(t *testing.T) {
+	for _, value := range []string{"true", "0", ""} {
+		t.Setenv("COOKIE_SECURE", value)
+		if err := Load(&service{}); err != nil {
+			t.Fatalf("expected COOKIE_SECURE=%q to load but got %v", value, err)
+		}
+	}
+	t.Setenv("COOKIE_SECURE", "flase")
+	if err := Load(&service{}); err == nil || !strings.Contains(err.Error(), "COOKIE_SECURE") {
+		t.Fatalf("expected COOKIE_SECURE=flase to be rejected but got %v", err)
+	}
+	t.Setenv("COOKIE_SECURE", "")
+	t.Setenv("COOKIE_HTTPONLY", "yes")
+	if err := Load(&service{}); err == nil || !strings.Contains(err.Error(), "COOKIE_HTTPONLY") {
+		t.Fatalf("expected COOKIE_HTTPONLY=yes to be rejected but got %v", err)
+	}
+	t.Setenv("COOKIE_HTTPONLY", "")
+	for _, value := range []string{"lax", "Strict"} {
+		t.Setenv("COOKIE_SAMESITE", value)
+		if err := Load(&service{}); err != nil {
+			t.Fatalf("expected COOKIE_SAMESITE=%q to load but got %v", value, err)
+		}
+	}
+	t.Setenv("COOKIE_SAMESITE", "lox")
+	if err := Load(&service{}); err == nil || !strings.Contains(err.Error(), "COOKIE_SAMESITE") {
+		t.Fatalf("expected COOKIE_SAMESITE=lox to be rejected but got %v", err)
+	}
+	//browsers drop SameSite=None cookies over http
+	t.Setenv("COOKIE_SAMESITE", "none")
+	if err := Load(&service{}); err == nil || !strings.Contains(err.Error(), "COOKIE_SECURE") {
+		t.Fatalf("expected COOKIE_SAMESITE=none without secure cookies to be rejected but got %v", err)
+	}
+	t.Setenv("COOKIE_SECURE", "true")
+	cfg := service{}
+	if err := Load(&cfg); err != nil {
+		t.Fatalf("expected COOKIE_SAMESITE=none with secure cookies to load but got %v", err)
+	}
+	if attributes := cfg.Cookies(); !attributes.Secure {
+		t.Fatalf("expected COOKIE_SECURE to override the environment but got %+v", attributes)
+	}
+}
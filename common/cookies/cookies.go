@@ -0,0 +1,57 @@
+// Package cookies holds the cookie attributes shared by every BearChat service.
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//Attributes are the security related attributes applied to every cookie we set
+type Attributes struct {
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+//Development is the default, it works over plain http on localhost
+var Development = Attributes{
+	Secure:   false,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+//Production requires https for every cookie
+var Production = Attributes{
+	Secure:   true,
+	HttpOnly: true,
+	SameSite: http.SameSiteLaxMode,
+}
+
+//ParseSameSite converts "lax", "strict" or "none" into an http.SameSite, an empty value is lax
+func ParseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	}
+	return http.SameSiteLaxMode, fmt.Errorf("unknown SameSite %q, use lax, strict or none", value)
+}
+
+//Apply copies the attributes onto cookie and returns it, so it can wrap http.SetCookie calls
+func (a Attributes) Apply(cookie *http.Cookie) *http.Cookie {
+	cookie.Secure = a.Secure
+	cookie.HttpOnly = a.HttpOnly
+	cookie.SameSite = a.SameSite
+	if a.Domain != "" {
+		cookie.Domain = a.Domain
+	}
+	if cookie.Path == "" {
+		cookie.Path = "/"
+	}
+	return cookie
+}
@@ -0,0 +1,35 @@
+// Package cors implements the CORS policy shared by the BearChat services.
+package cors
+
+import "net/http"
+
+//Middleware allows credentialed requests using methods from the listed origins,
+//an origin of "*" allows every origin
+func Middleware(origins []string, methods string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Set headers
+			origin := r.Header.Get("Origin")
+			if origin != "" && (allowed[origin] || allowed["*"]) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, X-CSRF-Token")
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+			}
+			w.Header().Add("Vary", "Origin")
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			// Next
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Package csrf implements signed double-submit cookie protection for the
+// cookie-authenticated BearChat APIs.
+//
+// A token is issued by auth-service as the csrf_token cookie and in the
+// response body. For every state changing request that carries an
+// access_token or refresh_token cookie, the frontend must echo the token in
+// the X-CSRF-Token header. A cross-site attacker can make the browser send the
+// cookie but can neither read it nor forge the HMAC, so the header never
+// matches.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+const (
+	//CookieName is the cookie holding the CSRF token
+	CookieName = "csrf_token"
+	//HeaderName is the header the frontend echoes the token in
+	HeaderName = "X-CSRF-Token"
+
+	tokenExpiry = 24 * time.Hour
+)
+
+//authCookies are the cookies that authenticate a request, only those requests need a token
+var authCookies = []string{"access_token", "refresh_token"}
+
+//ErrInvalidToken is returned when the header and cookie tokens are missing, differ or are forged
+var ErrInvalidToken = errors.New("missing or invalid CSRF token")
+
+//Protector issues and verifies CSRF tokens
+type Protector struct {
+	secret     []byte
+	attributes cookies.Attributes
+}
+
+//New creates a Protector signing tokens with secret and setting the cookie with attributes
+func New(secret []byte, attributes cookies.Attributes) *Protector {
+	//the frontend has to read the token, so the cookie can never be HttpOnly
+	attributes.HttpOnly = false
+	return &Protector{secret: secret, attributes: attributes}
+}
+
+//NewToken returns a random token signed with the Protector's secret
+func (p *Protector) NewToken() (string, error) {
+	nonce := make([]byte, 32)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + p.sign(encoded), nil
+}
+
+func (p *Protector) sign(nonce string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//valid reports whether token was signed by this Protector
+func (p *Protector) valid(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(p.sign(parts[0])))
+}
+
+//Verify checks the double-submitted token of r
+func (p *Protector) Verify(r *http.Request) error {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	header := r.Header.Get(HeaderName)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie.Value)) != 1 {
+		return ErrInvalidToken
+	}
+	if !p.valid(header) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+//IssueToken is the handler that hands out a new token as a cookie and as JSON
+func (p *Protector) IssueToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	token, err := p.NewToken()
+	if err != nil {
+		problem.Internal(w, r, "error generating CSRF token", err)
+		return
+	}
+
+	http.SetCookie(w, p.attributes.Apply(&http.Cookie{
+		Name:    CookieName,
+		Value:   token,
+		Expires: time.Now().Add(tokenExpiry),
+		Path:    "/",
+	}))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"csrfToken": token})
+}
+
+//Protect is middleware rejecting cookie-authenticated, state changing requests without a valid token
+func (p *Protector) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if safeMethod(r.Method) || !cookieAuthenticated(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		err := p.Verify(r)
+		if err != nil {
+			problem.Error(w, r, http.StatusForbidden, problem.CodeCSRF, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func cookieAuthenticated(r *http.Request) bool {
+	for _, name := range authCookies {
+		if _, err := r.Cookie(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+)
+
+//request is a cookie-authenticated POST with the csrf cookie and header
+func request(cookie string, header string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/posts/create", nil)
+	r.AddCookie(&http.Cookie{Name: "access_token", Value: "token"})
+	if cookie != "" {
+		r.AddCookie(&http.Cookie{Name: CookieName, Value: cookie})
+	}
+	if header != "" {
+		r.Header.Set(HeaderName, header)
+	}
+	return r
+}
+
+func TestVerify(t *testing.T) {
+	p := New([]byte("secret"), cookies.Development)
+	token, err := p.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := p.NewToken()
+	forged, _ := New([]byte("another secret"), cookies.Development).NewToken()
+	nonce := strings.Split(token, ".")[0]
+
+	if err := p.Verify(request(token, token)); err != nil {
+		t.Fatalf("expected the token to be valid but got %v", err)
+	}
+	for name, r := range map[string]*http.Request{
+		"no cookie":         request("", token),
+		"no header":         request(token, ""),
+		"different tokens":  request(token, other),
+		"another secret":    request(forged, forged),
+		"unsigned nonce":    request(nonce, nonce),
+		"tampered nonce":    request("x"+token, "x"+token),
+		"too many segments": request(token+".x", token+".x"),
+	} {
+		if err := p.Verify(r); err != ErrInvalidToken {
+			t.Errorf("%s: expected ErrInvalidToken but got %v", name, err)
+		}
+	}
+}
+
+func TestProtect(t *testing.T) {
+	p := New([]byte("secret"), cookies.Production)
+	handler := p.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := serve(request("", "")); code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a token but was %d", code)
+	}
+	token, _ := p.NewToken()
+	if code := serve(request(token, token)); code != http.StatusOK {
+		t.Fatalf("expected 200 with the token but was %d", code)
+	}
+	//safe methods and requests without an auth cookie can't be forged into doing anything
+	if code := serve(httptest.NewRequest(http.MethodPost, "/api/auth/signin", nil)); code != http.StatusOK {
+		t.Fatalf("expected 200 without an auth cookie but was %d", code)
+	}
+	get := request("", "")
+	get.Method = http.MethodGet
+	if code := serve(get); code != http.StatusOK {
+		t.Fatalf("expected 200 for a GET but was %d", code)
+	}
+}
+
+func TestIssueToken(t *testing.T) {
+	p := New([]byte("secret"), cookies.Production)
+	w := httptest.NewRecorder()
+	p.IssueToken(w, httptest.NewRequest(http.MethodGet, "/api/auth/csrf", nil))
+
+	resp := w.Result()
+	if len(resp.Cookies()) != 1 {
+		t.Fatalf("expected the csrf cookie but got %v", resp.Cookies())
+	}
+	cookie := resp.Cookies()[0]
+	//the frontend reads the cookie to echo it, so it can't be HttpOnly
+	if cookie.Name != CookieName || cookie.HttpOnly || !cookie.Secure || !strings.Contains(w.Body.String(), cookie.Value) {
+		t.Fatalf("unexpected cookie %+v and body %s", cookie, w.Body)
+	}
+	if err := p.Verify(request(cookie.Value, cookie.Value)); err != nil {
+		t.Fatalf("expected the issued token to be valid but got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Package database opens the MySQL connection pool of a service.
+//
+// Open waits for the server with exponential backoff and a ping, because
+// sql.Open never fails on connectivity, and applies the pool limits from
+// Settings. Every query made with a context is a span of the trace of that
+// context. Publish exposes the pool statistics through expvar.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+
+	//MySQL driver
+	_ "github.com/go-sql-driver/mysql"
+)
+
+//Settings are the pool and retry settings, embed them in the service's config
+type Settings struct {
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" yaml:"db_max_open_conns" default:"25"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" yaml:"db_max_idle_conns" default:"25"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" yaml:"db_conn_max_lifetime" default:"5m"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" yaml:"db_conn_max_idle_time" default:"1m"`
+
+	//ConnectTimeout bounds how long Open waits for the server, 0 waits until the context is done
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" yaml:"db_connect_timeout" default:"0s"`
+	InitialBackoff time.Duration `env:"DB_INITIAL_BACKOFF" yaml:"db_initial_backoff" default:"500ms"`
+	MaxBackoff     time.Duration `env:"DB_MAX_BACKOFF" yaml:"db_max_backoff" default:"30s"`
+}
+
+//Open creates the pool for dsn and blocks until the database answers a ping
+func Open(ctx context.Context, driver string, dsn string, settings Settings) (*sql.DB, error) {
+	db, err := otelsql.Open(driver, dsn, otelsql.WithAttributes(semconv.DBSystemNameMySQL))
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(settings.MaxOpenConns)
+	db.SetMaxIdleConns(settings.MaxIdleConns)
+	db.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(settings.ConnMaxIdleTime)
+
+	if settings.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.ConnectTimeout)
+		defer cancel()
+	}
+
+	err = waitForPing(ctx, db, settings)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func waitForPing(ctx context.Context, db *sql.DB, settings Settings) error {
+	backoff := settings.InitialBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			log.Printf("connected to the database after %d attempt(s)", attempt)
+			return nil
+		}
+
+		//jitter by up to 20% so replicas don't retry in lockstep
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+		log.Printf("couldn't connect to the database (%v), retrying in %s", err, wait.Round(time.Millisecond))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if settings.MaxBackoff > 0 && backoff > settings.MaxBackoff {
+			backoff = settings.MaxBackoff
+		}
+	}
+}
+
+//Publish exposes the pool statistics of db as the expvar "database.<name>"
+func Publish(name string, db *sql.DB) {
+	expvar.Publish("database."+name, expvar.Func(func() interface{} {
+		return db.Stats()
+	}))
+}
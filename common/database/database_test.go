@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+//flaky is a driver whose first connections fail, like a MySQL server still starting
+type flaky struct {
+	mu       sync.Mutex
+	attempts int
+	//failures is how many connections fail before the server is up
+	failures int
+}
+
+func (f *flaky) Open(name string) (driver.Conn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts++
+	if f.attempts <= f.failures {
+		return nil, errors.New("connection refused")
+	}
+	return conn{}, nil
+}
+
+//fail makes the next failures connections fail
+func (f *flaky) fail(failures int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts, f.failures = 0, failures
+}
+
+type conn struct{}
+
+func (conn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (conn) Close() error                              { return nil }
+func (conn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+var server = &flaky{}
+
+func init() {
+	sql.Register("flaky", server)
+}
+
+func TestOpenRetriesUntilTheServerAnswers(t *testing.T) {
+	server.fail(3)
+	db, err := Open(context.Background(), "flaky", "", Settings{InitialBackoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if server.attempts != 4 {
+		t.Fatalf("expected 3 failed pings and a successful one but there were %d attempts", server.attempts)
+	}
+}
+
+func TestOpenGivesUpAfterTheConnectTimeout(t *testing.T) {
+	server.fail(1 << 30)
+	start := time.Now()
+	_, err := Open(context.Background(), "flaky", "", Settings{
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		ConnectTimeout: 100 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the connect timeout but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected Open to give up after the timeout but it took %s", elapsed)
+	}
+	//the backoff doubles up to MaxBackoff, so 100ms leave room for about a dozen attempts
+	if server.attempts < 5 || server.attempts > 50 {
+		t.Fatalf("expected the backoff to space the attempts but there were %d", server.attempts)
+	}
+}
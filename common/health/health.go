@@ -0,0 +1,152 @@
+// Package health serves the liveness, readiness and version endpoints of the BearChat services.
+//
+// A Checker is the outermost handler of a service. It answers /healthz, /readyz
+// and /version itself and hands every other request to the api handler once
+// Ready was called, so a service can start listening for probes while it still
+// waits for its database.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+//Build information, set at build time with
+//  go build -ldflags "-X github.com/BearCloud/fa20-project-dev/backend/common/health.Version=v1.2.0 ..."
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+//CheckTimeout bounds every readiness check
+const CheckTimeout = 2 * time.Second
+
+//Check reports an error when a dependency of the service isn't usable
+type Check func(ctx context.Context) error
+
+//Checker tracks whether a service is ready to take traffic
+type Checker struct {
+	service string
+
+	mu     sync.Mutex
+	checks map[string]Check
+
+	api      atomic.Value //http.Handler, set by Ready
+	draining atomic.Bool
+}
+
+//New creates the Checker of service, it isn't ready until Ready is called
+func New(service string) *Checker {
+	return &Checker{service: service, checks: make(map[string]Check)}
+}
+
+//AddCheck makes /readyz run check under name
+func (c *Checker) AddCheck(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+//Ready starts handing requests to api
+func (c *Checker) Ready(api http.Handler) {
+	c.api.Store(api)
+}
+
+//Drain makes /readyz fail so load balancers stop sending traffic before the service stops,
+//requests that still arrive are served
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/healthz":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "/readyz":
+		c.readyz(w, r)
+	case "/version":
+		writeJSON(w, http.StatusOK, map[string]string{
+			"service":   c.service,
+			"version":   Version,
+			"commit":    Commit,
+			"buildTime": BuildTime,
+			"go":        runtime.Version(),
+		})
+	default:
+		api, _ := c.api.Load().(http.Handler)
+		if api == nil {
+			w.Header().Set("Retry-After", "1")
+			problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "the service is starting")
+			return
+		}
+		api.ServeHTTP(w, r)
+	}
+}
+
+//readyz runs every check concurrently and reports the failing ones. The errors are only logged,
+//they can hold hosts and DSNs and /readyz is public.
+func (c *Checker) readyz(w http.ResponseWriter, r *http.Request) {
+	if c.api.Load() == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+		return
+	}
+	if c.draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		return
+	}
+
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), CheckTimeout)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		resultMu sync.Mutex
+		results  = make(map[string]string, len(checks))
+		failed   = false
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := "ok"
+			err := check(ctx)
+			if err != nil {
+				slog.WarnContext(r.Context(), "readiness check failed", "check", name, "err", err)
+				result = "failed"
+			}
+			resultMu.Lock()
+			defer resultMu.Unlock()
+			results[name] = result
+			failed = failed || err != nil
+		}(name, check)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	if failed {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]interface{}{"status": status, "checks": results})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,201 @@
+// Package logging sets up the structured JSON logs shared by the BearChat services.
+//
+// Setup installs a log/slog logger as the default, so the log package writes
+// through it too. Every record logged with a request's context carries the
+// request_id assigned by Middleware and the trace_id of its span, and tokens, passwords and email
+// addresses are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+//RequestIDHeader carries the request ID between the services
+const RequestIDHeader = "X-Request-ID"
+
+//Settings are the log settings, config.Common embeds them so every service has them
+type Settings struct {
+	Level  string `env:"LOG_LEVEL" yaml:"log_level" default:"info"`
+	Format string `env:"LOG_FORMAT" yaml:"log_format" default:"json"`
+}
+
+//Setup makes the logger of service the default of slog and of the log package
+func Setup(service string, settings Settings) error {
+	logger, err := New(os.Stderr, service, settings)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+//New creates a logger writing to w with the level and format of settings
+func New(w io.Writer, service string, settings Settings) (*slog.Logger, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(settings.Level))
+	if err != nil {
+		return nil, fmt.Errorf("logging: invalid LOG_LEVEL %q", settings.Level)
+	}
+
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	switch strings.ToLower(settings.Format) {
+	case "json", "":
+		handler = slog.NewJSONHandler(w, options)
+	case "text":
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("logging: invalid LOG_FORMAT %q, use json or text", settings.Format)
+	}
+	return slog.New(contextHandler{handler}).With("service", service), nil
+}
+
+//contextHandler adds the request ID and the trace of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+var (
+	//sensitiveKey matches the attributes whose whole value is masked
+	sensitiveKey = regexp.MustCompile(`(?i)password|token|secret|authorization|cookie|claims`)
+	email        = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	jwt          = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*`)
+	//secretParam matches token=..., password: ... and the like inside messages
+	secretParam = regexp.MustCompile(`(?i)(\w*(?:password|token|secret)"?\s*[:=]\s*"?)[^\s"&,}]+`)
+)
+
+//Redact masks the tokens, passwords and email addresses found in s
+func Redact(s string) string {
+	s = jwt.ReplaceAllString(s, "[REDACTED]")
+	s = secretParam.ReplaceAllString(s, "${1}[REDACTED]")
+	return email.ReplaceAllString(s, "[EMAIL]")
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKey.MatchString(attr.Key) {
+		return slog.String(attr.Key, "[REDACTED]")
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+	return attr
+}
+
+type requestIDKey struct{}
+
+//WithRequestID returns ctx carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+//RequestID returns the request ID of ctx, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+//PropagateRequestID sets the request ID of the context of req on the outgoing request
+func PropagateRequestID(req *http.Request) {
+	if id := RequestID(req.Context()); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
+
+//validRequestID accepts the IDs of other services and proxies, but nothing that could forge a log line
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+//Middleware accepts the X-Request-ID of the caller or assigns one, echoes it in the response,
+//puts it in the request context and header for outgoing calls, and logs every request
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		r.Header.Set(RequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+		r = r.WithContext(WithRequestID(r.Context(), id))
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		level := slog.LevelInfo
+		if recorder.status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.Log(r.Context(), level, "request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+		)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+//Unwrap lets http.ResponseController reach the flusher of the proxied responses
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,282 @@
+// Package migrate applies the versioned SQL migrations a service embeds.
+//
+// Migrations are files named <version>_<name>.up.sql and
+// <version>_<name>.down.sql, e.g. 0002_create_passkeys.up.sql. Applied
+// versions are recorded in the schema_migrations table of the service's
+// database, and a MySQL named lock keeps several replicas starting at the
+// same time from migrating concurrently.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockName    = "bearchat_schema_migrations"
+	lockTimeout = 60 // seconds
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+//Migration is one schema version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+//Load reads every migration in the root of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(fsys, path.Join(".", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migrate: version %d has no up migration", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+//Up applies every migration in fsys that hasn't been applied yet
+func Up(db *sql.DB, fsys fs.FS) error {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return err
+	}
+	return withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			if applied[migration.Version] {
+				continue
+			}
+			log.Printf("applying migration %d_%s", migration.Version, migration.Name)
+			err = execScript(conn, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migrate: %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			_, err = conn.ExecContext(context.Background(), "INSERT INTO schema_migrations (version, name, appliedAt) VALUES (?,?,?)",
+				migration.Version, migration.Name, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Down reverts the latest steps applied migrations
+func Down(db *sql.DB, fsys fs.FS, steps int) error {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return err
+	}
+	return withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+			migration := migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migrate: %d_%s has no down migration", migration.Version, migration.Name)
+			}
+			log.Printf("reverting migration %d_%s", migration.Version, migration.Name)
+			err = execScript(conn, migration.Down)
+			if err != nil {
+				return fmt.Errorf("migrate: %d_%s: %v", migration.Version, migration.Name, err)
+			}
+			_, err = conn.ExecContext(context.Background(), "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+//Status returns one line per migration telling whether it has been applied
+func Status(db *sql.DB, fsys fs.FS) ([]string, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	err = withLock(db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrations {
+			state := "pending"
+			if applied[migration.Version] {
+				state = "applied"
+			}
+			lines = append(lines, fmt.Sprintf("%04d_%s %s", migration.Version, migration.Name, state))
+		}
+		return nil
+	})
+	return lines, err
+}
+
+//Command runs the migrate sub command of a service: "up", "down [steps]" or "status"
+func Command(db *sql.DB, fsys fs.FS, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [steps] | status")
+	}
+	switch args[0] {
+	case "up":
+		return Up(db, fsys)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("migrate: invalid number of steps %q", args[1])
+			}
+		}
+		return Down(db, fsys, steps)
+	case "status":
+		lines, err := Status(db, fsys)
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return err
+	}
+	return fmt.Errorf("migrate: unknown command %q", args[0])
+}
+
+//withLock runs fn on a single connection holding the migration lock
+func withLock(db *sql.DB, fn func(*sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return errors.New("migrate: timed out waiting for the migration lock")
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255),
+		appliedAt DATETIME
+	)`)
+	if err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]bool, error) {
+	rows, err := conn.QueryContext(context.Background(), "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		err = rows.Scan(&version)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+//execScript runs every statement of a migration file, they are separated by a semicolon at the end of a line
+func execScript(conn *sql.Conn, script string) error {
+	for _, statement := range statements(script) {
+		_, err := conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statements(script string) []string {
+	var (
+		result  []string
+		current strings.Builder
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
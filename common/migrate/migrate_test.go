@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+//recorder is a driver recording the statements it runs, it answers GET_LOCK with lock and the
+//schema_migrations query with applied
+type recorder struct {
+	mu         sync.Mutex
+	statements []string
+	lock       int64
+	applied    []int64
+}
+
+func (r *recorder) Open(name string) (driver.Conn, error) {
+	return &conn{r}, nil
+}
+
+//reset forgets the statements and sets the answers of the next Up
+func (r *recorder) reset(lock int64, applied ...int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements, r.lock, r.applied = nil, lock, applied
+}
+
+//executed returns the statements of the migrations, without the lock and the bookkeeping
+func (r *recorder) executed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := []string{}
+	for _, statement := range r.statements {
+		if !strings.Contains(statement, "schema_migrations") && !strings.Contains(statement, "_LOCK(") {
+			result = append(result, statement)
+		}
+	}
+	return result
+}
+
+type conn struct{ r *recorder }
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *conn) Close() error                              { return nil }
+func (c *conn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.statements = append(c.r.statements, strings.Join(strings.Fields(query), " "))
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.statements = append(c.r.statements, query)
+	if strings.HasPrefix(query, "SELECT GET_LOCK") {
+		return &rows{[]int64{c.r.lock}}, nil
+	}
+	return &rows{c.r.applied}, nil
+}
+
+//rows is a single column of integers
+type rows struct{ values []int64 }
+
+func (r *rows) Columns() []string { return []string{"value"} }
+func (r *rows) Close() error      { return nil }
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+var db = &recorder{}
+
+func init() {
+	sql.Register("recorder", db)
+}
+
+var migrations = fstest.MapFS{
+	"0001_create_users.up.sql": {Data: []byte(`-- the users
+CREATE TABLE users (
+	id INT PRIMARY KEY
+);
+CREATE INDEX users_id ON users (id);
+`)},
+	"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;\n")},
+	"0002_add_name.up.sql":       {Data: []byte("ALTER TABLE users ADD COLUMN name VARCHAR(32)")},
+	"0002_add_name.down.sql":     {Data: []byte("ALTER TABLE users DROP COLUMN name;")},
+	"README.md":                  {Data: []byte("not a migration")},
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- comment\nCREATE TABLE a (\n  x INT\n);\n\nINSERT INTO a VALUES (1); \nUPDATE a SET x = 2"
+	expected := []string{"CREATE TABLE a (\n  x INT\n)", "INSERT INTO a VALUES (1)", "UPDATE a SET x = 2"}
+	if got := statements(script); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q but got %q", expected, got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	loaded, err := Load(migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Name != "create_users" || loaded[1].Version != 2 || loaded[1].Down == "" {
+		t.Fatalf("unexpected migrations %+v", loaded)
+	}
+
+	_, err = Load(fstest.MapFS{"0001_a.up.sql": {}, "0001_b.up.sql": {}})
+	if err == nil {
+		t.Fatal("expected two names for one version to be refused")
+	}
+	_, err = Load(fstest.MapFS{"0001_a.down.sql": {Data: []byte("DROP TABLE a")}})
+	if err == nil {
+		t.Fatal("expected a version without an up migration to be refused")
+	}
+}
+
+func TestUpAppliesThePendingMigrationsUnderTheLock(t *testing.T) {
+	conn, err := sql.Open("recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	db.reset(1, 1)
+	err = Up(conn, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.mu.Lock()
+	first, last := db.statements[0], db.statements[len(db.statements)-1]
+	db.mu.Unlock()
+	if !strings.HasPrefix(first, "SELECT GET_LOCK") || !strings.HasPrefix(last, "SELECT RELEASE_LOCK") {
+		t.Fatalf("expected the migration under the lock but it ran between %q and %q", first, last)
+	}
+	expected := []string{"ALTER TABLE users ADD COLUMN name VARCHAR(32)"}
+	if got := db.executed(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected only the pending migration but got %q", db.executed())
+	}
+
+	db.reset(1)
+	err = Up(conn, migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []string{"CREATE TABLE users ( id INT PRIMARY KEY )", "CREATE INDEX users_id ON users (id)", "ALTER TABLE users ADD COLUMN name VARCHAR(32)"}
+	if got := db.executed(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected every statement of both migrations but got %q", got)
+	}
+}
+
+func TestUpWaitsForTheLock(t *testing.T) {
+	conn, err := sql.Open("recorder", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	//another replica held the lock for the whole lockTimeout
+	db.reset(0)
+	err = Up(conn, migrations)
+	if err == nil || !strings.Contains(err.Error(), "lock") {
+		t.Fatalf("expected the lock timeout but got %v", err)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.statements) != 1 || !strings.HasPrefix(db.statements[0], "SELECT GET_LOCK") {
+		t.Fatalf("expected nothing to run without the lock but got %q", db.statements)
+	}
+}
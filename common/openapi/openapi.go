@@ -0,0 +1,177 @@
+// Package openapi serves the OpenAPI 3 specifications of the BearChat services and checks
+// the handlers against them.
+//
+// Each service embeds its api/openapi.yaml and serves it with Handler on
+// /openapi.yaml and /openapi.json. The service tests use CheckRoutes to make
+// sure every route registered by RegisterRoutes is documented and every
+// documented operation exists, and Validate to check each response of the
+// handlers against the specification.
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gorilla/mux"
+)
+
+//Load parses spec and validates it against the OpenAPI 3 schema
+func Load(spec []byte) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, err
+	}
+	err = doc.Validate(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+//Handler serves spec as YAML, or as JSON when the path ends in .json
+func Handler(spec []byte) http.Handler {
+	doc, err := Load(spec)
+	if err != nil {
+		panic("openapi: invalid specification: " + err.Error())
+	}
+	asJSON, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".json") {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(asJSON)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Write(spec)
+	})
+}
+
+//Routes serves spec on /openapi.yaml and /openapi.json of router
+func Routes(router *mux.Router, spec []byte) {
+	handler := Handler(spec)
+	router.Handle("/openapi.yaml", handler).Methods(http.MethodGet)
+	router.Handle("/openapi.json", handler).Methods(http.MethodGet)
+}
+
+//variablePattern matches the regular expression of a mux path variable, {id:[0-9]+}
+var variablePattern = regexp.MustCompile(`\{(\w+):[^}]+\}`)
+
+//CheckRoutes reports the routes of router missing from doc and the operations of doc missing from router.
+//OPTIONS routes answer CORS preflights and aren't documented.
+func CheckRoutes(doc *openapi3.T, router *mux.Router) error {
+	registered := map[string]bool{}
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		template = variablePattern.ReplaceAllString(template, "{$1}")
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			if method != http.MethodOptions {
+				registered[method+" "+template] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[method+" "+path] = true
+		}
+	}
+
+	problems := []string{}
+	for route := range registered {
+		if !documented[route] {
+			problems = append(problems, route+" is registered but not documented")
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			problems = append(problems, route+" is documented but not registered")
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New(strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+//Validate serves next and reports every response to a documented operation that doesn't match doc,
+//including statuses and content types the operation doesn't document
+func Validate(doc *openapi3.T, next http.Handler, report func(error)) http.Handler {
+	//match the operations on any host, the servers of doc are where the API is deployed
+	anyServer := *doc
+	anyServer.Servers = nil
+	router, err := gorillamux.NewRouter(&anyServer)
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, err := router.FindRoute(r)
+		if err != nil {
+			if errors.Is(err, routers.ErrPathNotFound) || errors.Is(err, routers.ErrMethodNotAllowed) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			report(err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := httptest.NewRecorder()
+		next.ServeHTTP(recorder, r)
+
+		err = openapi3filter.ValidateResponse(r.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: &openapi3filter.RequestValidationInput{
+				Request:    r,
+				PathParams: params,
+				Route:      route,
+			},
+			Status: recorder.Code,
+			Header: recorder.Header(),
+			Body:   io.NopCloser(bytes.NewReader(recorder.Body.Bytes())),
+			Options: &openapi3filter.Options{
+				IncludeResponseStatus: true,
+				AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			},
+		})
+		if err != nil {
+			report(fmt.Errorf("%s %s answered %d: %v", r.Method, r.URL.Path, recorder.Code, err))
+		}
+
+		for name, values := range recorder.Header() {
+			w.Header()[name] = values
+		}
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+	})
+}
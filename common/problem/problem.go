@@ -0,0 +1,206 @@
+// Package problem writes the errors of the BearChat APIs as application/problem+json.
+//
+// Every error response of every service has the same shape (RFC 9457):
+//
+//	{
+//	  "type": "about:blank",
+//	  "title": "Bad Request",
+//	  "status": 400,
+//	  "code": "validation_failed",
+//	  "detail": "the request has invalid fields",
+//	  "instance": "/api/auth/signup",
+//	  "request_id": "4f3c...",
+//	  "errors": [{"field": "email", "code": "required", "message": "email is required"}]
+//	}
+//
+// code is the machine readable reason clients switch on, detail is meant for
+// people and may change. errors lists the invalid fields of a request.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/gorilla/mux"
+)
+
+//ContentType is the media type of the error responses
+const ContentType = "application/problem+json"
+
+//The codes shared by every service, the services define their own next to their handlers
+const (
+	CodeInvalidJSON      = "invalid_json"
+	CodeValidation       = "validation_failed"
+	CodeUnauthenticated  = "unauthenticated"
+	CodeInvalidToken     = "invalid_token"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeCSRF             = "csrf_failed"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "unavailable"
+	CodeBadGateway       = "bad_gateway"
+)
+
+//FieldError is the problem with one field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+//Problem is the body of an error response
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+//Error makes a Problem usable as an error, e.g. by the generated clients
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Code + ": " + p.Detail
+	}
+	return p.Code
+}
+
+//New creates the problem with status, code and detail
+func New(status int, code string, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+//Write sends p as the response to r
+func Write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+//Error sends the problem with status, code and detail, it replaces http.Error
+func Error(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	Write(w, r, New(status, code, detail))
+}
+
+//Internal logs err and sends a 500 whose detail is what failed, never err itself
+func Internal(w http.ResponseWriter, r *http.Request, detail string, err error) {
+	slog.ErrorContext(r.Context(), detail, "err", err)
+	Error(w, r, http.StatusInternalServerError, CodeInternal, detail)
+}
+
+//Invalid sends a 400 listing the invalid fields
+func Invalid(w http.ResponseWriter, r *http.Request, fields Fields) {
+	p := New(http.StatusBadRequest, CodeValidation, "the request has invalid fields")
+	p.Errors = fields
+	Write(w, r, p)
+}
+
+//Fields collects the invalid fields of a request
+type Fields []FieldError
+
+//Add records that field is invalid for the reason code
+func (f *Fields) Add(field string, code string, message string) {
+	*f = append(*f, FieldError{Field: field, Code: code, Message: message})
+}
+
+//Require records field as missing when value is empty
+func (f *Fields) Require(field string, value string) {
+	if value == "" {
+		f.Add(field, "required", field+" is required")
+	}
+}
+
+//MaxBodyBytes is the largest body DecodeJSON reads
+var MaxBodyBytes int64 = 64 << 10
+
+//DecodeJSON decodes the body of r into target, it sends the problem and returns false when it can't.
+//The body must be a single JSON value of at most MaxBodyBytes without fields target doesn't have.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(target)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	var wrongType *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		Error(w, r, http.StatusRequestEntityTooLarge, CodeTooLarge, fmt.Sprintf("the body is larger than %d bytes", tooLarge.Limit))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		//encoding/json has no error type for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		fields := Fields{}
+		fields.Add(field, "unknown", field+" is not a field of this request")
+		Invalid(w, r, fields)
+	case errors.As(err, &wrongType) && wrongType.Field != "":
+		fields := Fields{}
+		fields.Add(wrongType.Field, "invalid_type", wrongType.Field+" must be a JSON "+jsonType(wrongType.Type))
+		Invalid(w, r, fields)
+	default:
+		Error(w, r, http.StatusBadRequest, CodeInvalidJSON, "the body is not valid JSON: "+err.Error())
+	}
+	return false
+}
+
+//jsonType names the JSON type a Go value is decoded from
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.String()
+}
+
+//NotFound answers the requests that match no route
+var NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusNotFound, CodeNotFound, "no route matches "+r.URL.Path)
+})
+
+//MethodNotAllowed answers the requests to a route with another method
+var MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	Error(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+})
+
+//Routes makes router answer unknown routes and methods with problems. mux doesn't run its
+//middleware for them, so they get their request ID and access log here.
+func Routes(router *mux.Router) {
+	router.NotFoundHandler = logging.Middleware(NotFound)
+	router.MethodNotAllowedHandler = logging.Middleware(MethodNotAllowed)
+}
@@ -0,0 +1,88 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+)
+
+//render runs handler on r and decodes the problem it answered with
+func render(t *testing.T, r *http.Request, handler http.HandlerFunc) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	logging.Middleware(handler).ServeHTTP(w, r)
+	if w.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("expected %s but got %q", ContentType, w.Header().Get("Content-Type"))
+	}
+	p := Problem{}
+	err := json.Unmarshal(w.Body.Bytes(), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, p
+}
+
+func TestError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/posts/0", nil)
+	w, p := render(t, r, func(w http.ResponseWriter, r *http.Request) {
+		Error(w, r, http.StatusNotFound, CodeNotFound, "no such post")
+	})
+	if w.Code != http.StatusNotFound || p.Type != "about:blank" || p.Title != "Not Found" || p.Status != 404 ||
+		p.Code != CodeNotFound || p.Detail != "no such post" || p.Instance != "/api/posts/0" {
+		t.Fatalf("unexpected problem %d %+v", w.Code, p)
+	}
+	if p.RequestID == "" || p.RequestID != w.Header().Get(logging.RequestIDHeader) {
+		t.Fatalf("expected the request ID of the response but got %q", p.RequestID)
+	}
+}
+
+func TestInternalHidesTheError(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	w, p := render(t, r, func(w http.ResponseWriter, r *http.Request) {
+		Internal(w, r, "error reading the posts", errors.New("dial tcp 172.28.1.2:3306: connection refused"))
+	})
+	if w.Code != http.StatusInternalServerError || p.Code != CodeInternal || strings.Contains(w.Body.String(), "172.28") {
+		t.Fatalf("expected a 500 without the error but got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	type body struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	cases := []struct {
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{`{"name":`, http.StatusBadRequest, CodeInvalidJSON, ""},
+		{`{"name":"a"} {}`, http.StatusBadRequest, CodeInvalidJSON, ""},
+		{`{"name":"a","admin":true}`, http.StatusBadRequest, CodeValidation, "admin"},
+		{`{"count":"many"}`, http.StatusBadRequest, CodeValidation, "count"},
+		{`{"name":"` + strings.Repeat("a", int(MaxBodyBytes)) + `"}`, http.StatusRequestEntityTooLarge, CodeTooLarge, ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		w, p := render(t, r, func(w http.ResponseWriter, r *http.Request) {
+			if DecodeJSON(w, r, &body{}) {
+				t.Errorf("expected %.40s to be refused", c.body)
+			}
+		})
+		if w.Code != c.status || p.Code != c.code || c.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != c.field) {
+			t.Errorf("%.40s: expected %d %s %s but got %d %+v", c.body, c.status, c.code, c.field, w.Code, p)
+		}
+	}
+
+	target := body{}
+	w := httptest.NewRecorder()
+	if !DecodeJSON(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"a","count":2}`)), &target) || target.Count != 2 {
+		t.Fatalf("expected the body to decode but got %+v %s", target, w.Body)
+	}
+}
@@ -0,0 +1,143 @@
+// Package server runs the HTTP server of a BearChat service and shuts it down gracefully.
+//
+// On SIGTERM or SIGINT, Run makes /readyz fail, waits DrainDelay so load
+// balancers notice, stops accepting connections and waits up to
+// ShutdownTimeout for the requests in flight. It then runs the registered
+// stop functions in reverse order, so background workers stop before the
+// database pools they use are closed.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+)
+
+//Settings are the server timeouts, config.Common embeds them so every service has them
+type Settings struct {
+	ReadHeaderTimeout time.Duration `env:"READ_HEADER_TIMEOUT" yaml:"read_header_timeout" default:"5s"`
+	ReadTimeout       time.Duration `env:"READ_TIMEOUT" yaml:"read_timeout" default:"15s"`
+	WriteTimeout      time.Duration `env:"WRITE_TIMEOUT" yaml:"write_timeout" default:"30s"`
+	IdleTimeout       time.Duration `env:"IDLE_TIMEOUT" yaml:"idle_timeout" default:"120s"`
+
+	//DrainDelay is how long /readyz fails before the listener closes
+	DrainDelay time.Duration `env:"DRAIN_DELAY" yaml:"drain_delay" default:"5s"`
+	//ShutdownTimeout bounds the wait for the requests in flight
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" default:"30s"`
+}
+
+//Server serves the health.Checker of a service
+type Server struct {
+	http     *http.Server
+	checker  *health.Checker
+	settings Settings
+	errs     chan error
+
+	mu    sync.Mutex
+	stops []stop
+}
+
+type stop struct {
+	name string
+	fn   func() error
+}
+
+//New creates the server of checker listening on addr
+func New(addr string, checker *health.Checker, settings Settings) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              addr,
+			Handler:           checker,
+			ReadHeaderTimeout: settings.ReadHeaderTimeout,
+			ReadTimeout:       settings.ReadTimeout,
+			WriteTimeout:      settings.WriteTimeout,
+			IdleTimeout:       settings.IdleTimeout,
+		},
+		checker:  checker,
+		settings: settings,
+		errs:     make(chan error, 1),
+	}
+}
+
+//SignalContext returns a context cancelled on SIGTERM or SIGINT, pass it to the startup
+//steps so that a service stopped while it waits for its database exits right away
+func SignalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+}
+
+//OnShutdown registers fn to run once the requests in flight are done.
+//The functions run in reverse order of registration, like defers.
+func (s *Server) OnShutdown(name string, fn func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stops = append(s.stops, stop{name: name, fn: fn})
+}
+
+//Start listens in the background, the checker answers probes while the service starts.
+//The returned context is ctx, also cancelled when the server fails.
+func (s *Server) Start(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		err := s.http.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.errs <- err
+			cancel()
+		}
+	}()
+	return ctx
+}
+
+//Run blocks until ctx is done, then shuts down. It returns the error of a failed server.
+func (s *Server) Run(ctx context.Context) error {
+	<-ctx.Done()
+	select {
+	case err := <-s.errs:
+		log.Printf("server failed: %v", err)
+		s.Shutdown()
+		return err
+	default:
+		log.Println("shutting down")
+		return s.Shutdown()
+	}
+}
+
+//Shutdown drains the server and runs the stop functions
+func (s *Server) Shutdown() error {
+	s.checker.Drain()
+	time.Sleep(s.settings.DrainDelay)
+
+	ctx := context.Background()
+	if s.settings.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.settings.ShutdownTimeout)
+		defer cancel()
+	}
+	err := s.http.Shutdown(ctx)
+	if err != nil {
+		log.Printf("requests were still in flight: %v", err)
+		s.http.Close()
+	}
+
+	s.mu.Lock()
+	stops := s.stops
+	s.stops = nil
+	s.mu.Unlock()
+	for i := len(stops) - 1; i >= 0; i-- {
+		stopErr := stops[i].fn()
+		if stopErr != nil {
+			log.Printf("stopping %s: %v", stops[i].name, stopErr)
+			if err == nil {
+				err = stopErr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,159 @@
+// Package tracing sets up the OpenTelemetry tracing shared by the BearChat services.
+//
+// Setup installs the tracer provider and the W3C trace context propagator.
+// Middleware continues the trace of the caller with a server span per request
+// named after the mux route template, Transport and Client start client spans and propagate the trace
+// to the called service, and database.Open instruments database/sql. Spans are
+// exported to stdout or to an OTLP/HTTP collector, or not at all.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//Settings are the tracing settings, config.Common embeds them so every service has them
+type Settings struct {
+	//Exporter is none, stdout or otlp
+	Exporter string `env:"TRACING_EXPORTER" yaml:"tracing_exporter" default:"none"`
+	//OTLPEndpoint is the OTLP/HTTP collector, e.g. http://otel-collector:4318
+	OTLPEndpoint string `env:"OTLP_ENDPOINT" yaml:"otlp_endpoint" default:"http://localhost:4318"`
+	//SampleRatio is the share of new traces recorded, a request keeps the decision of its caller
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" yaml:"tracing_sample_ratio" default:"1"`
+}
+
+//instrumentation names the tracer of the server spans
+const instrumentation = "github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+
+//ShutdownTimeout bounds the export of the last spans
+var ShutdownTimeout = 5 * time.Second
+
+//Setup installs the tracer provider of service, the returned function exports the spans left,
+//register it with server.OnShutdown
+func Setup(ctx context.Context, service string, settings Settings) (func() error, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(settings.Exporter) {
+	case "none", "":
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(settings.OTLPEndpoint))
+	default:
+		err = fmt.Errorf("tracing: invalid TRACING_EXPORTER %q, use none, stdout or otlp", settings.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(service, settings.SampleRatio, exporter)
+	Install(provider)
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+//NewProvider creates the tracer provider of service sampling ratio of the new traces,
+//a nil exporter still creates spans so the trace is propagated
+func NewProvider(service string, ratio float64, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", service),
+			attribute.String("service.version", health.Version),
+		)),
+	}
+	if exporter != nil {
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(options...)
+}
+
+//Install makes provider the global tracer provider and propagates the W3C trace context and baggage
+func Install(provider trace.TracerProvider) {
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+//Middleware continues the trace of the caller, or starts one, with a server span per request
+//named after its mux route template
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentation).Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	})
+}
+
+//statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+//Unwrap lets http.ResponseController reach the flusher of the proxied responses
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+//Transport wraps base so that every request has a client span and carries the trace
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+//Client is the http.Client of the outgoing calls between services
+var Client = &http.Client{Transport: Transport(http.DefaultTransport)}
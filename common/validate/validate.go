@@ -0,0 +1,154 @@
+// Package validate checks the bodies of the requests against the rules declared in their
+// validate struct tags.
+//
+//	type Post struct {
+//		PostBody string `json:"postBody" validate:"notblank,max=255,nocontrol"`
+//	}
+//
+// The rules are those of github.com/go-playground/validator plus:
+//
+//	notblank   not empty once spaces are trimmed
+//	nocontrol  no control characters other than newlines and tabs
+//	username   letters, digits, '.', '_' and '-'
+//	name       letters, spaces, '\'', '.' and '-'
+//	maxbytes   at most that many bytes of UTF-8, where max counts characters
+//
+// Violations are reported as problem.Fields named after the json tags, so they
+// come back in the shared error format with the codes required, too_short,
+// too_long or invalid.
+package validate
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+	namePattern     = regexp.MustCompile(`^[\p{L}\p{M}' .-]+$`)
+)
+
+var rules = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	//name the fields like the clients do
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	})
+	v.RegisterValidation("nocontrol", func(fl validator.FieldLevel) bool {
+		return strings.IndexFunc(fl.Field().String(), func(r rune) bool {
+			return unicode.IsControl(r) && r != '\n' && r != '\t'
+		}) < 0
+	})
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return usernamePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("name", func(fl validator.FieldLevel) bool {
+		return namePattern.MatchString(fl.Field().String())
+	})
+	v.RegisterValidation("maxbytes", func(fl validator.FieldLevel) bool {
+		limit, err := strconv.Atoi(fl.Param())
+		if err != nil {
+			panic("validate: maxbytes needs a number, not " + fl.Param())
+		}
+		return len(fl.Field().String()) <= limit
+	})
+	return v
+}
+
+//Struct returns the fields of v breaking the rules of their validate tags
+func Struct(v interface{}) problem.Fields {
+	fields := problem.Fields{}
+	err := rules.Struct(v)
+	if err != nil {
+		add(&fields, "", err)
+	}
+	return fields
+}
+
+//Var records field in fields when value breaks the rules of tag, e.g. "omitempty,min=8"
+func Var(fields *problem.Fields, field string, value interface{}, tag string) {
+	err := rules.Var(value, tag)
+	if err != nil {
+		add(fields, field, err)
+	}
+}
+
+//DecodeJSON decodes the body of r into target and validates it, it sends the problem and returns false
+//when the body isn't acceptable
+func DecodeJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	if !problem.DecodeJSON(w, r, target) {
+		return false
+	}
+	fields := Struct(target)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return false
+	}
+	return true
+}
+
+//add records the violations of err, named field when they don't belong to a struct field
+func add(fields *problem.Fields, field string, err error) {
+	violations, ok := err.(validator.ValidationErrors)
+	if !ok {
+		//the rules themselves are wrong, e.g. an unknown tag
+		panic("validate: " + err.Error())
+	}
+	for _, violation := range violations {
+		name := field
+		if name == "" {
+			name = violation.Field()
+		}
+		code, message := describe(name, violation)
+		fields.Add(name, code, message)
+	}
+}
+
+//describe returns the code and message of a violation
+func describe(field string, violation validator.FieldError) (code string, message string) {
+	switch violation.Tag() {
+	case "required", "notblank":
+		return "required", field + " is required"
+	case "min":
+		return "too_short", fmt.Sprintf("%s must be at least %s characters", field, violation.Param())
+	case "max":
+		return "too_long", fmt.Sprintf("%s must be at most %s characters", field, violation.Param())
+	case "maxbytes":
+		return "too_long", fmt.Sprintf("%s must be at most %s bytes", field, violation.Param())
+	case "email":
+		return "invalid", field + " must be an email address"
+	case "uuid":
+		return "invalid", field + " must be a UUID"
+	case "username":
+		return "invalid", field + " may only contain letters, digits, '.', '_' and '-'"
+	case "name":
+		return "invalid", field + " may only contain letters, spaces, ''', '.' and '-'"
+	case "nocontrol":
+		return "invalid", field + " must not contain control characters"
+	case "oneof":
+		return "invalid", fmt.Sprintf("%s must be one of %s", field, strings.ReplaceAll(violation.Param(), " ", ", "))
+	}
+	return "invalid", fmt.Sprintf("%s breaks the %s rule", field, violation.Tag())
+}
@@ -0,0 +1,121 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	authapi "github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+func TestSignup(t *testing.T) {
+	c := signup(t, "signup_user")
+	if c.cookie("access_token") == "" || c.cookie("refresh_token") == "" {
+		t.Fatal("signup didn't set the access and refresh tokens")
+	}
+
+	other := newClient(t)
+	resp, body := other.do(http.MethodPost, authURL+"/api/auth/signup", map[string]string{
+		"username": "signup_user", "email": "other@berkeley.edu", "password": "password",
+	})
+	expectProblem(t, "signup with a taken username", resp, body, http.StatusConflict, authapi.CodeUsernameTaken)
+
+	resp, body = other.do(http.MethodPost, authURL+"/api/auth/signup", map[string]string{
+		"username": "signup_other", "email": "signup_user@berkeley.edu", "password": "password",
+	})
+	expectProblem(t, "signup with a taken email", resp, body, http.StatusConflict, authapi.CodeEmailTaken)
+
+	resp, body = other.do(http.MethodPost, authURL+"/api/auth/signup", map[string]string{"username": "signup_other"})
+	p := expectProblem(t, "signup without email and password", resp, body, http.StatusBadRequest, problem.CodeValidation)
+	if len(p.Errors) != 2 || p.Errors[0].Field != "email" || p.Errors[1].Field != "password" || p.Errors[0].Code != "required" {
+		t.Fatalf("expected email and password to be reported missing but got %+v", p.Errors)
+	}
+
+	resp, body = other.do(http.MethodPost, authURL+"/api/auth/signup", "{not json")
+	expectProblem(t, "signup with a malformed body", resp, body, http.StatusBadRequest, problem.CodeInvalidJSON)
+}
+
+func TestSigninAndLogout(t *testing.T) {
+	signup(t, "signin_user")
+
+	c := newClient(t)
+	resp, body := c.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{
+		"username": "signin_user", "password": "wrong",
+	})
+	expectProblem(t, "signin with a wrong password", resp, body, http.StatusUnauthorized, authapi.CodeWrongPassword)
+
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{
+		"username": "signin_user", "email": "signin_user@berkeley.edu", "password": "signin_user-password",
+	})
+	expect(t, "signin", resp, body, http.StatusOK)
+	if c.cookie("access_token") == "" || c.cookie("refresh_token") == "" {
+		t.Fatal("signin didn't set the access and refresh tokens")
+	}
+
+	//once signed in, every mutation needs the CSRF token
+	c.fetchCSRFToken()
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{
+		"username": "nobody", "password": "password",
+	})
+	expectProblem(t, "signin as an unknown user", resp, body, http.StatusNotFound, authapi.CodeUserNotFound)
+
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/logout", nil)
+	expect(t, "logout", resp, body, http.StatusOK)
+	for _, cookie := range resp.Cookies() {
+		if cookie.Value != "" || cookie.Expires.After(time.Now()) {
+			t.Fatalf("logout didn't expire %s", cookie.Name)
+		}
+	}
+	if c.cookie("access_token") != "" || c.cookie("refresh_token") != "" {
+		t.Fatal("the client still holds its tokens after logout")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	c := signup(t, "verify_user")
+
+	resp, body := c.do(http.MethodPost, authURL+"/api/auth/verify?token=dummy", nil)
+	expectProblem(t, "verify with an unknown token", resp, body, http.StatusBadRequest, authapi.CodeInvalidVerifyToken)
+
+	token := mailer.token(t, "verify_user@berkeley.edu", "user-signup.html")
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/verify?token="+token, nil)
+	expect(t, "verify with the emailed token", resp, body, http.StatusOK)
+}
+
+func TestPasswordReset(t *testing.T) {
+	signup(t, "reset_user")
+	c := newClient(t)
+
+	resp, body := c.do(http.MethodPost, authURL+"/api/auth/sendreset", map[string]string{"email": "nobody@berkeley.edu"})
+	expectProblem(t, "sendreset for an unknown email", resp, body, http.StatusNotFound, authapi.CodeUserNotFound)
+
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/sendreset", map[string]string{"email": "reset_user@berkeley.edu"})
+	expect(t, "sendreset", resp, body, http.StatusOK)
+	token := mailer.token(t, "reset_user@berkeley.edu", "password-reset.html")
+
+	reset := map[string]string{"username": "reset_user", "email": "reset_user@berkeley.edu", "password": "new-password"}
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/resetpw?token=wrong", reset)
+	expectProblem(t, "resetpw with a wrong token", resp, body, http.StatusBadRequest, authapi.CodeInvalidResetToken)
+
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/resetpw?token="+token, reset)
+	expect(t, "resetpw", resp, body, http.StatusOK)
+
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{"username": "reset_user", "password": "reset_user-password"})
+	expectProblem(t, "signin with the old password", resp, body, http.StatusUnauthorized, authapi.CodeWrongPassword)
+	resp, body = c.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{"username": "reset_user", "password": "new-password"})
+	expect(t, "signin with the new password", resp, body, http.StatusOK)
+}
+
+func TestCSRFIsEnforcedAcrossServices(t *testing.T) {
+	c := signup(t, "csrf_user")
+
+	//a cross-site form post carries the cookies but not the header
+	c.jar.SetCookies(mustParse(authURL), []*http.Cookie{{Name: "csrf_token", Value: "", MaxAge: -1, Path: "/"}})
+	for _, url := range []string{postsURL + "/api/posts/create", friendsURL + "/api/friends"} {
+		resp, body := c.do(http.MethodPost, url, map[string]string{"postBody": "forged"})
+		expectProblem(t, "POST "+url+" without a CSRF token", resp, body, http.StatusForbidden, problem.CodeCSRF)
+	}
+	resp, body := c.do(http.MethodPut, profilesURL+"/api/profile/"+c.userID, map[string]string{"firstName": "forged"})
+	expectProblem(t, "PUT profile without a CSRF token", resp, body, http.StatusForbidden, problem.CodeCSRF)
+}
@@ -0,0 +1,10 @@
+// Package e2e runs the BearChat services together, in-process, and tests them
+// end to end over HTTP.
+//
+// TestMain starts auth-service, posts, profiles and friends on local ports the
+// same way their main functions do, with the real MySQL stores and migrations
+// running against an embedded MySQL compatible server and friends talking to a
+// fake gremlin endpoint instead of Neptune. No containers are needed:
+//
+//	cd e2e && go test ./...
+package e2e
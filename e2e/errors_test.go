@@ -0,0 +1,58 @@
+package e2e
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+func TestUnknownRoutesAreProblems(t *testing.T) {
+	c := signup(t, "errors_oski")
+
+	resp, body := c.do(http.MethodGet, postsURL+"/api/nothing/here", nil)
+	expectProblem(t, "unknown route", resp, body, http.StatusNotFound, problem.CodeNotFound)
+
+	resp, body = c.do(http.MethodPatch, profilesURL+"/api/profile/"+c.userID, nil)
+	expectProblem(t, "unknown method", resp, body, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed)
+
+	resp, body = c.do(http.MethodPost, postsURL+"/api/posts/create", "{not json")
+	expectProblem(t, "malformed post", resp, body, http.StatusBadRequest, problem.CodeInvalidJSON)
+
+	resp, body = c.do(http.MethodGet, postsURL+"/api/posts/user/"+c.userID+"?limit=-1", nil)
+	p := expectProblem(t, "negative limit", resp, body, http.StatusBadRequest, problem.CodeValidation)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "limit" {
+		t.Fatalf("expected limit to be reported but got %+v", p.Errors)
+	}
+}
+
+func TestInvalidBodiesAreRejected(t *testing.T) {
+	c := signup(t, "errors_stanny")
+
+	cases := []struct {
+		what  string
+		url   string
+		body  interface{}
+		field string
+		code  string
+	}{
+		{"long post", postsURL + "/api/posts/create", map[string]string{"postBody": strings.Repeat("x", 256)}, "postBody", "too_long"},
+		{"blank post", postsURL + "/api/posts/create", map[string]string{"postBody": " "}, "postBody", "required"},
+		{"unknown field", postsURL + "/api/posts/create", map[string]string{"postBody": "hi", "mood": "happy"}, "mood", "unknown"},
+		{"invalid email", authURL + "/api/auth/signup", map[string]string{"username": "errors_x", "email": "x", "password": "long enough"}, "email", "invalid"},
+	}
+	for _, tc := range cases {
+		resp, body := c.do(http.MethodPost, tc.url, tc.body)
+		p := expectProblem(t, tc.what, resp, body, http.StatusBadRequest, problem.CodeValidation)
+		if len(p.Errors) != 1 || p.Errors[0].Field != tc.field || p.Errors[0].Code != tc.code {
+			t.Errorf("%s: expected %s to be %s but got %+v", tc.what, tc.field, tc.code, p.Errors)
+		}
+	}
+	if posts := getPosts(t, c, postsURL+"/api/posts/user/"+c.userID); len(posts) != 0 {
+		t.Fatalf("expected the invalid posts to be rejected but got %+v", posts)
+	}
+
+	resp, body := c.do(http.MethodPost, postsURL+"/api/posts/create", `{"postBody": "`+strings.Repeat("x", int(problem.MaxBodyBytes))+`"}`)
+	expectProblem(t, "huge post", resp, body, http.StatusRequestEntityTooLarge, problem.CodeTooLarge)
+}
@@ -0,0 +1,77 @@
+package e2e
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+func mustParse(rawURL string) *url.URL {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func TestFriends(t *testing.T) {
+	oski := signup(t, "friends_oski")
+	stanny := signup(t, "friends_stanny")
+	tree := signup(t, "friends_tree")
+
+	for _, c := range []*client{oski, stanny, tree} {
+		resp, body := c.do(http.MethodPost, friendsURL+"/api/friends", nil)
+		expect(t, "add user to the graph", resp, body, http.StatusOK)
+	}
+
+	resp, body := oski.do(http.MethodGet, friendsURL+"/api/friends/"+stanny.userID, nil)
+	expect(t, "are friends", resp, body, http.StatusOK)
+	if strings.TrimSpace(string(body)) != "false" {
+		t.Fatalf("expected strangers but got %s", body)
+	}
+
+	resp, body = oski.do(http.MethodPost, friendsURL+"/api/friends/"+stanny.userID, nil)
+	expect(t, "add friend", resp, body, http.StatusOK)
+	resp, body = oski.do(http.MethodPost, friendsURL+"/api/friends/"+tree.userID, nil)
+	expect(t, "add friend", resp, body, http.StatusOK)
+
+	//friendship goes both ways
+	for _, pair := range [][2]*client{{oski, stanny}, {stanny, oski}} {
+		resp, body = pair[0].do(http.MethodGet, friendsURL+"/api/friends/"+pair[1].userID, nil)
+		expect(t, "are friends", resp, body, http.StatusOK)
+		if strings.TrimSpace(string(body)) != "true" {
+			t.Fatalf("expected friends but got %s", body)
+		}
+	}
+
+	resp, body = oski.do(http.MethodGet, friendsURL+"/api/friends", nil)
+	expect(t, "get friends", resp, body, http.StatusOK)
+	friends := []string{}
+	decode(t, body, &friends)
+	sort.Strings(friends)
+	expected := []string{stanny.userID, tree.userID}
+	sort.Strings(expected)
+	if strings.Join(friends, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected friends %v but got %v", expected, friends)
+	}
+
+	resp, body = stanny.do(http.MethodGet, friendsURL+"/api/friends", nil)
+	expect(t, "get friends", resp, body, http.StatusOK)
+	decode(t, body, &friends)
+	if len(friends) != 1 || friends[0] != oski.userID {
+		t.Fatalf("expected only oski as stanny's friend but got %v", friends)
+	}
+
+	//befriending someone who isn't in the graph fails in Neptune
+	resp, body = oski.do(http.MethodPost, friendsURL+"/api/friends/00000000-0000-0000-0000-000000000000", nil)
+	if resp.StatusCode == http.StatusOK {
+		t.Fatalf("expected an error when befriending an unknown user: %s", body)
+	}
+
+	resp, body = newClient(t).do(http.MethodGet, friendsURL+"/api/friends", nil)
+	expectProblem(t, "get friends without signing in", resp, body, http.StatusUnauthorized, problem.CodeUnauthenticated)
+}
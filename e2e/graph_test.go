@@ -0,0 +1,117 @@
+package e2e
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+)
+
+// the gremlin queries sent by the friends service, their uuids are bound variables
+var (
+	addVertex  = regexp.MustCompile(`^g\.addV\(\)\.property\('uuid', (\w+)\)$`)
+	addEdge    = regexp.MustCompile(`^g\.addE\('friends with'\)\.from\(g\.V\(\)\.has\('uuid', (\w+)\)\)\.to\(g\.V\(\)\.has\('uuid', (\w+)\)\)$`)
+	friendsOf  = regexp.MustCompile(`^g\.V\(\)\.has\('uuid', (\w+)\)\.out\('friends with'\)\.values\('uuid'\)$`)
+	countAny   = regexp.MustCompile(`^g\.V\(\)\.limit\(1\)\.count\(\)$`)
+	countEdges = regexp.MustCompile(`^g\.V\(\)\.has\('uuid', (\w+)\)\.outE\('friends with'\)\.where\(otherV\(\)\.has\('uuid', (\w+)\)\)\.count\(\)$`)
+)
+
+// graph is a fake Neptune gremlin HTTP endpoint that understands the queries of the friends service
+type graph struct {
+	mu       sync.Mutex
+	vertices map[string]bool
+	edges    map[string][]string
+	queries  []string
+	//requestIDs are the X-Request-ID headers of the queries
+	requestIDs []string
+	//traceparents are the W3C trace context headers of the queries
+	traceparents []string
+	//down makes every query fail like an unreachable cluster
+	down bool
+}
+
+func newGraph() *graph {
+	return &graph{vertices: make(map[string]bool), edges: make(map[string][]string)}
+}
+
+func (g *graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := struct {
+		Gremlin  string            `json:"gremlin"`
+		Bindings map[string]string `json:"bindings"`
+	}{}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query := body.Gremlin
+	//bound returns the value of the variable of a match, Neptune fails on an unbound one
+	bound := func(match []string) []string {
+		values := make([]string, len(match))
+		for i, name := range match[1:] {
+			values[i+1] = body.Bindings[name]
+		}
+		return values
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.queries = append(g.queries, query)
+	g.requestIDs = append(g.requestIDs, r.Header.Get("X-Request-ID"))
+	g.traceparents = append(g.traceparents, r.Header.Get("traceparent"))
+	if g.down {
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	values := []interface{}{}
+	switch {
+	case addVertex.MatchString(query):
+		uuid := bound(addVertex.FindStringSubmatch(query))[1]
+		g.vertices[uuid] = true
+		values = append(values, map[string]interface{}{"@type": "g:Vertex", "@value": map[string]string{"label": "vertex"}})
+	case addEdge.MatchString(query):
+		match := bound(addEdge.FindStringSubmatch(query))
+		if !g.vertices[match[1]] || !g.vertices[match[2]] {
+			//Neptune fails the traversal when from() or to() finds no vertex
+			http.Error(w, `{"code":"InternalFailureException","detailedMessage":"The provided traverser does not map to a value"}`, http.StatusInternalServerError)
+			return
+		}
+		g.edges[match[1]] = append(g.edges[match[1]], match[2])
+	case friendsOf.MatchString(query):
+		for _, uuid := range g.edges[bound(friendsOf.FindStringSubmatch(query))[1]] {
+			values = append(values, uuid)
+		}
+	case countAny.MatchString(query):
+		count := 0
+		if len(g.vertices) > 0 {
+			count = 1
+		}
+		values = append(values, map[string]interface{}{"@type": "g:Int64", "@value": count})
+	case countEdges.MatchString(query):
+		match := bound(countEdges.FindStringSubmatch(query))
+		count := 0
+		for _, uuid := range g.edges[match[1]] {
+			if uuid == match[2] {
+				count++
+			}
+		}
+		values = append(values, map[string]interface{}{"@type": "g:Int64", "@value": count})
+	default:
+		http.Error(w, `{"code":"MalformedQueryException"}`, http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": map[string]interface{}{"code": 200},
+		"result": map[string]interface{}{
+			"data": map[string]interface{}{"@type": "g:List", "@value": values},
+		},
+	})
+}
+
+func startGraph() (*graph, *httptest.Server) {
+	g := newGraph()
+	return g, httptest.NewServer(g)
+}
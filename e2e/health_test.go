@@ -0,0 +1,85 @@
+package e2e
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+)
+
+func TestHealth(t *testing.T) {
+	c := newClient(t)
+	for service, url := range map[string]string{
+		"auth-service":     authURL,
+		"posts-service":    postsURL,
+		"profiles-service": profilesURL,
+		"friends-service":  friendsURL,
+	} {
+		resp, body := c.do(http.MethodGet, url+"/healthz", nil)
+		expect(t, service+" healthz", resp, body, http.StatusOK)
+
+		resp, body = c.do(http.MethodGet, url+"/readyz", nil)
+		expect(t, service+" readyz", resp, body, http.StatusOK)
+
+		resp, body = c.do(http.MethodGet, url+"/version", nil)
+		expect(t, service+" version", resp, body, http.StatusOK)
+		version := map[string]string{}
+		decode(t, body, &version)
+		if version["service"] != service || version["version"] != health.Version {
+			t.Fatalf("unexpected version %v", version)
+		}
+	}
+}
+
+func TestReadinessFollowsTheGraph(t *testing.T) {
+	c := newClient(t)
+
+	friendDB.mu.Lock()
+	friendDB.down = true
+	friendDB.mu.Unlock()
+	resp, body := c.do(http.MethodGet, friendsURL+"/readyz", nil)
+	friendDB.mu.Lock()
+	friendDB.down = false
+	friendDB.mu.Unlock()
+	expect(t, "readyz with the graph down", resp, body, http.StatusServiceUnavailable)
+	result := struct{ Checks map[string]string }{}
+	decode(t, body, &result)
+	if result.Checks["graph"] != "failed" {
+		t.Fatalf("expected the graph check to fail without its error: %s", body)
+	}
+
+	//liveness doesn't depend on the graph
+	resp, body = c.do(http.MethodGet, friendsURL+"/healthz", nil)
+	expect(t, "healthz", resp, body, http.StatusOK)
+	resp, body = c.do(http.MethodGet, friendsURL+"/readyz", nil)
+	expect(t, "readyz once the graph is back", resp, body, http.StatusOK)
+}
+
+func TestReadinessGatesStartupAndShutdown(t *testing.T) {
+	checker := health.New("starting-service")
+	server := httptest.NewServer(checker)
+	defer server.Close()
+	c := newClient(t)
+
+	//while the service starts, probes are answered and api requests are turned away
+	resp, body := c.do(http.MethodGet, server.URL+"/healthz", nil)
+	expect(t, "healthz while starting", resp, body, http.StatusOK)
+	resp, body = c.do(http.MethodGet, server.URL+"/readyz", nil)
+	expect(t, "readyz while starting", resp, body, http.StatusServiceUnavailable)
+	resp, body = c.do(http.MethodGet, server.URL+"/api/posts", nil)
+	expect(t, "api while starting", resp, body, http.StatusServiceUnavailable)
+
+	checker.Ready(http.NotFoundHandler())
+	resp, body = c.do(http.MethodGet, server.URL+"/readyz", nil)
+	expect(t, "readyz once ready", resp, body, http.StatusOK)
+	resp, body = c.do(http.MethodGet, server.URL+"/api/posts", nil)
+	expect(t, "api once ready", resp, body, http.StatusNotFound)
+
+	//a draining service fails readiness but still serves what arrives
+	checker.Drain()
+	resp, body = c.do(http.MethodGet, server.URL+"/readyz", nil)
+	expect(t, "readyz while draining", resp, body, http.StatusServiceUnavailable)
+	resp, body = c.do(http.MethodGet, server.URL+"/api/posts", nil)
+	expect(t, "api while draining", resp, body, http.StatusNotFound)
+}
@@ -0,0 +1,85 @@
+package e2e
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	authapi "github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+)
+
+//metric returns the value of series scraped from auth-service, every service of the test
+//process shares the default registry
+func metric(t *testing.T, series string) float64 {
+	t.Helper()
+	resp, body := newClient(t).do(http.MethodGet, authURL+"/metrics", nil)
+	expect(t, "metrics", resp, body, http.StatusOK)
+	for _, line := range strings.Split(string(body), "\n") {
+		if strings.HasPrefix(line, series+" ") {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(line, series+" "), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return value
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	const (
+		created       = `http_requests_total{code="201",method="POST",route="/api/posts/create"}`
+		profileRoute  = `http_request_duration_seconds_count{method="GET",route="/api/profile/{uuid}"}`
+		createQueries = `db_query_duration_seconds_count{operation="CreatePost",store="posts"}`
+		postsCreated  = `bearchat_posts_created_total`
+		signups       = `bearchat_signups_total`
+		wrongPassword = `bearchat_signins_total{method="password",outcome="wrong_password"}`
+		signupEmails  = `bearchat_emails_sent_total{outcome="sent",template="user-signup.html"}`
+		friendships   = `bearchat_friendships_added_total`
+	)
+	before := map[string]float64{}
+	for _, series := range []string{created, profileRoute, createQueries, postsCreated, signups, wrongPassword, signupEmails, friendships} {
+		before[series] = metric(t, series)
+	}
+
+	oski := signup(t, "metrics_oski")
+	stanny := signup(t, "metrics_stanny")
+	resp, body := oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears!"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	//TestPosts expects to own every post
+	for _, p := range getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID) {
+		resp, body = oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+p.PostID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}
+	for _, c := range []*client{oski, stanny} {
+		resp, body = c.do(http.MethodPost, friendsURL+"/api/friends", nil)
+		expect(t, "add user", resp, body, http.StatusOK)
+	}
+	resp, body = oski.do(http.MethodPost, friendsURL+"/api/friends/"+stanny.userID, nil)
+	expect(t, "add friend", resp, body, http.StatusOK)
+	//two different uuids are one route
+	oski.do(http.MethodGet, profilesURL+"/api/profile/"+oski.userID, nil)
+	oski.do(http.MethodGet, profilesURL+"/api/profile/"+stanny.userID, nil)
+	resp, body = oski.do(http.MethodPost, authURL+"/api/auth/signin", map[string]string{
+		"username": "metrics_oski",
+		"email":    "metrics_oski@berkeley.edu",
+		"password": "wrong",
+	})
+	expectProblem(t, "signin with a wrong password", resp, body, http.StatusUnauthorized, authapi.CodeWrongPassword)
+
+	for series, increase := range map[string]float64{
+		created:       1,
+		profileRoute:  2,
+		createQueries: 1,
+		postsCreated:  1,
+		signups:       2,
+		wrongPassword: 1,
+		signupEmails:  2,
+		friendships:   1,
+	} {
+		if got := metric(t, series) - before[series]; got != increase {
+			t.Errorf("%s: expected an increase of %v but got %v", series, increase, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package e2e
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/sirupsen/logrus"
+)
+
+//startMySQL runs an in-memory MySQL compatible server on a local port and
+//creates the databases of db-server/initdb.sql, it returns the server address
+func startMySQL() (string, func(), error) {
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	provider := memory.NewDBProvider()
+	engine := sqle.NewDefault(provider)
+	s, err := server.NewServer(server.Config{Protocol: "tcp", Address: "127.0.0.1:0"},
+		engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	go s.Start()
+	addr := s.Listener.Addr().String()
+	stop := func() { s.Close() }
+
+	err = initDatabases(addr)
+	if err != nil {
+		stop()
+		return "", nil, err
+	}
+	return addr, stop, nil
+}
+
+//initDatabases runs db-server/initdb.sql like the MySQL container does on its first start
+func initDatabases(addr string) error {
+	script, err := os.ReadFile("../db-server/initdb.sql")
+	if err != nil {
+		return err
+	}
+	db, err := sql.Open("mysql", "root@tcp("+addr+")/")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var lines []string
+	for _, line := range strings.Split(string(script), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if strings.TrimSpace(statement) == "" {
+			continue
+		}
+		_, err = db.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,433 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	postsapi "github.com/BearCloud/fa20-project-dev/backend/posts/api"
+)
+
+//post mirrors the JSON of a post
+type post struct {
+	PostBody   string `json:"postBody"`
+	PostID     string `json:"postID"`
+	AuthorID   string `json:"AuthorID"`
+	EditedAt   string `json:"editedAt"`
+	Visibility string `json:"visibility"`
+
+	Reactions   map[string]int `json:"reactions"`
+	MyReactions []string       `json:"myReactions"`
+	Comments    int            `json:"comments"`
+
+	Author *postsapi.Author `json:"author"`
+}
+
+//page is a page of posts, NextCursor is empty on the last one
+type page struct {
+	Posts      []post `json:"posts"`
+	NextCursor string `json:"next_cursor"`
+	Mode       string `json:"mode"`
+}
+
+func getPage(t *testing.T, c *client, url string) page {
+	t.Helper()
+	resp, body := c.do(http.MethodGet, url, nil)
+	expect(t, "GET "+url, resp, body, http.StatusOK)
+	result := page{}
+	decode(t, body, &result)
+	return result
+}
+
+func getPosts(t *testing.T, c *client, url string) []post {
+	t.Helper()
+	return getPage(t, c, url).Posts
+}
+
+//TestPosts covers the posts scenarios in order; it is the only test keeping posts so the feeds stay predictable
+func TestPosts(t *testing.T) {
+	oski := signup(t, "posts_oski")
+	stanny := signup(t, "posts_stanny")
+
+	resp, body := oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears!"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	resp, body = stanny.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Fear the tree"})
+	expect(t, "create", resp, body, http.StatusCreated)
+
+	resp, body = newClient(t).do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "anonymous"})
+	expectProblem(t, "create without signing in", resp, body, http.StatusUnauthorized, problem.CodeUnauthenticated)
+
+	//each feed holds only the other user's post
+	oskiFeed := getPosts(t, oski, postsURL+"/api/posts")
+	if len(oskiFeed) != 1 || oskiFeed[0].AuthorID != stanny.userID || oskiFeed[0].PostBody != "Fear the tree" {
+		t.Fatalf("unexpected feed for oski %+v", oskiFeed)
+	}
+	stannyFeed := getPosts(t, stanny, postsURL+"/api/posts")
+	if len(stannyFeed) != 1 || stannyFeed[0].AuthorID != oski.userID {
+		t.Fatalf("unexpected feed for stanny %+v", stannyFeed)
+	}
+	oskiPostID, stannyPostID := stannyFeed[0].PostID, oskiFeed[0].PostID
+
+	//each user's posts
+	oskiPosts := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)
+	if len(oskiPosts) != 1 || oskiPosts[0].PostID != oskiPostID {
+		t.Fatalf("unexpected posts for oski %+v", oskiPosts)
+	}
+	stannyPosts := getPosts(t, stanny, postsURL+"/api/posts/user/"+stanny.userID)
+	if len(stannyPosts) != 1 || stannyPosts[0].PostID != stannyPostID {
+		t.Fatalf("unexpected posts for stanny %+v", stannyPosts)
+	}
+	if others := getPosts(t, oski, postsURL+"/api/posts/user/"+stanny.userID); len(others) != 1 || others[0].PostID != stannyPostID {
+		t.Fatalf("unexpected posts of stanny for oski %+v", others)
+	}
+
+	//the cursor of a full page leads to the rest, newest first
+	first := getPage(t, stanny, postsURL+"/api/posts?limit=1")
+	if len(first.Posts) != 1 || first.NextCursor != "" {
+		t.Fatalf("expected oski's post alone on the last page but got %+v", first)
+	}
+	resp, body = oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears again!"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	first = getPage(t, stanny, postsURL+"/api/posts?limit=1")
+	if len(first.Posts) != 1 || first.Posts[0].PostBody != "Go Bears again!" || first.NextCursor == "" {
+		t.Fatalf("expected a first page of oski's second post but got %+v", first)
+	}
+	rest := getPage(t, stanny, postsURL+"/api/posts?limit=1&cursor="+first.NextCursor)
+	if len(rest.Posts) != 1 || rest.Posts[0].PostID != oskiPostID || rest.NextCursor != "" {
+		t.Fatalf("expected a last page of oski's first post but got %+v", rest)
+	}
+	resp, body = oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+first.Posts[0].PostID, nil)
+	expect(t, "delete", resp, body, http.StatusOK)
+
+	//only authors delete their posts
+	resp, body = oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+stannyPostID, nil)
+	expectProblem(t, "delete someone else's post", resp, body, http.StatusForbidden, problem.CodeForbidden)
+	resp, body = oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+oskiPostID, nil)
+	expect(t, "delete", resp, body, http.StatusOK)
+	resp, body = stanny.do(http.MethodDelete, postsURL+"/api/posts/delete/"+stannyPostID, nil)
+	expect(t, "delete", resp, body, http.StatusOK)
+	resp, body = stanny.do(http.MethodDelete, postsURL+"/api/posts/delete/"+stannyPostID, nil)
+	expectProblem(t, "delete a deleted post", resp, body, http.StatusNotFound, postsapi.CodePostNotFound)
+
+	if feed := getPosts(t, oski, postsURL+"/api/posts"); len(feed) != 0 {
+		t.Fatalf("expected an empty feed after deleting every post but got %+v", feed)
+	}
+}
+
+//TestFriendsFeed reads the home feed from the friends service
+func TestFriendsFeed(t *testing.T) {
+	oski := signup(t, "feed_oski")
+	stanny := signup(t, "feed_stanny")
+	tree := signup(t, "feed_tree")
+	for _, c := range []*client{oski, stanny, tree} {
+		resp, body := c.do(http.MethodPost, friendsURL+"/api/friends", nil)
+		expect(t, "add user to the graph", resp, body, http.StatusOK)
+	}
+	resp, body := oski.do(http.MethodPost, friendsURL+"/api/friends/"+stanny.userID, nil)
+	expect(t, "add friend", resp, body, http.StatusOK)
+
+	for _, c := range []*client{oski, stanny, tree} {
+		resp, body = c.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "hello"})
+		expect(t, "create", resp, body, http.StatusCreated)
+	}
+	//TestPosts expects to own every post
+	defer func() {
+		for _, c := range []*client{oski, stanny, tree} {
+			for _, p := range getPosts(t, c, postsURL+"/api/posts/user/"+c.userID) {
+				resp, body := c.do(http.MethodDelete, postsURL+"/api/posts/delete/"+p.PostID, nil)
+				expect(t, "delete", resp, body, http.StatusOK)
+			}
+		}
+	}()
+
+	feed := getPage(t, oski, postsURL+"/api/posts")
+	if feed.Mode != "friends" || len(feed.Posts) != 2 || feed.Posts[0].AuthorID != stanny.userID || feed.Posts[1].AuthorID != oski.userID {
+		t.Fatalf("expected stanny's then oski's post but got %+v", feed)
+	}
+	feed = getPage(t, oski, postsURL+"/api/posts?self=false")
+	if len(feed.Posts) != 1 || feed.Posts[0].AuthorID != stanny.userID {
+		t.Fatalf("expected stanny's post alone but got %+v", feed)
+	}
+
+	//without friends the feed is everyone else's
+	feed = getPage(t, tree, postsURL+"/api/posts")
+	if feed.Mode != "discover" || len(feed.Posts) != 2 || feed.Posts[0].AuthorID != stanny.userID {
+		t.Fatalf("expected the discover feed of stanny's and oski's posts but got %+v", feed)
+	}
+
+	//a deleted post leaves the timelines
+	oskiPosts := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)
+	resp, body = oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+oskiPosts[0].PostID, nil)
+	expect(t, "delete", resp, body, http.StatusOK)
+	feed = getPage(t, stanny, postsURL+"/api/posts")
+	if len(feed.Posts) != 1 || feed.Posts[0].AuthorID != stanny.userID {
+		t.Fatalf("expected stanny's post alone but got %+v", feed)
+	}
+}
+
+//TestEditPost edits a post twice and reads its revisions
+func TestEditPost(t *testing.T) {
+	oski := signup(t, "edit_oski")
+	stanny := signup(t, "edit_stanny")
+
+	resp, body := oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	postID := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)[0].PostID
+	//TestPosts expects to own every post
+	defer func() {
+		resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+postID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}()
+
+	resp, body = stanny.do(http.MethodPut, postsURL+"/api/posts/"+postID, map[string]string{"postBody": "Go Trees"})
+	expectProblem(t, "edit someone else's post", resp, body, http.StatusForbidden, problem.CodeForbidden)
+	for _, edit := range []string{"Go Bears!", "Go Bears!!"} {
+		resp, body = oski.do(http.MethodPut, postsURL+"/api/posts/"+postID, map[string]string{"postBody": edit})
+		expect(t, "edit", resp, body, http.StatusOK)
+		edited := post{}
+		decode(t, body, &edited)
+		if edited.PostBody != edit || edited.EditedAt == "" {
+			t.Fatalf("unexpected edited post %+v", edited)
+		}
+	}
+
+	resp, body = stanny.do(http.MethodGet, postsURL+"/api/posts/"+postID+"/revisions", nil)
+	expect(t, "revisions", resp, body, http.StatusOK)
+	history := struct {
+		Revisions []struct {
+			Revision int    `json:"revision"`
+			PostBody string `json:"postBody"`
+		} `json:"revisions"`
+	}{}
+	decode(t, body, &history)
+	if len(history.Revisions) != 3 || history.Revisions[0].PostBody != "Go Bears" || history.Revisions[2].Revision != 3 || history.Revisions[2].PostBody != "Go Bears!!" {
+		t.Fatalf("unexpected revisions %+v", history.Revisions)
+	}
+	if p := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID); len(p) != 1 || p[0].PostBody != "Go Bears!!" || p[0].EditedAt == "" {
+		t.Fatalf("expected the edited post but got %+v", p)
+	}
+}
+
+//TestReactions reacts to a post and lists who reacted
+func TestReactions(t *testing.T) {
+	oski := signup(t, "react_oski")
+	stanny := signup(t, "react_stanny")
+
+	resp, body := oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	postID := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)[0].PostID
+	//TestPosts expects to own every post
+	defer func() {
+		resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+postID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}()
+
+	reactionsURL := postsURL + "/api/posts/" + postID + "/reactions/"
+	for _, c := range []*client{stanny, stanny, oski} {
+		resp, body = c.do(http.MethodPut, reactionsURL+"like", nil)
+		expect(t, "react", resp, body, http.StatusOK)
+	}
+	resp, body = stanny.do(http.MethodPut, reactionsURL+"wow", nil)
+	expect(t, "react", resp, body, http.StatusOK)
+	summary := struct {
+		Reactions   map[string]int `json:"reactions"`
+		MyReactions []string       `json:"myReactions"`
+	}{}
+	decode(t, body, &summary)
+	if summary.Reactions["like"] != 2 || summary.Reactions["wow"] != 1 || len(summary.MyReactions) != 2 {
+		t.Fatalf("unexpected reactions %+v", summary)
+	}
+	resp, body = stanny.do(http.MethodDelete, reactionsURL+"wow", nil)
+	expect(t, "unreact", resp, body, http.StatusOK)
+	resp, body = stanny.do(http.MethodPut, reactionsURL+"meh", nil)
+	expectProblem(t, "react with an unknown kind", resp, body, http.StatusBadRequest, problem.CodeValidation)
+
+	resp, body = stanny.do(http.MethodGet, reactionsURL+"like", nil)
+	expect(t, "reactions", resp, body, http.StatusOK)
+	reactors := struct {
+		Reactions []struct {
+			UserID string `json:"userID"`
+		} `json:"reactions"`
+	}{}
+	decode(t, body, &reactors)
+	if len(reactors.Reactions) != 2 || reactors.Reactions[0].UserID != oski.userID || reactors.Reactions[1].UserID != stanny.userID {
+		t.Fatalf("expected oski's then stanny's like but got %+v", reactors.Reactions)
+	}
+
+	//the posts of a page carry their reactions
+	p := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)
+	if len(p) != 1 || p[0].Reactions["like"] != 2 || p[0].Reactions["wow"] != 0 || len(p[0].MyReactions) != 1 {
+		t.Fatalf("unexpected reactions of the post %+v", p)
+	}
+}
+
+//TestComments comments on a post, replies and deletes the thread
+func TestComments(t *testing.T) {
+	oski := signup(t, "comment_oski")
+	stanny := signup(t, "comment_stanny")
+
+	resp, body := oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	postID := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)[0].PostID
+	//TestPosts expects to own every post
+	defer func() {
+		resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+postID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}()
+
+	type comment struct {
+		CommentID string `json:"commentID"`
+		Body      string `json:"body"`
+		Depth     int    `json:"depth"`
+		Replies   int    `json:"replies"`
+		EditedAt  string `json:"editedAt"`
+	}
+	commentsURL := postsURL + "/api/posts/" + postID + "/comments"
+	resp, body = stanny.do(http.MethodPost, commentsURL, map[string]string{"body": "Go Bears!"})
+	expect(t, "comment", resp, body, http.StatusCreated)
+	first := comment{}
+	decode(t, body, &first)
+	resp, body = oski.do(http.MethodPost, commentsURL, map[string]string{"body": "Thanks", "parentID": first.CommentID})
+	expect(t, "reply", resp, body, http.StatusCreated)
+	reply := comment{}
+	decode(t, body, &reply)
+	if first.Depth != 1 || reply.Depth != 2 {
+		t.Fatalf("unexpected depths %+v %+v", first, reply)
+	}
+
+	resp, body = stanny.do(http.MethodPut, commentsURL+"/"+first.CommentID, map[string]string{"body": "Go Bears!!"})
+	expect(t, "edit comment", resp, body, http.StatusOK)
+	resp, body = oski.do(http.MethodPut, commentsURL+"/"+first.CommentID, map[string]string{"body": "Go Trees!"})
+	expectProblem(t, "edit someone else's comment", resp, body, http.StatusForbidden, problem.CodeForbidden)
+
+	resp, body = oski.do(http.MethodGet, commentsURL, nil)
+	expect(t, "comments", resp, body, http.StatusOK)
+	page := struct {
+		Comments []comment `json:"comments"`
+	}{}
+	decode(t, body, &page)
+	if len(page.Comments) != 1 || page.Comments[0].Body != "Go Bears!!" || page.Comments[0].Replies != 1 || page.Comments[0].EditedAt == "" {
+		t.Fatalf("unexpected comments %+v", page.Comments)
+	}
+	if p := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID); len(p) != 1 || p[0].Comments != 2 {
+		t.Fatalf("expected 2 comments on the post but got %+v", p)
+	}
+
+	//the author of the post deletes the thread
+	resp, body = oski.do(http.MethodDelete, commentsURL+"/"+first.CommentID, nil)
+	expect(t, "delete comment", resp, body, http.StatusOK)
+	resp, body = oski.do(http.MethodDelete, commentsURL+"/"+reply.CommentID, nil)
+	expectProblem(t, "delete a deleted reply", resp, body, http.StatusNotFound, postsapi.CodeCommentNotFound)
+	if p := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID); len(p) != 1 || p[0].Comments != 0 {
+		t.Fatalf("expected no comments on the post but got %+v", p)
+	}
+}
+
+//TestVisibility hides the friends posts from strangers and the private ones from everyone else
+func TestVisibility(t *testing.T) {
+	oski := signup(t, "visible_oski")
+	stanny := signup(t, "visible_stanny")
+	tree := signup(t, "visible_tree")
+	for _, c := range []*client{oski, stanny, tree} {
+		resp, body := c.do(http.MethodPost, friendsURL+"/api/friends", nil)
+		expect(t, "add user to the graph", resp, body, http.StatusOK)
+	}
+	resp, body := oski.do(http.MethodPost, friendsURL+"/api/friends/"+stanny.userID, nil)
+	expect(t, "add friend", resp, body, http.StatusOK)
+
+	for _, visibility := range []string{"public", "friends", "private"} {
+		resp, body = oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": visibility, "visibility": visibility})
+		expect(t, "create", resp, body, http.StatusCreated)
+	}
+	//TestPosts expects to own every post
+	defer func() {
+		for _, p := range getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID) {
+			resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+p.PostID, nil)
+			expect(t, "delete", resp, body, http.StatusOK)
+		}
+	}()
+
+	bodies := func(posts []post) []string {
+		result := []string{}
+		for _, p := range posts {
+			result = append(result, p.PostBody)
+		}
+		return result
+	}
+	own := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)
+	if got := bodies(own); len(got) != 3 || own[2].Visibility != "private" {
+		t.Fatalf("expected every post of oski but got %+v", own)
+	}
+	if got := bodies(getPosts(t, stanny, postsURL+"/api/posts/user/"+oski.userID)); len(got) != 2 || got[0] != "public" || got[1] != "friends" {
+		t.Fatalf("expected the public and friends posts for a friend but got %v", got)
+	}
+	if got := bodies(getPosts(t, tree, postsURL+"/api/posts/user/"+oski.userID)); len(got) != 1 || got[0] != "public" {
+		t.Fatalf("expected the public post for a stranger but got %v", got)
+	}
+	if got := bodies(getPosts(t, stanny, postsURL+"/api/posts")); len(got) != 2 || got[0] != "friends" {
+		t.Fatalf("expected the friends and public posts in the feed of a friend but got %v", got)
+	}
+	if got := bodies(getPosts(t, tree, postsURL+"/api/posts")); len(got) != 1 || got[0] != "public" {
+		t.Fatalf("expected the public post in the discover feed but got %v", got)
+	}
+
+	resp, body = tree.do(http.MethodGet, postsURL+"/api/posts/"+own[1].PostID+"/comments", nil)
+	expectProblem(t, "comments of a hidden post", resp, body, http.StatusNotFound, postsapi.CodePostNotFound)
+	resp, body = stanny.do(http.MethodPut, postsURL+"/api/posts/"+own[2].PostID+"/reactions/like", nil)
+	expectProblem(t, "react to a private post", resp, body, http.StatusNotFound, postsapi.CodePostNotFound)
+}
+
+func TestPermalink(t *testing.T) {
+	oski := signup(t, "permalink_oski")
+	stanny := signup(t, "permalink_stanny")
+	resp, body := oski.do(http.MethodPut, profilesURL+"/api/profile/"+oski.userID, profile{Firstname: "Oski", Lastname: "Bear", Email: "oski@berkeley.edu", UUID: oski.userID})
+	expect(t, "set profile", resp, body, http.StatusOK)
+	resp, body = oski.do(http.MethodPost, postsURL+"/api/posts/create", map[string]string{"postBody": "Go Bears!"})
+	expect(t, "create", resp, body, http.StatusCreated)
+	postID := getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID)[0].PostID
+	//TestPosts expects to own every post
+	defer func() {
+		resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+postID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}()
+	resp, body = stanny.do(http.MethodPut, postsURL+"/api/posts/"+postID+"/reactions/like", nil)
+	expect(t, "react", resp, body, http.StatusOK)
+
+	//the permalink carries the author from the profiles-service and the counts
+	resp, body = stanny.do(http.MethodGet, postsURL+"/api/posts/id/"+postID, nil)
+	expect(t, "get post", resp, body, http.StatusOK)
+	got := post{}
+	decode(t, body, &got)
+	if got.PostBody != "Go Bears!" || got.Author == nil || got.Author.FirstName != "Oski" || got.Author.LastName != "Bear" || got.Reactions["like"] != 1 {
+		t.Fatalf("expected the post with its author and reactions but got %+v %+v", got, got.Author)
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	ifNoneMatch := func(etag string) int {
+		req, err := http.NewRequest(http.MethodGet, postsURL+"/api/posts/id/"+postID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", etag)
+		resp, err := stanny.http.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := ifNoneMatch(etag); status != http.StatusNotModified {
+		t.Fatalf("expected 304 for an unchanged post but was %d", status)
+	}
+	resp, body = oski.do(http.MethodPost, postsURL+"/api/posts/"+postID+"/comments", map[string]string{"body": "Thanks!"})
+	expect(t, "comment", resp, body, http.StatusCreated)
+	if status := ifNoneMatch(etag); status != http.StatusOK {
+		t.Fatalf("expected 200 after a comment but was %d", status)
+	}
+
+	resp, body = stanny.do(http.MethodGet, postsURL+"/api/posts/id/"+stanny.userID, nil)
+	expectProblem(t, "get a missing post", resp, body, http.StatusNotFound, postsapi.CodePostNotFound)
+}
@@ -0,0 +1,50 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	profilesapi "github.com/BearCloud/fa20-project-dev/backend/profile/api"
+)
+
+//profile mirrors the JSON of a profile
+type profile struct {
+	Firstname string `json:"firstName"`
+	Lastname  string `json:"lastName"`
+	Email     string `json:"email"`
+	UUID      string `json:"uuid"`
+}
+
+func TestProfiles(t *testing.T) {
+	oski := signup(t, "profiles_oski")
+	stanny := signup(t, "profiles_stanny")
+
+	for _, p := range []struct {
+		c *client
+		profile
+	}{
+		{oski, profile{Firstname: "Test", Lastname: "User", Email: "contact_email@berkeley.edu", UUID: oski.userID}},
+		{stanny, profile{Firstname: "Test", Lastname: "Usertwo", Email: "contact_email2@berkeley.edu", UUID: stanny.userID}},
+	} {
+		resp, body := p.c.do(http.MethodPut, profilesURL+"/api/profile/"+p.c.userID, p.profile)
+		expect(t, "set profile", resp, body, http.StatusOK)
+
+		resp, body = p.c.do(http.MethodGet, profilesURL+"/api/profile/"+p.c.userID, nil)
+		expect(t, "get profile", resp, body, http.StatusOK)
+		got := profile{}
+		decode(t, body, &got)
+		if got != p.profile {
+			t.Fatalf("expected %+v but got %+v", p.profile, got)
+		}
+	}
+
+	//profiles are public but only their owner changes them
+	resp, body := oski.do(http.MethodGet, profilesURL+"/api/profile/"+stanny.userID, nil)
+	expect(t, "get someone else's profile", resp, body, http.StatusOK)
+	resp, body = oski.do(http.MethodPut, profilesURL+"/api/profile/"+stanny.userID, profile{Firstname: "Hacked"})
+	expectProblem(t, "set someone else's profile", resp, body, http.StatusForbidden, problem.CodeForbidden)
+
+	resp, body = oski.do(http.MethodGet, profilesURL+"/api/profile/00000000-0000-0000-0000-000000000000", nil)
+	expectProblem(t, "get a missing profile", resp, body, http.StatusNotFound, profilesapi.CodeProfileNotFound)
+}
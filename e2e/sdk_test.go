@@ -0,0 +1,117 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"testing"
+
+	authapi "github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	profilesapi "github.com/BearCloud/fa20-project-dev/backend/profile/api"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/auth"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/posts"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/profiles"
+)
+
+//TestSDK walks through a session with the generated clients
+func TestSDK(t *testing.T) {
+	ctx := context.Background()
+	c, err := sdk.NewWithServers(sdk.Servers{Auth: authURL, Posts: postsURL, Profiles: profilesURL, Friends: friendsURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := c.Auth.CsrfTokenWithResponse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.StatusCode() != http.StatusOK || token.JSON200 == nil || token.JSON200.CsrfToken == "" {
+		t.Fatalf("expected a CSRF token but got %d: %s", token.StatusCode(), token.Body)
+	}
+
+	username, email, password := "sdk_oski", "sdk_oski@berkeley.edu", "sdk_oski-password"
+	signedUp, err := c.Auth.SignupWithResponse(ctx, auth.Credentials{Username: &username, Email: &email, Password: &password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedUp.StatusCode() != http.StatusCreated {
+		t.Fatalf("signup: expected 201 but was %d: %s", signedUp.StatusCode(), signedUp.Body)
+	}
+	again, err := c.Auth.SignupWithResponse(ctx, auth.Credentials{Username: &username, Email: &email, Password: &password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ApplicationproblemJSON409 == nil || again.ApplicationproblemJSON409.Code != authapi.CodeUsernameTaken {
+		t.Fatalf("expected the problem to be decoded but got %d: %s", again.StatusCode(), again.Body)
+	}
+	userID := (&client{t: t, jar: c.HTTP.Jar.(*cookiejar.Jar)}).accessTokenUserID()
+
+	joined, err := c.Friends.AddUserWithResponse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joined.StatusCode() != http.StatusOK {
+		t.Fatalf("add user: expected 200 but was %d: %s", joined.StatusCode(), joined.Body)
+	}
+
+	created, err := c.Posts.CreatePostWithResponse(ctx, posts.NewPost{PostBody: "generated"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.StatusCode() != http.StatusCreated {
+		t.Fatalf("create: expected 201 but was %d: %s", created.StatusCode(), created.Body)
+	}
+	listed, err := c.Posts.GetPostsWithResponse(ctx, userID, &posts.GetPostsParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if listed.JSON200 == nil || len(listed.JSON200.Posts) != 1 || listed.JSON200.Posts[0].PostBody != "generated" {
+		t.Fatalf("expected the post but got %d: %s", listed.StatusCode(), listed.Body)
+	}
+	//TestPosts expects to own every post
+	deleted, err := c.Posts.DeletePostWithResponse(ctx, listed.JSON200.Posts[0].PostID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.StatusCode() != http.StatusOK {
+		t.Fatalf("delete: expected 200 but was %d: %s", deleted.StatusCode(), deleted.Body)
+	}
+
+	missing, err := c.Profiles.GetProfileWithResponse(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing.ApplicationproblemJSON404 == nil || missing.ApplicationproblemJSON404.Code != profilesapi.CodeProfileNotFound {
+		t.Fatalf("expected no profile but got %d: %s", missing.StatusCode(), missing.Body)
+	}
+	first := "Oski"
+	updated, err := c.Profiles.UpdateProfileWithResponse(ctx, userID, profiles.Profile{FirstName: &first, Email: &email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.StatusCode() != http.StatusOK {
+		t.Fatalf("update profile: expected 200 but was %d: %s", updated.StatusCode(), updated.Body)
+	}
+	profile, err := c.Profiles.GetProfileWithResponse(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.JSON200 == nil || *profile.JSON200.FirstName != first || *profile.JSON200.Uuid != userID {
+		t.Fatalf("expected the profile but got %d: %s", profile.StatusCode(), profile.Body)
+	}
+
+	//without the session there is no access token
+	anonymous, err := sdk.NewWithServers(sdk.Servers{Auth: authURL, Posts: postsURL, Profiles: profilesURL, Friends: friendsURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := anonymous.Posts.GetFeedWithResponse(ctx, &posts.GetFeedParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.ApplicationproblemJSON401 == nil || feed.ApplicationproblemJSON401.Code != problem.CodeUnauthenticated {
+		t.Fatalf("expected a 401 but got %d: %s", feed.StatusCode(), feed.Body)
+	}
+}
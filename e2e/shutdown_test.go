@@ -0,0 +1,113 @@
+package e2e
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+)
+
+//freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started, release := make(chan bool), make(chan bool)
+	router := http.NewServeMux()
+	router.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		w.Write([]byte("done"))
+	})
+
+	checker := health.New("shutdown-service")
+	checker.Ready(router)
+	addr := freeAddr(t)
+	srv := server.New(addr, checker, server.Settings{
+		ReadHeaderTimeout: time.Second,
+		DrainDelay:        200 * time.Millisecond,
+		ShutdownTimeout:   5 * time.Second,
+	})
+	var stopped []string
+	srv.OnShutdown("database", func() error { stopped = append(stopped, "database"); return nil })
+	srv.OnShutdown("worker", func() error { stopped = append(stopped, "worker"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = srv.Start(ctx)
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(ctx) }()
+
+	c := newClient(t)
+	waitUntil(t, func() bool {
+		resp, err := c.http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	})
+
+	//a request in flight when SIGTERM arrives still completes
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body := make([]byte, 4)
+		resp.Body.Read(body)
+		slow <- string(body)
+	}()
+	<-started
+	cancel()
+
+	//load balancers see the service draining before it stops listening
+	waitUntil(t, func() bool {
+		resp, err := c.http.Get("http://" + addr + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	})
+	release <- true
+	if body := <-slow; body != "done" {
+		t.Fatalf("the request in flight was cut off: %s", body)
+	}
+
+	err := <-runErr
+	if err != nil {
+		t.Fatalf("expected a clean shutdown but got %v", err)
+	}
+	if !reflect.DeepEqual(stopped, []string{"worker", "database"}) {
+		t.Fatalf("expected the worker to stop before the database closes, got %v", stopped)
+	}
+	if _, err := http.Get("http://" + addr + "/healthz"); err == nil {
+		t.Fatal("the server still listens after the shutdown")
+	}
+}
+
+func waitUntil(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
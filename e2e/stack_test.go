@@ -0,0 +1,388 @@
+package e2e
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	authapi "github.com/BearCloud/fa20-project-dev/backend/auth-service/api"
+	authmigrations "github.com/BearCloud/fa20-project-dev/backend/auth-service/migrations"
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/migrate"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+	friendsapi "github.com/BearCloud/fa20-project-dev/backend/friends/api"
+	postsapi "github.com/BearCloud/fa20-project-dev/backend/posts/api"
+	postsmigrations "github.com/BearCloud/fa20-project-dev/backend/posts/migrations"
+	profilesapi "github.com/BearCloud/fa20-project-dev/backend/profile/api"
+	profilesmigrations "github.com/BearCloud/fa20-project-dev/backend/profile/migrations"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const jwtSecret = "e2e-jwt-secret"
+
+//the running services, shared by every test of the package
+var (
+	authURL     string
+	postsURL    string
+	profilesURL string
+	friendsURL  string
+
+	mailer   = &fakeMailer{}
+	friendDB *graph
+
+	//spans are the spans of every service, exported once traces is flushed
+	spans  = tracetest.NewInMemoryExporter()
+	traces = tracing.NewProvider("e2e", 1, spans)
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+
+	stop, err := startServices()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "starting the services:", err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	stop()
+	os.Exit(code)
+}
+
+//startServices starts every service like its main function does and returns a function stopping them all
+func startServices() (func(), error) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	mysqlAddr, stopMySQL, err := startMySQL()
+	if err != nil {
+		return nil, err
+	}
+	stops = append(stops, stopMySQL)
+	dsn := func(name string) string { return "root@tcp(" + mysqlAddr + ")/" + name + "?parseTime=true" }
+
+	var graphServer *httptest.Server
+	friendDB, graphServer = startGraph()
+	stops = append(stops, graphServer.Close)
+
+	//settings that have no default in development
+	os.Setenv("JWT_SECRET", jwtSecret)
+	os.Setenv("SENDGRID_KEY", "unused")
+	os.Setenv("NEPTUNE_URL", graphServer.URL)
+	tracing.Install(traces)
+
+	authCfg := authapi.Config{}
+	postsCfg := postsapi.Config{}
+	profilesCfg := profilesapi.Config{}
+	friendsCfg := friendsapi.Config{}
+	for _, cfg := range []interface{}{&authCfg, &postsCfg, &profilesCfg, &friendsCfg} {
+		err = config.Load(cfg)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+	}
+
+	//auth-service
+	err = authapi.Configure(authCfg)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	authDB, err := openDB(authapi.InitDB, dsn("auth"), authCfg.Settings, authmigrations.FS)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	stops = append(stops, func() { authDB.Close() })
+	router := mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(authCfg.CORSOrigins, "GET, POST, OPTIONS"))
+	router.Use(authapi.CSRF)
+	router.Handle("/metrics", metrics.Handler())
+	authapi.RegisterRoutes(router, authapi.NewMySQLUserStore(authDB), mailer)
+	checker := health.New("auth-service")
+	checker.AddCheck("mysql", authDB.PingContext)
+	checker.Ready(router)
+	authURL = serve(checker, &stops)
+
+	//friends
+	friendsapi.Configure(friendsCfg)
+	router = mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(friendsCfg.CORSOrigins, "GET, POST, DELETE, OPTIONS"))
+	router.Use(csrf.New([]byte(friendsCfg.CSRFSecret), friendsCfg.Cookies()).Protect)
+	neptune := friendsapi.NewNeptuneGraph(friendsCfg.NeptuneURL)
+	friendsapi.RegisterRoutes(router, neptune)
+	checker = health.New("friends-service")
+	checker.AddCheck("graph", neptune.Ping)
+	checker.Ready(router)
+	friendsURL = serve(checker, &stops)
+
+	//profiles
+	profilesapi.Configure(profilesCfg)
+	profilesDB, err := openDB(profilesapi.InitDB, dsn("profiles"), profilesCfg.Settings, profilesmigrations.FS)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	stops = append(stops, func() { profilesDB.Close() })
+	router = mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(profilesCfg.CORSOrigins, "GET, PUT, OPTIONS"))
+	router.Use(csrf.New([]byte(profilesCfg.CSRFSecret), profilesCfg.Cookies()).Protect)
+	profilesapi.RegisterRoutes(router, profilesapi.NewMySQLProfileStore(profilesDB))
+	checker = health.New("profiles-service")
+	checker.AddCheck("mysql", profilesDB.PingContext)
+	checker.Ready(router)
+	profilesURL = serve(checker, &stops)
+
+	//posts
+	postsapi.Configure(postsCfg)
+	postsDB, err := openDB(postsapi.InitDB, dsn("postsDB"), postsCfg.Settings, postsmigrations.FS)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	stops = append(stops, func() { postsDB.Close() })
+	router = mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(postsCfg.CORSOrigins, "GET, POST, PUT, DELETE, OPTIONS"))
+	router.Use(csrf.New([]byte(postsCfg.CSRFSecret), postsCfg.Cookies()).Protect)
+	//the home feed asks the friends-service started above, and is read from the timelines,
+	//the authors of the posts come from the profiles-service
+	postsCfg.FriendsURL = friendsURL
+	postsCfg.ProfilesURL = profilesURL
+	postsapi.RegisterRoutes(router, postsapi.NewMySQLPostStore(postsDB), postsCfg.Friends(), postsapi.NewMySQLTimelines(postsDB), postsCfg.Profiles())
+	stops = append(stops, func() { postsapi.WaitFanOuts() })
+	checker = health.New("posts-service")
+	checker.AddCheck("mysql", postsDB.PingContext)
+	checker.Ready(router)
+	postsURL = serve(checker, &stops)
+
+	return stop, nil
+}
+
+//openDB connects a service to its database and migrates it, like the main functions do
+func openDB(initDB func(context.Context, string, database.Settings) (*sql.DB, error), dsn string, settings database.Settings, migrations fs.FS) (*sql.DB, error) {
+	db, err := initDB(context.Background(), dsn, settings)
+	if err != nil {
+		return nil, err
+	}
+	err = migrate.Up(db, migrations)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func serve(handler http.Handler, stops *[]func()) string {
+	server := httptest.NewServer(handler)
+	*stops = append(*stops, server.Close)
+	return server.URL
+}
+
+//fakeMailer records the emails of auth-service instead of sending them
+type fakeMailer struct {
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (m *fakeMailer) SendEmail(recipient string, subject string, templatePath string, data map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.tokens == nil {
+		m.tokens = make(map[string]string)
+	}
+	token, _ := data["Token"].(string)
+	m.tokens[recipient+" "+templatePath] = token
+	return nil
+}
+
+//token returns the token of the last email sent to recipient with the template
+func (m *fakeMailer) token(t *testing.T, recipient string, templatePath string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, ok := m.tokens[recipient+" "+templatePath]
+	if !ok {
+		t.Fatalf("no %s email was sent to %s", templatePath, recipient)
+	}
+	return token
+}
+
+//client is a browser-like client, it keeps the cookies of every service and echoes the CSRF token
+type client struct {
+	t      *testing.T
+	jar    *cookiejar.Jar
+	http   *http.Client
+	userID string
+}
+
+func newClient(t *testing.T) *client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &client{t: t, jar: jar, http: &http.Client{Jar: jar}}
+}
+
+//signup creates a user named username and returns a client signed in as that user
+func signup(t *testing.T, username string) *client {
+	t.Helper()
+	c := newClient(t)
+	resp, body := c.do(http.MethodPost, authURL+"/api/auth/signup", map[string]string{
+		"username": username,
+		"email":    username + "@berkeley.edu",
+		"password": username + "-password",
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("signup %s: expected 201 but was %d: %s", username, resp.StatusCode, body)
+	}
+	c.userID = c.accessTokenUserID()
+	c.fetchCSRFToken()
+	return c
+}
+
+//fetchCSRFToken asks auth-service for the CSRF cookie sent along with every mutation
+func (c *client) fetchCSRFToken() {
+	c.t.Helper()
+	resp, body := c.do(http.MethodGet, authURL+"/api/auth/csrf", nil)
+	if resp.StatusCode != http.StatusOK {
+		c.t.Fatalf("csrf: expected 200 but was %d: %s", resp.StatusCode, body)
+	}
+}
+
+func (c *client) cookie(name string) string {
+	u, _ := url.Parse(authURL)
+	for _, cookie := range c.jar.Cookies(u) {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func (c *client) accessTokenUserID() string {
+	c.t.Helper()
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(c.cookie("access_token"), claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		c.t.Fatalf("parsing the access token: %v", err)
+	}
+	userID, _ := claims["UserID"].(string)
+	return userID
+}
+
+//do sends body as JSON, a string body is sent as is, and returns the response with its body
+func (c *client) do(method string, url string, body interface{}) (*http.Response, []byte) {
+	c.t.Helper()
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = strings.NewReader(string(encoded))
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := c.cookie(csrf.CookieName); token != "" {
+		req.Header.Set(csrf.HeaderName, token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp, respBody
+}
+
+//expect fails the test unless resp has the status
+func expect(t *testing.T, what string, resp *http.Response, body []byte, status int) {
+	t.Helper()
+	if resp.StatusCode != status {
+		t.Fatalf("%s: expected status %d but was %d: %s", what, status, resp.StatusCode, body)
+	}
+}
+
+//expectProblem checks that the response is the application/problem+json error with status and code
+func expectProblem(t *testing.T, what string, resp *http.Response, body []byte, status int, code string) problem.Problem {
+	t.Helper()
+	expect(t, what, resp, body, status)
+	if contentType := resp.Header.Get("Content-Type"); contentType != problem.ContentType {
+		t.Fatalf("%s: expected a problem but the content type was %q", what, contentType)
+	}
+	p := problem.Problem{}
+	err := json.Unmarshal(body, &p)
+	if err != nil {
+		t.Fatalf("%s: %v in %s", what, err, body)
+	}
+	if p.Status != status || p.Code != code || p.Instance != resp.Request.URL.Path || p.RequestID == "" {
+		t.Fatalf("%s: expected a %d problem with the code %s but got %s", what, status, code, body)
+	}
+	return p
+}
+
+func decode(t *testing.T, body []byte, target interface{}) {
+	t.Helper()
+	err := json.Unmarshal(body, target)
+	if err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+}
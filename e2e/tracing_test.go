@@ -0,0 +1,140 @@
+package e2e
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//callerSpan is the span ID of the caller in the traceparent headers of the tests
+const callerSpan = "00f067aa0ba902b7"
+
+//traced sends a request continuing the trace traceID of a caller, sampled or not
+func traced(t *testing.T, c *client, method string, url string, body string, traceID string, sampled bool) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	req.Header.Set("traceparent", "00-"+traceID+"-"+callerSpan+"-"+flags)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(csrf.HeaderName, c.cookie(csrf.CookieName))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+//traceSpans returns the spans exported for traceID
+func traceSpans(t *testing.T, traceID string) []tracetest.SpanStub {
+	t.Helper()
+	err := traces.ForceFlush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := []tracetest.SpanStub{}
+	for _, span := range spans.GetSpans() {
+		if span.SpanContext.TraceID().String() == traceID {
+			result = append(result, span)
+		}
+	}
+	return result
+}
+
+func TestTraceReachesTheGraph(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	oski := signup(t, "tracing_oski")
+
+	resp := traced(t, oski, http.MethodPost, friendsURL+"/api/friends", "", traceID, true)
+	expect(t, "add user", resp, nil, http.StatusOK)
+
+	var server, client *tracetest.SpanStub
+	found := traceSpans(t, traceID)
+	for i, span := range found {
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			server = &found[i]
+		case trace.SpanKindClient:
+			client = &found[i]
+		}
+	}
+	if server == nil || client == nil {
+		t.Fatalf("expected a server and a client span but got %+v", found)
+	}
+	if server.Name != "POST /api/friends" || server.Parent.SpanID().String() != callerSpan {
+		t.Errorf("expected the server span to be named after the route and continue the caller, got %q with parent %s", server.Name, server.Parent.SpanID())
+	}
+	if client.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("expected the gremlin query to be a child of the request")
+	}
+
+	friendDB.mu.Lock()
+	last := friendDB.traceparents[len(friendDB.traceparents)-1]
+	friendDB.mu.Unlock()
+	if last != "00-"+traceID+"-"+client.SpanContext.SpanID().String()+"-01" {
+		t.Fatalf("expected the gremlin query to carry the trace but got %q", last)
+	}
+}
+
+func TestQueriesAreTraced(t *testing.T) {
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+	oski := signup(t, "tracing_stanny")
+
+	resp := traced(t, oski, http.MethodPost, postsURL+"/api/posts/create", `{"postBody": "traced"}`, traceID, true)
+	expect(t, "create", resp, nil, http.StatusCreated)
+	//TestPosts expects to own every post
+	for _, p := range getPosts(t, oski, postsURL+"/api/posts/user/"+oski.userID) {
+		resp, body := oski.do(http.MethodDelete, postsURL+"/api/posts/delete/"+p.PostID, nil)
+		expect(t, "delete", resp, body, http.StatusOK)
+	}
+
+	queries := 0
+	for _, span := range traceSpans(t, traceID) {
+		if strings.Contains(span.InstrumentationScope.Name, "otelsql") {
+			queries++
+		}
+	}
+	if queries == 0 {
+		t.Fatal("expected the insert to be a span of the request")
+	}
+
+	//the caller decided not to sample
+	const unsampled = "5c8b4e0e7ea5a3f8d2c1b0a9f8e7d6c5"
+	resp = traced(t, oski, http.MethodGet, postsURL+"/api/posts/user/"+oski.userID, "", unsampled, false)
+	expect(t, "get posts", resp, nil, http.StatusOK)
+	if found := traceSpans(t, unsampled); len(found) != 0 {
+		t.Fatalf("expected no span for an unsampled trace but got %d", len(found))
+	}
+}
+
+func TestLogsCarryTheTrace(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "e2e", logging.Settings{Level: "info", Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex(callerSpan)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	logger.InfoContext(ctx, "traced")
+	for _, expected := range []string{`"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`, `"span_id":"` + callerSpan + `"`} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %s in %s", expected, out.String())
+		}
+	}
+}
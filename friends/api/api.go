@@ -1,17 +1,20 @@
 package api
 
 import (
-	"net/http"
-	"github.com/gorilla/mux"
-	"log"
-	"bytes"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
+	"github.com/gorilla/mux"
 )
 
-const NeptuneURL = "https://<your_neptune_writer_endpoint>:8182/gremlin"
+//RegisterRoutes initializes the api endpoints, the handlers keep the friendships in graph
+func RegisterRoutes(router *mux.Router, graph Graph) error {
+	friends = graph
 
-func RegisterRoutes(router *mux.Router) error {
 	router.HandleFunc("/api/friends/{uuid}", areFriends).Methods(http.MethodGet, http.MethodOptions)
 	router.HandleFunc("/api/friends/{uuid}", addFriend).Methods(http.MethodPost, http.MethodOptions)
 	// router.HandleFunc("/api/friends/{uuid}", deleteFriend).Methods(http.MethodDelete)
@@ -22,108 +25,106 @@ func RegisterRoutes(router *mux.Router) error {
 	return nil
 }
 
-func getUUID (w http.ResponseWriter, r *http.Request) (uuid string) {
+//getUUID returns the userID of the access_token, ok is false when an error was already written to w
+func getUUID(w http.ResponseWriter, r *http.Request) (uuid string, ok bool) {
 	cookie, err := r.Cookie("access_token")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated, "sign in first, the access_token cookie is missing")
+		return "", false
 	}
 	//validate the cookie
 	claims, err := ValidateToken(cookie.Value)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		log.Print(err.Error())
+		slog.WarnContext(r.Context(), "error validating token", "err", err)
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "invalid or expired access token")
+		return "", false
 	}
-	log.Println(claims)
 
-	
-
-	return claims["UserID"].(string)
+	userID, ok := claims["UserID"].(string)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "the token has no UserID")
+		return "", false
+	}
+	return userID, true
 }
 
-func getFriends (w http.ResponseWriter, r *http.Request) {
-	uuid := getUUID(w, r)
-	gq := "g.V().has('uuid', '" + uuid + "').out('friends with').values('uuid')"
-	response, err := makeNeptuneRequest(gq)
-	// var req_body map[string]string
-	// req_body["gremlin"] = "g.V().has('uuid', '" + uuid + "').out('friends with').values('uuid')"
-
-	// jsonValue, _ := json.Marshal(req_body)
-
-	// resp, err := http.Post(NeptuneURL, "application/json", bytes.NewBuffer(jsonValue))
-	// defer resp.Body.Close()
+//otherUUID returns the uuid of the path, ok is false when it isn't a uuid and a 400 was written to w
+func otherUUID(w http.ResponseWriter, r *http.Request) (uuid string, ok bool) {
+	uuid = mux.Vars(r)["uuid"]
+	fields := problem.Fields{}
+	validate.Var(&fields, "uuid", uuid, "uuid")
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return "", false
+	}
+	return uuid, true
+}
 
-	// var response map[string]interface{}
-	
-	// err = json.NewDecoder(resp.Body).Decode(&response)
+func getFriends(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	values, err := friends.Friends(r.Context(), uuid)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, "error listing the friends", err)
 		return
 	}
 
-	result := response["result"].(map[string]interface{})
-	data := result["data"].(map[string]interface{})
-	values := data["@value"].([]interface{})
-
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(values)
-	return
 }
 
 func areFriends(w http.ResponseWriter, r *http.Request) {
-	otherUUID := mux.Vars(r)["uuid"]
-	uuid := getUUID(w, r)
-	gq := "g.V().has('uuid', '" + uuid + "').outE('friends with').where(otherV().has('uuid', '" + otherUUID + "')).count()"
-	response, err := makeNeptuneRequest(gq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	other, ok := otherUUID(w, r)
+	if !ok {
 		return
 	}
-
-	result := response["result"].(map[string]interface{})
-	data := result["data"].(map[string]interface{})
-	values := data["@value"].([]interface{})
-	value := values[0].(map[string]interface{})
-	edges := value["@value"].(float64)
-
-	if edges < 1 {
-		fmt.Fprint(w, false)
-	} else {
-		fmt.Fprint(w, true)
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	friendly, err := friends.AreFriends(r.Context(), uuid, other)
+	if err != nil {
+		problem.Internal(w, r, "error checking the friendship", err)
+		return
 	}
 
-	return
-
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, friendly)
 }
 
 func addFriend(w http.ResponseWriter, r *http.Request) {
-	otherUUID := mux.Vars(r)["uuid"]
-	uuid := getUUID(w, r)
-	gq := "g.addE('friends with').from(g.V().has('uuid', '" + uuid + "')).to(g.V().has('uuid', '" + otherUUID + "'))"
-	_, err := makeNeptuneRequest(gq)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	other, ok := otherUUID(w, r)
+	if !ok {
+		return
+	}
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	err := friends.AddFriend(r.Context(), uuid, other)
+	if err == ErrUserNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodeUserNotFound, "both users must have joined the friends graph")
 		return
 	}
-
-	gq = "g.addE('friends with').from(g.V().has('uuid', '" + otherUUID + "')).to(g.V().has('uuid', '" + uuid + "'))"
-	_, err = makeNeptuneRequest(gq)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, "error adding the friend", err)
 		return
 	}
-	return
+	friendshipsAdded.Inc()
 }
 
-func addUser (w http.ResponseWriter, r *http.Request) {
-	uuid := getUUID(w, r)
-	gq := "g.addV().property('uuid', '" + uuid + "')"
-	_, err := makeNeptuneRequest(gq)
+func addUser(w http.ResponseWriter, r *http.Request) {
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	err := friends.AddUser(r.Context(), uuid)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, "error adding the user", err)
 		return
 	}
-	
-	return
 }
 
 // func deleteFriend(w http.ResponseWriter, r *http.Request) {
@@ -148,20 +149,3 @@ func addUser (w http.ResponseWriter, r *http.Request) {
 // 	}
 // 	json.NewEncoder(w).Encode(isFriend[0].Result.Data)
 // }
-
-func makeNeptuneRequest(gremlinQuery string) (map[string]interface{}, error) {
-	req_body := make(map[string]string)
-	req_body["gremlin"] = gremlinQuery
-	jsonValue, _ := json.Marshal(req_body)
-	resp, err := http.Post(NeptuneURL, "application/json", bytes.NewBuffer(jsonValue))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	response := make(map[string]interface{})
-	err = json.NewDecoder(resp.Body).Decode(&response)
-	if err != nil {
-		return nil, err
-	}
-	return response, nil
-}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	oski   = "5b1c9a36-5f0e-4c1b-9a55-8f7d3c1f4b2e"
+	stanny = "0d4b1b5e-2a3c-4f6e-8d9a-1c2b3d4e5f60"
+)
+
+//newFriendsServer starts every friends route against an in-memory graph
+func newFriendsServer(t *testing.T) *httptest.Server {
+	router := mux.NewRouter()
+	err := RegisterRoutes(router, NewMemoryGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every response must match openapi.yaml
+	server := httptest.NewServer(openapi.Validate(doc, router, func(err error) { t.Error(err) }))
+	t.Cleanup(server.Close)
+	return server
+}
+
+//do sends a request as userID, an empty userID sends no access_token
+func do(t *testing.T, method string, url string, userID string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "" {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"UserID": userID,
+			"exp":    time.Now().Add(time.Minute).Unix(),
+		}).SignedString(jwtKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, strings.TrimSpace(string(body))
+}
+
+func TestFriendship(t *testing.T) {
+	server := newFriendsServer(t)
+
+	for _, userID := range []string{oski, stanny} {
+		if resp, body := do(t, http.MethodPost, server.URL+"/api/friends", userID); resp.StatusCode != http.StatusOK {
+			t.Fatalf("add user: expected 200 but was %d: %s", resp.StatusCode, body)
+		}
+	}
+	if _, body := do(t, http.MethodGet, server.URL+"/api/friends/"+stanny, oski); body != "false" {
+		t.Fatalf("expected strangers but got %s", body)
+	}
+
+	if resp, body := do(t, http.MethodPost, server.URL+"/api/friends/"+stanny, oski); resp.StatusCode != http.StatusOK {
+		t.Fatalf("add friend: expected 200 but was %d: %s", resp.StatusCode, body)
+	}
+	if _, body := do(t, http.MethodGet, server.URL+"/api/friends/"+oski, stanny); body != "true" {
+		t.Fatalf("expected the friendship to go both ways but got %s", body)
+	}
+
+	_, body := do(t, http.MethodGet, server.URL+"/api/friends", stanny)
+	list := []string{}
+	json.Unmarshal([]byte(body), &list)
+	if len(list) != 1 || list[0] != oski {
+		t.Fatalf("expected [%s] but got %s", oski, body)
+	}
+}
+
+func TestAddFriendErrors(t *testing.T) {
+	server := newFriendsServer(t)
+	do(t, http.MethodPost, server.URL+"/api/friends", oski)
+
+	if resp, _ := do(t, http.MethodPost, server.URL+"/api/friends/"+stanny, oski); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 when befriending an unknown user but was %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodGet, server.URL+"/api/friends", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		resp, body := do(t, method, server.URL+"/api/friends/x')).drop()", oski)
+		if resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"field":"uuid"`) {
+			t.Fatalf("%s: expected 400 for a path that isn't a uuid but was %d: %s", method, resp.StatusCode, body)
+		}
+	}
+}
+
+func TestNeptuneBindsTheUUIDs(t *testing.T) {
+	var requests []map[string]interface{}
+	neptune := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		w.Write([]byte(`{"result":{"data":{"@type":"g:List","@value":[]}}}`))
+	}))
+	defer neptune.Close()
+
+	err := NewNeptuneGraph(neptune.URL).AddFriend(context.Background(), oski, stanny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected an edge in each direction but got %v", requests)
+	}
+	for _, request := range requests {
+		query, _ := request["gremlin"].(string)
+		if strings.Contains(query, oski) || strings.Contains(query, stanny) {
+			t.Fatalf("expected the uuids to be bound, not part of the query %q", query)
+		}
+	}
+	bindings, _ := requests[1]["bindings"].(map[string]interface{})
+	if bindings["from"] != stanny || bindings["to"] != oski {
+		t.Fatalf("expected the reverse edge to bind from and to but got %v", requests[1])
+	}
+}
+
+//TestOpenAPI checks that openapi.yaml documents exactly the routes of RegisterRoutes
+func TestOpenAPI(t *testing.T) {
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	err = RegisterRoutes(router, NewMemoryGraph())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openapi.CheckRoutes(doc, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,15 @@
+package api
+
+import "github.com/BearCloud/fa20-project-dev/backend/common/config"
+
+//Config holds the friends-service settings, see the config package for how they are loaded
+type Config struct {
+	config.Common `yaml:",inline"`
+
+	NeptuneURL string `env:"NEPTUNE_URL" yaml:"neptune_url" required:"true"`
+}
+
+//Configure applies cfg to the api package
+func Configure(cfg Config) {
+	jwtKey = []byte(cfg.JWTSecret)
+}
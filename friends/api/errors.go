@@ -0,0 +1,6 @@
+package api
+
+//The error codes of the friends service, next to the shared ones of the problem package
+const (
+	CodeUserNotFound = "user_not_found"
+)
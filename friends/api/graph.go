@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+)
+
+//ErrUserNotFound is returned by a Graph when a user has no vertex
+var ErrUserNotFound = errors.New("user not found")
+
+//Graph holds the users and the friendships between them
+type Graph interface {
+	//AddUser adds the vertex of uuid
+	AddUser(ctx context.Context, uuid string) error
+	//AddFriend connects uuid and otherUUID in both directions
+	AddFriend(ctx context.Context, uuid string, otherUUID string) error
+	//AreFriends reports whether uuid is friends with otherUUID
+	AreFriends(ctx context.Context, uuid string, otherUUID string) (bool, error)
+	//Friends returns the uuids of every friend of uuid
+	Friends(ctx context.Context, uuid string) ([]string, error)
+}
+
+//friends is the graph used by the handlers, set by RegisterRoutes
+var friends Graph
+
+//bindings are the variables of a gremlin query, sent next to it
+type bindings map[string]string
+
+//addFriendship adds the edge going from one user to the other
+const addFriendship = "g.addE('friends with').from(g.V().has('uuid', from)).to(g.V().has('uuid', to))"
+
+//NeptuneGraph keeps the graph in a Neptune cluster, queried with gremlin over HTTP
+type NeptuneGraph struct {
+	url string
+}
+
+//NewNeptuneGraph creates a Graph sending its queries to the gremlin endpoint url
+func NewNeptuneGraph(url string) *NeptuneGraph {
+	return &NeptuneGraph{url: url}
+}
+
+func (g *NeptuneGraph) AddUser(ctx context.Context, uuid string) error {
+	defer metrics.TimeQuery("graph", "AddUser")()
+	_, err := g.query(ctx, "g.addV().property('uuid', uuid)", bindings{"uuid": uuid})
+	return err
+}
+
+func (g *NeptuneGraph) AddFriend(ctx context.Context, uuid string, otherUUID string) error {
+	defer metrics.TimeQuery("graph", "AddFriend")()
+	_, err := g.query(ctx, addFriendship, bindings{"from": uuid, "to": otherUUID})
+	if err != nil {
+		return err
+	}
+	_, err = g.query(ctx, addFriendship, bindings{"from": otherUUID, "to": uuid})
+	return err
+}
+
+func (g *NeptuneGraph) AreFriends(ctx context.Context, uuid string, otherUUID string) (bool, error) {
+	defer metrics.TimeQuery("graph", "AreFriends")()
+	values, err := g.query(ctx, "g.V().has('uuid', uuid).outE('friends with').where(otherV().has('uuid', other)).count()", bindings{"uuid": uuid, "other": otherUUID})
+	if err != nil {
+		return false, err
+	}
+	if len(values) == 0 {
+		return false, errors.New("neptune returned no count")
+	}
+	value, _ := values[0].(map[string]interface{})
+	edges, ok := value["@value"].(float64)
+	if !ok {
+		return false, fmt.Errorf("unexpected count %v", values[0])
+	}
+	return edges >= 1, nil
+}
+
+func (g *NeptuneGraph) Friends(ctx context.Context, uuid string) ([]string, error) {
+	defer metrics.TimeQuery("graph", "Friends")()
+	values, err := g.query(ctx, "g.V().has('uuid', uuid).out('friends with').values('uuid')", bindings{"uuid": uuid})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		friend, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected uuid %v", value)
+		}
+		result = append(result, friend)
+	}
+	return result, nil
+}
+
+//Ping checks that the gremlin endpoint answers queries
+func (g *NeptuneGraph) Ping(ctx context.Context) error {
+	_, err := g.query(ctx, "g.V().limit(1).count()", nil)
+	return err
+}
+
+//query runs gremlinQuery with the values of its variables and returns the values of its GraphSON
+//result list. The values are never part of the query string, so a uuid can't change the traversal.
+func (g *NeptuneGraph) query(ctx context.Context, gremlinQuery string, variables bindings) ([]interface{}, error) {
+	response, err := makeNeptuneRequest(ctx, g.url, gremlinQuery, variables)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := response["result"].(map[string]interface{})
+	data, _ := result["data"].(map[string]interface{})
+	values, ok := data["@value"].([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected response from neptune")
+	}
+	return values, nil
+}
+
+func makeNeptuneRequest(ctx context.Context, url string, gremlinQuery string, variables bindings) (map[string]interface{}, error) {
+	req_body := make(map[string]interface{})
+	req_body["gremlin"] = gremlinQuery
+	if len(variables) > 0 {
+		req_body["bindings"] = variables
+	}
+	jsonValue, _ := json.Marshal(req_body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	logging.PropagateRequestID(req)
+	resp, err := tracing.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("neptune returned %d: %s", resp.StatusCode, message)
+	}
+	response := make(map[string]interface{})
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+//MemoryGraph is an in-memory Graph, used for tests and local development
+type MemoryGraph struct {
+	mu      sync.Mutex
+	friends map[string]map[string]bool
+}
+
+//NewMemoryGraph creates an empty MemoryGraph
+func NewMemoryGraph() *MemoryGraph {
+	return &MemoryGraph{friends: make(map[string]map[string]bool)}
+}
+
+func (g *MemoryGraph) AddUser(ctx context.Context, uuid string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.friends[uuid]; !ok {
+		g.friends[uuid] = make(map[string]bool)
+	}
+	return nil
+}
+
+func (g *MemoryGraph) AddFriend(ctx context.Context, uuid string, otherUUID string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.friends[uuid] == nil || g.friends[otherUUID] == nil {
+		return ErrUserNotFound
+	}
+	g.friends[uuid][otherUUID] = true
+	g.friends[otherUUID][uuid] = true
+	return nil
+}
+
+func (g *MemoryGraph) AreFriends(ctx context.Context, uuid string, otherUUID string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.friends[uuid][otherUUID], nil
+}
+
+func (g *MemoryGraph) Friends(ctx context.Context, uuid string) ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	result := []string{}
+	for friend := range g.friends[uuid] {
+		result = append(result, friend)
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,12 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+//the business metrics of friends, the HTTP and graph ones come from the metrics package
+var friendshipsAdded = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "bearchat_friendships_added_total",
+	Help: "Friendships added.",
+})
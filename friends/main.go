@@ -3,43 +3,67 @@ package main
 import (
 	"log"
 	_ "log"
-	"net/http"
-	_ "net/http"
 
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
 	"github.com/BearCloud/fa20-project-dev/backend/friends/api"
 	"github.com/gorilla/mux"
 )
 
 func main() {
 
+	//Load the configuration from the environment, .env and CONFIG_FILE
+	cfg := api.Config{}
+	config.MustLoad("friends-service", &cfg)
+	api.Configure(cfg)
+
 	// Create a new mux for routing api calls
 	router := mux.NewRouter()
-	router.Use(CORS)
-	
-	err := api.RegisterRoutes(router)
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(cfg.CORSOrigins, "GET, POST, DELETE, OPTIONS"))
+	router.Use(csrf.New([]byte(cfg.CSRFSecret), cfg.Cookies()).Protect)
+
+	//Prometheus metrics, the gateway doesn't forward this path
+	router.Handle("/metrics", metrics.Handler())
+
+	//the specification of the API on /openapi.yaml and /openapi.json
+	openapi.Routes(router, api.OpenAPI)
+
+	graph := api.NewNeptuneGraph(cfg.NeptuneURL)
+	err := api.RegisterRoutes(router, graph)
 	if err != nil {
 		log.Fatal("Error registering API endpoints")
 	}
 
-	http.ListenAndServe(":80", router)
-}
+	//readiness follows the reachability of the graph
+	checker := health.New("friends-service")
+	checker.AddCheck("graph", graph.Ping)
+	checker.Ready(router)
+
+	//SIGTERM drains the requests in flight before exiting
+	ctx, stop := server.SignalContext()
+	defer stop()
+	srv := server.New(cfg.ListenAddr, checker, cfg.Server)
 
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		// Set headers
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Origin", "localhost:3000")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		// Next
-		next.ServeHTTP(w, r)
-		return
-	})
-}
\ No newline at end of file
+	//export the spans of TRACING_EXPORTER, the last ones once everything else has stopped
+	flushTraces, err := tracing.Setup(ctx, "friends-service", cfg.Trace)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	srv.OnShutdown("tracing", flushTraces)
+	err = srv.Run(srv.Start(ctx))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import "github.com/gorilla/mux"
+
+//PinnedCookie names the cookie remembering the auth-service instance of a client's passkey ceremonies
+const PinnedCookie = "auth_instance"
+
+//RegisterRoutes forwards the api of every service to its instances
+func RegisterRoutes(router *mux.Router, cfg Config) error {
+	routes := []struct {
+		prefix   string
+		backends []string
+		//pinned is the path under prefix whose requests of a client all go to one instance
+		pinned string
+	}{
+		//auth-service keeps the passkey sessions in memory between begin and finish
+		{"/api/auth", cfg.AuthBackends, "/api/auth/passkey/"},
+		{"/api/posts", cfg.PostsBackends, ""},
+		{"/api/profile", cfg.ProfilesBackends, ""},
+		{"/api/friends", cfg.FriendsBackends, ""},
+	}
+	for _, route := range routes {
+		pool, err := NewPool(route.backends, cfg.BackendFailTimeout)
+		if err != nil {
+			return err
+		}
+		if route.pinned != "" {
+			router.PathPrefix(route.pinned).Handler(pool.Pinned(PinnedCookie, route.pinned, cfg.Cookies()))
+		}
+		router.Path(route.prefix).Handler(pool)
+		router.PathPrefix(route.prefix + "/").Handler(pool)
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+//instance is a fake service instance answering with its name and the path it received
+func instance(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//the services set their own CORS headers, the gateway must drop them
+		w.Header().Set("Access-Control-Allow-Origin", "http://evil.example")
+		io.ReadAll(r.Body)
+		w.Write([]byte(name + " " + r.URL.Path))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+//newGateway starts a gateway in front of the given instances like main does
+func newGateway(t *testing.T, cfg Config) *httptest.Server {
+	if cfg.BackendFailTimeout == 0 {
+		cfg.BackendFailTimeout = time.Minute
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit, cfg.RateBurst = 1000, 1000
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	for _, backends := range []*[]string{&cfg.AuthBackends, &cfg.PostsBackends, &cfg.ProfilesBackends, &cfg.FriendsBackends} {
+		if len(*backends) == 0 {
+			*backends = []string{instance(t, "default").URL}
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(cors.Middleware([]string{"http://localhost:3000"}, "GET, POST, PUT, DELETE, OPTIONS"))
+	router.Use(LimitBody(cfg.MaxBodyBytes))
+	router.Use(NewRateLimiter(cfg.RateLimit, cfg.RateBurst).Middleware)
+	router.Use(Identity)
+	err := RegisterRoutes(router, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func send(t *testing.T, req *http.Request) (*http.Response, string) {
+	t.Helper()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	return send(t, req)
+}
+
+func accessToken(t *testing.T, userID string, expiresIn time.Duration) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"UserID": userID,
+		"exp":    time.Now().Add(expiresIn).Unix(),
+	}).SignedString(jwtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRoutesToEachService(t *testing.T) {
+	gateway := newGateway(t, Config{
+		AuthBackends:     []string{instance(t, "auth").URL},
+		PostsBackends:    []string{instance(t, "posts").URL},
+		ProfilesBackends: []string{instance(t, "profiles").URL},
+		FriendsBackends:  []string{instance(t, "friends").URL},
+	})
+
+	for path, expected := range map[string]string{
+		"/api/auth/signin":  "auth /api/auth/signin",
+		"/api/posts/0":      "posts /api/posts/0",
+		"/api/profile/oski": "profiles /api/profile/oski",
+		"/api/friends":      "friends /api/friends",
+		"/api/friends/stan": "friends /api/friends/stan",
+	} {
+		_, body := get(t, gateway.URL+path)
+		if strings.TrimSpace(body) != expected {
+			t.Errorf("GET %s: expected %q but got %q", path, expected, body)
+		}
+	}
+
+	resp, _ := get(t, gateway.URL+"/api/unknown")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown service but was %d", resp.StatusCode)
+	}
+}
+
+func TestLoadBalancesAndSkipsDownInstances(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	gateway := newGateway(t, Config{
+		PostsBackends: []string{instance(t, "one").URL, instance(t, "two").URL, down.URL},
+	})
+
+	seen := map[string]int{}
+	failures := 0
+	for i := 0; i < 12; i++ {
+		resp, body := get(t, gateway.URL+"/api/posts/0")
+		if resp.StatusCode == http.StatusBadGateway {
+			failures++
+			continue
+		}
+		seen[strings.Fields(body)[0]]++
+	}
+	if failures != 1 {
+		t.Fatalf("expected the down instance to fail once and then be skipped, it failed %d times", failures)
+	}
+	if seen["one"] < 3 || seen["two"] < 3 {
+		t.Fatalf("expected requests to be spread across the instances, got %v", seen)
+	}
+}
+
+func TestPinsPasskeyCeremonies(t *testing.T) {
+	gateway := newGateway(t, Config{
+		AuthBackends: []string{instance(t, "one").URL, instance(t, "two").URL},
+	})
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		//each client is a browser with its own cookies
+		jar, _ := cookiejar.New(nil)
+		client := &http.Client{Jar: jar}
+		instances := map[string]bool{}
+		for _, path := range []string{"/api/auth/passkey/login/begin", "/api/auth/passkey/login/finish", "/api/auth/passkey/register/begin"} {
+			resp, err := client.Post(gateway.URL+path, "application/json", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			instances[strings.Fields(string(body))[0]] = true
+		}
+		if len(instances) != 1 {
+			t.Fatalf("expected the passkey requests of a client to reach one instance, got %v", instances)
+		}
+		for name := range instances {
+			seen[name]++
+		}
+	}
+	if seen["one"] == 0 || seen["two"] == 0 {
+		t.Fatalf("expected the clients to be spread across the instances, got %v", seen)
+	}
+}
+
+func TestRejectsInvalidTokens(t *testing.T) {
+	gateway := newGateway(t, Config{})
+
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/api/posts/0", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken(t, "oski", time.Minute)})
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a valid token to be forwarded but got %d", resp.StatusCode)
+	}
+
+	for _, token := range []string{"forged", accessToken(t, "oski", -time.Minute)} {
+		req, _ = http.NewRequest(http.MethodGet, gateway.URL+"/api/posts/0", nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+		resp, _ := send(t, req)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for an invalid token but was %d", resp.StatusCode)
+		}
+
+		//signing in again must still work with a stale cookie
+		req, _ = http.NewRequest(http.MethodPost, gateway.URL+"/api/auth/signin", nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: token})
+		resp, _ = send(t, req)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected auth routes to ignore a stale token but got %d", resp.StatusCode)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gateway := newGateway(t, Config{RateLimit: 1, RateBurst: 3})
+
+	for i := 0; i < 3; i++ {
+		if resp, _ := get(t, gateway.URL+"/api/posts/0"); resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d within the burst: expected 200 but was %d", i, resp.StatusCode)
+		}
+	}
+	resp, _ := get(t, gateway.URL+"/api/posts/0")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After but was %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	//a forged token doesn't get a fresh bucket, nor a 401 that skips the limit
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/api/posts/0", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "forged"})
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected an invalid token to be limited by IP but got %d", resp.StatusCode)
+	}
+
+	//signed in users have their own bucket
+	req, _ = http.NewRequest(http.MethodGet, gateway.URL+"/api/posts/0", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken(t, "oski", time.Minute)})
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a signed in user to have a separate limit but got %d", resp.StatusCode)
+	}
+}
+
+func TestLimitsBodySize(t *testing.T) {
+	gateway := newGateway(t, Config{MaxBodyBytes: 16})
+
+	req, _ := http.NewRequest(http.MethodPost, gateway.URL+"/api/posts/create", strings.NewReader(strings.Repeat("a", 17)))
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a large body but was %d", resp.StatusCode)
+	}
+
+	//a chunked body has no Content-Length and is cut off while it is proxied
+	req, _ = http.NewRequest(http.MethodPost, gateway.URL+"/api/posts/create", io.NopCloser(strings.NewReader(strings.Repeat("a", 64))))
+	req.ContentLength = -1
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for a large chunked body but was %d", resp.StatusCode)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, gateway.URL+"/api/posts/create", strings.NewReader("small"))
+	if resp, _ := send(t, req); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for a small body but was %d", resp.StatusCode)
+	}
+}
+
+func TestUnifiedCORS(t *testing.T) {
+	gateway := newGateway(t, Config{})
+
+	req, _ := http.NewRequest(http.MethodGet, gateway.URL+"/api/posts/0", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	resp, _ := send(t, req)
+	if origins := resp.Header.Values("Access-Control-Allow-Origin"); len(origins) != 1 || origins[0] != "http://localhost:3000" {
+		t.Fatalf("expected only the gateway's CORS header, got %v", origins)
+	}
+
+	req, _ = http.NewRequest(http.MethodOptions, gateway.URL+"/api/profile/oski", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	resp, body := send(t, req)
+	if resp.StatusCode != http.StatusOK || body != "" {
+		t.Fatalf("expected the gateway to answer preflights itself, got %d %q", resp.StatusCode, body)
+	}
+}
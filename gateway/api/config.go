@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+)
+
+//Config holds the gateway settings, see the config package for how they are loaded
+type Config struct {
+	config.Common `yaml:",inline"`
+
+	//Each service is reached through a comma separated list of instances
+	AuthBackends     []string `env:"AUTH_BACKENDS" yaml:"auth_backends" default:"http://172.28.1.1:80"`
+	PostsBackends    []string `env:"POSTS_BACKENDS" yaml:"posts_backends" default:"http://172.28.1.3:80"`
+	ProfilesBackends []string `env:"PROFILES_BACKENDS" yaml:"profiles_backends" default:"http://172.28.1.4:80"`
+	FriendsBackends  []string `env:"FRIENDS_BACKENDS" yaml:"friends_backends" default:"http://172.28.1.5:80"`
+
+	//BackendFailTimeout is how long an instance that refused a connection is skipped
+	BackendFailTimeout time.Duration `env:"BACKEND_FAIL_TIMEOUT" yaml:"backend_fail_timeout" default:"10s"`
+
+	//RateLimit is the sustained number of requests per second of a user, or of an IP when signed out
+	RateLimit    float64 `env:"RATE_LIMIT" yaml:"rate_limit" default:"20"`
+	RateBurst    int     `env:"RATE_BURST" yaml:"rate_burst" default:"40"`
+	MaxBodyBytes int     `env:"MAX_BODY_BYTES" yaml:"max_body_bytes" default:"1048576"`
+
+	//AdminAddr serves /metrics apart from the public listener, keep it on the internal network
+	AdminAddr string `env:"ADMIN_ADDR" yaml:"admin_addr" default:":9100"`
+}
+
+//Validate checks the backend URLs on top of the common settings
+func (c Config) Validate() error {
+	err := c.Common.Validate()
+	if err != nil {
+		return err
+	}
+	for name, backends := range map[string][]string{
+		"AUTH_BACKENDS":     c.AuthBackends,
+		"POSTS_BACKENDS":    c.PostsBackends,
+		"PROFILES_BACKENDS": c.ProfilesBackends,
+		"FRIENDS_BACKENDS":  c.FriendsBackends,
+	} {
+		if len(backends) == 0 {
+			return fmt.Errorf("config: %s needs at least one instance", name)
+		}
+		for _, backend := range backends {
+			u, err := url.Parse(backend)
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("config: %s: invalid URL %q", name, backend)
+			}
+		}
+	}
+	if c.RateLimit <= 0 || c.RateBurst <= 0 || c.MaxBodyBytes <= 0 {
+		return errors.New("config: RATE_LIMIT, RATE_BURST and MAX_BODY_BYTES must be positive")
+	}
+	return nil
+}
+
+//Configure applies cfg to the api package
+func Configure(cfg Config) {
+	jwtKey = []byte(cfg.JWTSecret)
+}
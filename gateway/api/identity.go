@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/dgrijalva/jwt-go"
+)
+
+var jwtKey = []byte("my_secret_key")
+
+//ValidateToken returns the claims of a valid access token
+func ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, errors.New("could not parse claims")
+}
+
+//tokenUser returns the userID of the access_token cookie of r, an empty one without a cookie
+func tokenUser(r *http.Request) (string, error) {
+	cookie, err := r.Cookie("access_token")
+	if err != nil || cookie.Value == "" {
+		return "", nil
+	}
+	claims, err := ValidateToken(cookie.Value)
+	if err != nil {
+		return "", err
+	}
+	userID, ok := claims["UserID"].(string)
+	if !ok || userID == "" {
+		return "", errors.New("the token has no UserID")
+	}
+	return userID, nil
+}
+
+//Identity validates the access_token cookie once for every service. An invalid or
+//expired token is rejected with 401 except on the auth routes, where the user is
+//signing in again. The services still check the token themselves.
+func Identity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := tokenUser(r)
+		if err != nil && !strings.HasPrefix(r.URL.Path, "/api/auth/") {
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "invalid or expired access token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+//LimitBody rejects request bodies larger than maxBytes with 413
+func LimitBody(maxBytes int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > int64(maxBytes) {
+				problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge, "request body too large")
+				return
+			}
+			//bodies without a Content-Length are cut off while they are proxied
+			r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//RateLimiter is a token bucket per user, or per client IP for signed out requests
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+//idleBucket is how long a bucket is kept after its last request, a full bucket needs no state
+const idleBucket = 10 * time.Minute
+
+//NewRateLimiter allows rate requests per second with bursts of up to burst requests
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+//Allow takes a token from the bucket of key, it returns how long to wait when there is none
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > idleBucket {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > idleBucket {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+//Middleware answers 429 with a Retry-After header once the caller is out of tokens. It
+//runs before Identity so that requests with invalid tokens are limited too, by IP.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := tokenUser(r)
+		key := "user:" + userID
+		if err != nil || userID == "" {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			key = "ip:" + host
+		}
+
+		ok, wait := l.Allow(key)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			problem.Error(w, r, http.StatusTooManyRequests, problem.CodeRateLimited, "too many requests, retry after "+w.Header().Get("Retry-After")+"s")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/cookies"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+)
+
+//Pool load-balances requests round robin across the instances of one service.
+//An instance that can't be reached is skipped for the fail timeout.
+type Pool struct {
+	backends    []*backend
+	next        uint32
+	failTimeout time.Duration
+}
+
+type backend struct {
+	url   *url.URL
+	proxy *httputil.ReverseProxy
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+//NewPool creates a Pool proxying to the instances at urls
+func NewPool(urls []string, failTimeout time.Duration) (*Pool, error) {
+	pool := &Pool{failTimeout: failTimeout}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		b := &backend{url: u}
+		b.proxy = &httputil.ReverseProxy{
+			Rewrite: func(r *httputil.ProxyRequest) {
+				r.SetURL(u)
+				r.SetXForwarded()
+			},
+			Transport:      tracing.Transport(http.DefaultTransport),
+			ModifyResponse: stripCORS,
+			ErrorHandler:   pool.errorHandler(b),
+		}
+		pool.backends = append(pool.backends, b)
+	}
+	if len(pool.backends) == 0 {
+		return nil, errors.New("a pool needs at least one instance")
+	}
+	return pool, nil
+}
+
+//ServeHTTP forwards r to the next available instance
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.backends[p.pick()].proxy.ServeHTTP(w, r)
+}
+
+//pick returns the index of the next instance that isn't down, or of the next one when they all are
+func (p *Pool) pick() int {
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	now := time.Now()
+	for i := 0; i < len(p.backends); i++ {
+		index := (start + i) % len(p.backends)
+		if p.backends[index].up(now) {
+			return index
+		}
+	}
+	return start % len(p.backends)
+}
+
+func (b *backend) up(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.After(b.downUntil)
+}
+
+//Pinned sends every request of a client under path to the same instance, remembered in the cookie
+//named cookie. It's for state an instance keeps in memory between requests, like the passkey
+//ceremonies of auth-service whose finish must reach the instance that served the begin.
+func (p *Pool) Pinned(cookie string, path string, attributes cookies.Attributes) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(cookie); err == nil {
+			index, err := strconv.Atoi(c.Value)
+			if err == nil && index >= 0 && index < len(p.backends) && p.backends[index].up(time.Now()) {
+				p.backends[index].proxy.ServeHTTP(w, r)
+				return
+			}
+		}
+		//a new client, or its instance is down and the state it held is lost anyway
+		index := p.pick()
+		http.SetCookie(w, attributes.Apply(&http.Cookie{Name: cookie, Value: strconv.Itoa(index), Path: path}))
+		p.backends[index].proxy.ServeHTTP(w, r)
+	})
+}
+
+func (p *Pool) errorHandler(b *backend) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		//the client sent too much, the instance is fine
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			problem.Error(w, r, http.StatusRequestEntityTooLarge, problem.CodeTooLarge, "request body too large")
+			return
+		}
+
+		slog.ErrorContext(r.Context(), "proxying failed", "method", r.Method, "path", r.URL.Path, "backend", b.url.Host, "err", err)
+		b.mu.Lock()
+		b.downUntil = time.Now().Add(p.failTimeout)
+		b.mu.Unlock()
+		problem.Error(w, r, http.StatusBadGateway, problem.CodeBadGateway, "the service is unavailable")
+	}
+}
+
+//stripCORS drops the CORS headers of the services, the gateway sets its own
+func stripCORS(resp *http.Response) error {
+	for name := range resp.Header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			resp.Header.Del(name)
+		}
+	}
+	return nil
+}
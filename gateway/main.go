@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+	"github.com/BearCloud/fa20-project-dev/backend/gateway/api"
+	"github.com/gorilla/mux"
+)
+
+func main() {
+	//Load the configuration from the environment, .env and CONFIG_FILE
+	cfg := api.Config{}
+	config.MustLoad("gateway", &cfg)
+	api.Configure(cfg)
+
+	// Every request passes CORS, the body limit, the rate limit and the token check before it is forwarded
+	router := mux.NewRouter()
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(cfg.CORSOrigins, "GET, POST, PUT, DELETE, OPTIONS"))
+	router.Use(api.LimitBody(cfg.MaxBodyBytes))
+	router.Use(api.NewRateLimiter(cfg.RateLimit, cfg.RateBurst).Middleware)
+	router.Use(api.Identity)
+
+	err := api.RegisterRoutes(router, cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	//the gateway has its own probes, the ones of the services stay internal
+	checker := health.New("gateway")
+	checker.Ready(router)
+
+	//SIGTERM drains the requests in flight before exiting
+	ctx, stop := server.SignalContext()
+	defer stop()
+	srv := server.New(cfg.ListenAddr, checker, cfg.Server)
+
+	//export the spans of TRACING_EXPORTER, the last ones once everything else has stopped
+	flushTraces, err := tracing.Setup(ctx, "gateway", cfg.Trace)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	srv.OnShutdown("tracing", flushTraces)
+
+	//Prometheus metrics are served on their own listener, the public one only forwards /api
+	admin := &http.Server{Addr: cfg.AdminAddr, Handler: metrics.Handler(), ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout}
+	go func() {
+		err := admin.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err.Error())
+		}
+	}()
+	srv.OnShutdown("admin", admin.Close)
+
+	log.Println("gateway listening...")
+	err = srv.Run(srv.Start(ctx))
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
@@ -1,337 +1,451 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"database/sql"
-	"strconv"
-	"fmt"
 )
 
+//pageSize is the number of posts returned when the request has no limit, maxPageSize the largest limit
+var (
+	pageSize    = 25
+	maxPageSize = 100
+)
 
-func RegisterRoutes(router *mux.Router) error {
-	// Why don't we put options here? Check main.go :)
+//editWindow is how long after posting the author may edit a post, 0 for ever
+var editWindow = 15 * time.Minute
+
+//the modes of the home feed: the posts of the friends, or of every other user
+const (
+	modeFriends  = "friends"
+	modeDiscover = "discover"
+)
 
-	router.HandleFunc("/api/posts/{startIndex}", getFeed).Methods(http.MethodGet) 
-	router.HandleFunc("/api/posts/{uuid}/{startIndex}", getPosts).Methods(http.MethodGet)
+//Page is one page of posts, NextCursor is empty on the last page and Mode is the feed mode served
+type Page struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+}
+
+//RegisterRoutes initializes the api endpoints, the handlers keep their posts in store, read the
+//friends of the home feed from lister, push new posts to feeds, unless feeds is nil, and read the
+//authors of the permalinks from authors
+func RegisterRoutes(router *mux.Router, store PostStore, lister FriendLister, feeds Timelines, authors AuthorReader) error {
+	// Why don't we put options here? Check main.go :)
+	posts = store
+	friendLister = lister
+	timelines = feeds
+	authorReader = authors
+
+	router.HandleFunc("/api/posts", getFeed).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/user/{uuid}", getPosts).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/id/{postID}", getPost).Methods(http.MethodGet)
 	router.HandleFunc("/api/posts/create", createPost).Methods(http.MethodPost, http.MethodOptions)
 	router.HandleFunc("/api/posts/delete/{postID}", deletePost).Methods(http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/api/posts/{postID}", editPost).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc("/api/posts/{postID}/revisions", getRevisions).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/{postID}/reactions/{kind}", react).Methods(http.MethodPut, http.MethodDelete, http.MethodOptions)
+	router.HandleFunc("/api/posts/{postID}/reactions/{kind}", getReactions).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/{postID}/comments", createComment).Methods(http.MethodPost, http.MethodOptions)
+	router.HandleFunc("/api/posts/{postID}/comments", getComments).Methods(http.MethodGet)
+	router.HandleFunc("/api/posts/{postID}/comments/{commentID}", editComment).Methods(http.MethodPut, http.MethodOptions)
+	router.HandleFunc("/api/posts/{postID}/comments/{commentID}", deleteComment).Methods(http.MethodDelete)
 
 	return nil
 }
 
-func getUUID (w http.ResponseWriter, r *http.Request) (uuid string) {
+//getUUID returns the userID of the access_token, ok is false when an error was already written to w
+func getUUID(w http.ResponseWriter, r *http.Request) (uuid string, ok bool) {
 	cookie, err := r.Cookie("access_token")
 	if err != nil {
-		http.Error(w, errors.New("error obtaining cookie: " + err.Error()).Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated, "sign in first, the access_token cookie is missing")
+		return "", false
 	}
 	//validate the cookie
 	claims, err := ValidateToken(cookie.Value)
 	if err != nil {
-		http.Error(w, errors.New("error validating token: " + err.Error()).Error(), http.StatusUnauthorized)
-		log.Print(err.Error())
+		slog.WarnContext(r.Context(), "error validating token", "err", err)
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "invalid or expired access token")
+		return "", false
 	}
-	log.Println(claims)
 
-	return claims["UserID"].(string)
+	userID, ok := claims["UserID"].(string)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "the token has no UserID")
+		return "", false
+	}
+	return userID, true
 }
 
-func getPosts(w http.ResponseWriter, r *http.Request) {
-
-	// Load the uuid and startIndex from the url paramater into their own variables
-	// Look at mux.Vars() ... -> https://godoc.org/github.com/gorilla/mux#Vars
-	// make sure to use "strconv" to convert the startIndex to an integer!
-	uuid := mux.Vars(r)["uuid"]
-	start,err := strconv.Atoi(mux.Vars(r)["startIndex"])
-
-
-
-
-	// Check if the user is authorized
-	// First get the uuid from the access_token (see getUUID())
-	// Compare that to the uuid we got from the url parameters, if they're not the same, return an error http.StatusUnauthorized
-	// YOUR CODE HERE
-	userID := getUUID(w, r)
-	if userID != uuid {
-		http.Error(w, errors.New("uuid does not match").Error(), http.StatusUnauthorized)
+//pageParams reads the cursor and limit query parameters, adding the invalid ones to fields
+func pageParams(r *http.Request, fields *problem.Fields) (after Cursor, limit int) {
+	after, err := decodeCursor(r.URL.Query().Get("cursor"))
+	if err != nil {
+		fields.Add("cursor", "invalid", "cursor must be a next_cursor returned by a previous page")
 	}
+	limit = pageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			fields.Add("limit", "invalid", fmt.Sprintf("limit must be an integer between 1 and %d", maxPageSize))
+		}
+	}
+	return after, limit
+}
 
+//writePage serves the first limit posts of found, fetched with one more post to tell whether a next page exists
+func writePage(w http.ResponseWriter, found []Post, limit int, mode string) {
+	page := Page{Posts: found, Mode: mode}
+	if len(found) > limit {
+		page.Posts = found[:limit]
+		page.NextCursor = cursorAfter(page.Posts[limit-1]).encode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
 
-	
-	var posts *sql.Rows
-	// var err error
-
-	/* 
-		-Get all that posts that matches our userID (or uuid)
-		-Sort them chronologically (the database has a "postTime" field), hint: ORDER BY
-		-Make sure to always get up to 25, and start with an offset of {startIndex} (look at the previous SQL homework for hints)\
-		-As indicated by the "posts" variable, this query returns multiple rows
-	*/
-	//posts, err = DB.Query("SELECT * FROM posts WHERE AuthorID = ?, PostID >= ? ORDER BY PostTime LIMIT 25", uuid , start)
-	posts, err = DB.Query("SELECT * FROM posts WHERE authorID = ? ORDER BY postTime LIMIT 25 OFFSET ?", uuid, start)
-
-
-	// Check for errors from the query
+//withCounts fills in the reactions to found, MyReactions being the ones of userID, and the number of comments
+func withCounts(ctx context.Context, found []Post, userID string) error {
+	postIDs := make([]string, len(found))
+	for i, post := range found {
+		postIDs[i] = post.PostID
+	}
+	summaries, err := posts.Reactions(ctx, postIDs, userID)
 	if err != nil {
-		http.Error(w, errors.New("error obtainting rows: " + err.Error()).Error(), http.StatusUnauthorized)
-		log.Print(err.Error())
-	}
-
-	
-
-
-	var (
-		content string
-		postID string
-		userid string
-		postTime time.Time
-	)
-	numPosts := 0
-	// Create "postsArray", which is a slice (array) of Posts. Make sure it has size 25
-	// Hint: https://tour.golang.org/moretypes/13
-	postsArray := make([]Post,25)
-
-	for i := 0; i < 25 && posts.Next(); i++ {
-		// Every time we call posts.Next() we get access to the next row returned from our query
-		// Question: How many columns did we return
-		// Reminder: Scan() scans the rows in order of their columns. See the variables defined up above for your convenience
-		err = posts.Scan(&content, &postID, &userid, &postTime) //&postAuthor /* missing 5th column, decide about later*/)
-		
-		// Check for errors in scanning
-		if err != nil {
-			http.Error(w, errors.New("error scanning columns: " + err.Error()).Error(), http.StatusUnauthorized)
-			log.Print(err.Error())
+		return err
+	}
+	comments, err := posts.CommentCounts(ctx, postIDs)
+	if err != nil {
+		return err
+	}
+	for i := range found {
+		found[i].Reactions = summaries[found[i].PostID].Counts
+		found[i].MyReactions = summaries[found[i].PostID].Mine
+		found[i].Comments = comments[found[i].PostID]
+		//a post without reactions has empty ones, not null
+		if found[i].Reactions == nil {
+			found[i].Reactions = map[string]int{}
 		}
+		if found[i].MyReactions == nil {
+			found[i].MyReactions = []string{}
+		}
+	}
+	return nil
+}
 
-		// Set the i-th index of postsArray to a new Post with values directly from the variables you just scanned into
-		// Check post.go for the structure of a Post
-		// Hint: https://gobyexample.com/structs 
-		
-		//YOUR CODE HERE
-		postsArray[i] = Post{content, postID, userid, postTime, ""}//postAuthor /* add final column?*/}
-		numPosts++
+func getPosts(w http.ResponseWriter, r *http.Request) {
+	// Load the uuid from the url paramater and the page from the query
+	uuid := mux.Vars(r)["uuid"]
+	fields := problem.Fields{}
+	after, limit := pageParams(r, &fields)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return
 	}
 
-	posts.Close()
-	err = posts.Err()
+	// Check if the user is signed in, any user may list the posts of another one
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+
+	// Get a page of the posts of the user the caller may see, oldest first, after the cursor
+	userPosts, err := posts.UserPosts(r.Context(), uuid, visibilities(r, userID, uuid), after, limit+1)
+	if err != nil {
+		problem.Internal(w, r, "error obtaining posts", err)
+		return
+	}
+	err = withCounts(r.Context(), userPosts, userID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
-	}
-  //encode fetched data as json and serve to client
-  // Up until now, we've actually been counting the number of posts (numPosts)
-  // We will always have *up to* 25 posts, but we can have less
-  // However, we already allocated 25 spots in oru postsArray
-  // Return the subarray that contains all of our values (which may be a subsection of our array or the entire array)
-  subarray := postsArray[:numPosts]
-  json.NewEncoder(w).Encode(subarray)
-  return;
+		problem.Internal(w, r, "error counting the reactions and comments", err)
+		return
+	}
+
+	//encode fetched data as json and serve to client
+	writePage(w, userPosts, limit, "")
 }
 
 func createPost(w http.ResponseWriter, r *http.Request) {
 	// Obtain the userID from the JSON Web Token
-	// See getUUID(...)
-	userID := getUUID(w, r)
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
 
 	// Create a Post object and then Decode the JSON Body (which has the structure of a Post) into that object
 	post := Post{}
-	err := json.NewDecoder(r.Body).Decode(&post)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !validate.DecodeJSON(w, r, &post) {
+		return
 	}
 
-	// Use the uuid library to generate a post ID
-	// Hint: https://godoc.org/github.com/google/uuid#New
-	postID := uuid.New()
-
 	//Load our location in PST
-	pst, err := time.LoadLocation("America/Los_Angeles")
+	now, err := pstNow()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, "error loading the time zone", err)
+		return
 	}
-	currPST := time.Now().In(pst)
 
-	// Insert the post into the database
-	// Look at /db-server/initdb.sql for a better understanding of what you need to insert
-	result , err := DB.Exec("INSERT INTO posts (content, postID, authorID, postTime) VALUES (?,?,?,?)", post.PostBody, postID, userID, currPST)
-	
-	// Check errors with executing the query
+	// Insert the post with a new post ID, the author and time always come from the server
+	visibility := post.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+	post = Post{
+		PostBody:   post.PostBody,
+		PostID:     uuid.New().String(),
+		AuthorID:   userID,
+		PostTime:   now,
+		Visibility: visibility,
+	}
+	err = posts.CreatePost(r.Context(), post)
 	if err != nil {
-		http.Error(w, errors.New("error inserting the post into the database").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error inserting the post into the database", err)
 		return
 	}
+	postsCreated.Inc()
 
-	// Make sure at least one row was affected, otherwise return an InternalServerError
-	// You did something very similar in Checkpoint 2
-	row_count, err := result.RowsAffected()
-	if row_count == 0 {
-		http.Error(w, errors.New("Cannot find the post in the database").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
-		return
-	} 
+	// Push the post to the timelines of the author's friends
+	if timelines != nil {
+		fanOut(r, userID, post)
+	}
 
-	// What kind of HTTP header should we return since we created something?
-	// Check your signup from Checkpoint 2!
 	w.WriteHeader(201)
+}
 
-	return
+//pstNow returns the current time in PST, the time zone of the posts
+func pstNow() (time.Time, error) {
+	pst, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().In(pst), nil
 }
 
-func deletePost(w http.ResponseWriter, r *http.Request) {
-	// Get the postID to delete
-	// Look at mux.Vars() ... -> https://godoc.org/github.com/gorilla/mux#Vars
+func editPost(w http.ResponseWriter, r *http.Request) {
+	// Get the postID to edit and the uuid from the access token
 	postID := mux.Vars(r)["postID"]
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
 
-	fmt.Sprintf("postID: %s", postID)
+	// Decode the new body, validated like a new post
+	edit := PostEdit{}
+	if !validate.DecodeJSON(w, r, &edit) {
+		return
+	}
 
-	// Get the uuid from the access token, see getUUID(...)
-	uuid := getUUID(w, r)
+	// Only the author may edit a post, for editWindow after posting it
+	post, err := posts.Post(r.Context(), postID)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error getting the post", err)
+		return
+	}
+	if uuid != post.AuthorID {
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "only the author may edit a post")
+		return
+	}
+	now, err := pstNow()
+	if err != nil {
+		problem.Internal(w, r, "error loading the time zone", err)
+		return
+	}
+	if editWindow > 0 && now.Sub(post.PostTime) > editWindow {
+		problem.Error(w, r, http.StatusForbidden, CodeEditWindowClosed, fmt.Sprintf("posts can only be edited for %s after posting them", editWindow))
+		return
+	}
 
-	fmt.Sprintf("UUID: %s", uuid)
+	// Store the new body, the previous ones are kept as revisions
+	post, err = posts.EditPost(r.Context(), postID, edit.PostBody, now)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error editing the post", err)
+		return
+	}
+	postsEdited.Inc()
 
-	var exists bool
-	//check if post exists
-	err := DB.QueryRow("SELECT EXISTS (SELECT postID FROM posts WHERE postID = ?)", postID).Scan(&exists)
+	// The edited post has the same counts as on the pages of posts
+	found := []Post{post}
+	err = withCounts(r.Context(), found, uuid)
+	if err != nil {
+		problem.Internal(w, r, "error counting the reactions and comments", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found[0])
+}
 
-	// Check for errors in executing the query 
+func getRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get the postID, any user who may see the post may read its history
+	postID := mux.Vars(r)["postID"]
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	_, ok = visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
 
+	revisions, err := posts.Revisions(r.Context(), postID)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
 	if err != nil {
-		http.Error(w, errors.New("error checking if post/postID exists").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error obtaining the revisions", err)
 		return
 	}
 
-	// Check if the post actually exists, otherwise return an http.StatusNotFound
-	if exists != true {
-		http.Error(w, errors.New("the post cannot be found/doesn't exists").Error(), http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]Revision{"revisions": revisions})
+}
+
+func deletePost(w http.ResponseWriter, r *http.Request) {
+	// Get the postID to delete
+	postID := mux.Vars(r)["postID"]
+
+	// Get the uuid from the access token
+	uuid, ok := getUUID(w, r)
+	if !ok {
 		return
 	}
 
 	// Get the authorID of the post with the specified postID
-	var authorID string
-	err = DB.QueryRow("SELECT authorID FROM posts WHERE postID = ?", postID).Scan(&authorID)
-
-	fmt.Sprintf("authorID: %s", authorID)
-	
-	// Check for errors in executing the query
+	authorID, err := posts.PostAuthor(r.Context(), postID)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
 	if err != nil {
-		http.Error(w, errors.New("error getting the authorID of the post with the specified postID").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error getting the authorID of the post with the specified postID", err)
 		return
 	}
 
-	// Check if the uuid from the access token is the same as the authorID from the query
-	// If not, return http.StatusUnauthorized
+	// Only the author may delete a post
 	if uuid != authorID {
-		http.Error(w, errors.New("requested source doesn't match uuid in database").Error(), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "only the author may delete a post")
 		return
 	}
 
 	// Delete the post since by now we're authorized to do so
-	_, err = DB.Exec("DELETE FROM posts WHERE postID = ?", postID)
-	
-	// Check for errors in executing the query
-	if err != nil {
-		http.Error(w, errors.New("error getting the authorID of the post with the specified postID").Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+	err = posts.DeletePost(r.Context(), postID)
+	if err != nil && err != ErrPostNotFound {
+		problem.Internal(w, r, "error deleting the post", err)
 		return
 	}
-	return
+
+	// The timelines only index posts, a failure leaves rows that no longer join
+	if timelines != nil {
+		err = timelines.Remove(r.Context(), postID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "error removing the post from the timelines", "err", err)
+		}
+	}
 }
 
 func getFeed(w http.ResponseWriter, r *http.Request) {
-	// get the start index from the url paramaters
-	// based on the previous functions, you should be familiar with how to do so
-	start, err := strconv.Atoi(mux.Vars(r)["startIndex"])
-
-	// convert startIndex to int
-	// YOUR CODE HERE
-	
-	// Check for errors in converting
-	// If error, return http.StatusBadRequest
-	if err != nil {
-		http.Error(w, errors.New("error converting startIndex to integer").Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+	// get the page and the mode from the query
+	fields := problem.Fields{}
+	after, limit := pageParams(r, &fields)
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = modeFriends
+	}
+	if mode != modeFriends && mode != modeDiscover {
+		fields.Add("mode", "invalid", "mode must be friends or discover")
+	}
+	includeSelf := true
+	if value := r.URL.Query().Get("self"); value != "" {
+		var err error
+		includeSelf, err = strconv.ParseBool(value)
+		if err != nil {
+			fields.Add("self", "invalid", "self must be true or false")
+		}
+	}
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
 		return
 	}
 
 	// Get the userID from the access_token
-	// You should now be familiar with how to do so
-	userID := getUUID(w, r)
-	if err != nil {
-		http.Error(w, errors.New("error retrieving").Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+	userID, ok := getUUID(w, r)
+	if !ok {
 		return
 	}
 
-	var posts *sql.Rows
-	  
-	// Obtain all of the posts where the authorID is *NOT* the current authorID
-	// Sort chronologically
-	// Always limit to 25 queries
-	// Always start at an offset of startIndex
-	//posts, err := DB.Query("SELECT * FROM posts WHERE AuthorID != ?, PostID >= ? ORDER BY postTime LIMIT 25", &userID, &start)
-	posts, err = DB.Query("SELECT * FROM posts WHERE authorID != ? ORDER BY postTime LIMIT 25 OFFSET ?", userID, start)
-	
-	// Check for errors in executing the query
+	// The friends (and the user) write the home feed, without friends it's the discover feed
+	var authors []string
+	if mode == modeFriends {
+		authors = feedAuthors(r, userID, includeSelf)
+		if authors == nil {
+			mode = modeDiscover
+		}
+	}
+
+	// Obtain a page of posts, newest first, after the cursor, from the timeline unless
+	// the user has too many friends for it
+	var feed []Post
+	var err error
+	if mode == modeFriends && timelines != nil && includeSelf && len(authors) <= fanoutLimit+1 {
+		feed, err = timelineFeed(r.Context(), userID, authors, after, limit+1)
+	} else if mode == modeFriends {
+		feed, err = posts.AuthorsFeed(r.Context(), userID, authors, after, limit+1)
+	} else {
+		feed, err = posts.Discover(r.Context(), userID, after, limit+1)
+	}
 	if err != nil {
-		http.Error(w, errors.New("error obtaining rows").Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+		problem.Internal(w, r, "error obtaining posts", err)
+		return
+	}
+	err = withCounts(r.Context(), feed, userID)
+	if err != nil {
+		problem.Internal(w, r, "error counting the reactions and comments", err)
 		return
 	}
 
-	var (
-		content string
-		postID string
-		userid string
-		postTime time.Time
-	)
-
-	numPosts := 0
-	// Put all the posts into an array of Max Size 25 and return all the filled spots
-	// Almost exaclty like getPosts()
-	postsArray := make([]Post, 25)
-	for i :=0; i< len(postsArray) && posts.Next(); i++ {
-		err = posts.Scan(&content, &postID, &userid, &postTime)
-		if err != nil {
-			http.Error(w, errors.New("error scanning columns: " + err.Error()).Error(), http.StatusUnauthorized)
-			log.Print(err.Error())
-		}
-
-		postsArray[i] = Post{content, postID, userid, postTime, ""}
-		numPosts++
+	//encode fetched data as json and serve to client
+	writePage(w, feed, limit, mode)
+}
 
+//feedAuthors returns the authors of the friends feed of userID, nil when it falls back to the
+//discover feed because the user has no friends or the friends-service can't be reached
+func feedAuthors(r *http.Request, userID string, includeSelf bool) []string {
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return nil
 	}
-
-	posts.Close()
-	err = posts.Err()
+	friends, err := friendLister.Friends(r.Context(), userID, cookie.Value)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		log.Print(err.Error())
+		slog.WarnContext(r.Context(), "error listing the friends, serving the discover feed", "err", err)
+		feedFallbacks.WithLabelValues("unavailable").Inc()
+		return nil
 	}
-  //encode fetched data as json and serve to client
-  // Up until now, we've actually been counting the number of posts (numPosts)
-  // We will always have *up to* 25 posts, but we can have less
-  // However, we already allocated 25 spots in oru postsArray
-  // Return the subarray that contains all of our values (which may be a subsection of our array or the entire array)
-  //json.NewEncoder(w).Encode(postsArray[:numPosts])
-
-	// postJson, err := json.Marshal(postsArray[:numPosts])
- //    if err != nil {
- //        log.Fatal("Cannot encode to JSON ", err)
- //    }
-
-	//json.NewEncoder(w).Encode(postJson)
-	json.NewEncoder(w).Encode(postsArray[:numPosts])
-
-	//return postsArray[:numPosts]
-
-	return
+	if len(friends) == 0 {
+		feedFallbacks.WithLabelValues("no_friends").Inc()
+		return nil
+	}
+	//the cached list is shared, copy it before adding the user
+	authors := append(make([]string, 0, len(friends)+1), friends...)
+	if includeSelf {
+		authors = append(authors, userID)
+	}
+	return authors
 }
@@ -0,0 +1,1068 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	oski   = "5b1c9a36-5f0e-4c1b-9a55-8f7d3c1f4b2e"
+	stanny = "0d4b1b5e-2a3c-4f6e-8d9a-1c2b3d4e5f60"
+	carl   = "9e8d7c6b-5a49-4837-a261-5f4e3d2c1b0a"
+)
+
+//fakeFriends lists the friends of its map, or fails with err
+type fakeFriends struct {
+	mu      sync.Mutex
+	friends map[string][]string
+	err     error
+	calls   int
+	//failures is the number of the next calls failing like a friends service having a blip
+	failures int
+}
+
+func (f *fakeFriends) Friends(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if accessToken == "" {
+		return nil, errors.New("no access token")
+	}
+	if f.failures > 0 {
+		f.failures--
+		return nil, errors.New("friends unavailable")
+	}
+	return f.friends[userID], f.err
+}
+
+func (f *fakeFriends) Refresh(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	return f.Friends(ctx, userID, accessToken)
+}
+
+//authors has the profile of oski, fails for stanny and carl has none
+var authors = AuthorsFunc(func(ctx context.Context, userID string) (Author, error) {
+	switch userID {
+	case oski:
+		return Author{UUID: oski, FirstName: "Oski", LastName: "Bear"}, nil
+	case stanny:
+		return Author{}, errors.New("profiles unavailable")
+	}
+	return Author{UUID: userID}, nil
+})
+
+//newPostsServer starts every posts route against an in-memory store, nobody has friends
+func newPostsServer(t *testing.T) (*httptest.Server, *MemoryPostStore) {
+	return newFeedServer(t, &fakeFriends{})
+}
+
+//newFeedServer starts every posts route against an in-memory store and friends
+func newFeedServer(t *testing.T, friends FriendLister) (*httptest.Server, *MemoryPostStore) {
+	store := NewMemoryPostStore()
+	return newServer(t, store, friends, nil), store
+}
+
+//newTimelineServer starts every posts route against an in-memory store, friends and timelines
+func newTimelineServer(t *testing.T, friends FriendLister) (*httptest.Server, *MemoryPostStore, *MemoryTimelines) {
+	store := NewMemoryPostStore()
+	feeds := NewMemoryTimelines(store)
+	return newServer(t, store, friends, feeds), store, feeds
+}
+
+func newServer(t *testing.T, store *MemoryPostStore, friends FriendLister, feeds Timelines) *httptest.Server {
+	router := mux.NewRouter()
+	err := RegisterRoutes(router, store, friends, feeds, authors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every response must match openapi.yaml
+	server := httptest.NewServer(openapi.Validate(doc, router, func(err error) { t.Error(err) }))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func accessToken(t *testing.T, userID string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"UserID": userID,
+		"exp":    time.Now().Add(time.Minute).Unix(),
+	})
+	signed, err := token.SignedString(jwtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+//do sends a request as userID, an empty userID sends no access_token
+func do(t *testing.T, method string, url string, userID string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "" {
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken(t, userID)})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decodePage(t *testing.T, resp *http.Response) Page {
+	t.Helper()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	result := Page{}
+	err := json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func seed(store *MemoryPostStore, authorID string, n int) {
+	start := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		store.CreatePost(context.Background(), Post{
+			PostBody: fmt.Sprintf("post %d", i),
+			PostID:   fmt.Sprintf("%s-%02d", authorID[:8], i),
+			AuthorID: authorID,
+			PostTime: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+}
+
+func TestCreatePost(t *testing.T) {
+	server, store := newPostsServer(t)
+
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"Go Bears!","AuthorID":"someone-else"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 but was %d", resp.StatusCode)
+	}
+	created, _ := store.UserPosts(context.Background(), oski, allVisibilities, Cursor{}, pageSize)
+	if len(created) != 1 || created[0].PostBody != "Go Bears!" || created[0].PostID == "" {
+		t.Fatalf("unexpected stored posts %+v", created)
+	}
+
+	resp = do(t, http.MethodPost, server.URL+"/api/posts/create", "", `{"postBody":"Go Bears!"}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `not json`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid body but was %d", resp.StatusCode)
+	}
+}
+
+func TestCreatePostValidatesTheBody(t *testing.T) {
+	server, store := newPostsServer(t)
+
+	cases := []struct {
+		body string
+		code string
+	}{
+		{`{}`, "required"},
+		{`{"postBody":"   "}`, "required"},
+		{`{"postBody":"` + strings.Repeat("a", 256) + `"}`, "too_long"},
+		{`{"postBody":"ring \u0007"}`, "invalid"},
+		{`{"postBody":"Go Bears!","title":"hello"}`, "unknown"},
+	}
+	for _, c := range cases {
+		resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, c.body)
+		p := problem.Problem{}
+		json.NewDecoder(resp.Body).Decode(&p)
+		if resp.StatusCode != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Code != c.code {
+			t.Errorf("create %s: expected %s but got %d %+v", c.body, c.code, resp.StatusCode, p)
+		}
+	}
+
+	//the content column holds 255 characters, not bytes
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"`+strings.Repeat("🐻", 255)+`"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 for 255 characters but was %d", resp.StatusCode)
+	}
+	created, _ := store.UserPosts(context.Background(), oski, allVisibilities, Cursor{}, pageSize)
+	if len(created) != 1 {
+		t.Fatalf("expected only the valid post to be stored but got %d", len(created))
+	}
+}
+
+func TestGetPosts(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 30)
+	seed(store, stanny, 2)
+
+	first := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski, oski, ""))
+	if len(first.Posts) != pageSize || first.Posts[0].PostBody != "post 0" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	second := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski+"?cursor="+first.NextCursor, oski, ""))
+	if len(second.Posts) != 5 || second.Posts[0].PostBody != "post 25" || second.NextCursor != "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+	limited := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski+"?limit=10", oski, ""))
+	if len(limited.Posts) != 10 || limited.NextCursor == "" {
+		t.Fatalf("unexpected page of 10 %+v", limited)
+	}
+
+	others := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+stanny, oski, ""))
+	if len(others.Posts) != 2 || others.Posts[0].AuthorID != stanny {
+		t.Fatalf("unexpected posts of someone else %+v", others)
+	}
+	for _, query := range []string{"?cursor=abc", "?cursor=" + first.NextCursor[1:], "?limit=0", "?limit=101", "?limit=ten"} {
+		resp := do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski+query, oski, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s but was %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestPagesSurviveChanges(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, stanny, 4)
+
+	first := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+stanny+"?limit=2", stanny, ""))
+	if len(first.Posts) != 2 {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	//an offset would now skip "post 2"
+	store.DeletePost(context.Background(), first.Posts[0].PostID)
+	second := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+stanny+"?limit=2&cursor="+first.NextCursor, stanny, ""))
+	if len(second.Posts) != 2 || second.Posts[0].PostBody != "post 2" || second.Posts[1].PostBody != "post 3" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+	if second.NextCursor != "" {
+		t.Fatalf("expected the last page to have no next_cursor but got %q", second.NextCursor)
+	}
+}
+
+//labels names the posts of page after the first character of their author and their number
+func labels(page Page) []string {
+	result := []string{}
+	for _, post := range page.Posts {
+		result = append(result, post.AuthorID[:1]+post.PostBody[len("post "):])
+	}
+	return result
+}
+
+func TestGetFeed(t *testing.T) {
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}, stanny: {oski}}}
+	server, store := newFeedServer(t, friends)
+	seed(store, oski, 3)
+	seed(store, stanny, 2)
+	seed(store, carl, 2)
+
+	//friends and self, newest first
+	feed := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", oski, ""))
+	if got := fmt.Sprint(labels(feed)); feed.Mode != "friends" || got != "[52 51 01 50 00]" {
+		t.Fatalf("expected the posts of oski and stanny, newest first, but got %s in %s mode", got, feed.Mode)
+	}
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?self=false", oski, ""))
+	if got := fmt.Sprint(labels(feed)); got != "[01 00]" {
+		t.Fatalf("expected stanny's posts but got %s", got)
+	}
+
+	//pages continue after the cursor in the same order
+	first := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?limit=3", oski, ""))
+	rest := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?limit=3&cursor="+first.NextCursor, oski, ""))
+	if got := fmt.Sprint(labels(first), labels(rest)); got != "[52 51 01] [50 00]" || rest.NextCursor != "" {
+		t.Fatalf("unexpected pages %s", got)
+	}
+
+	//discover is everyone else's posts
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?mode=discover", oski, ""))
+	if got := fmt.Sprint(labels(feed)); feed.Mode != "discover" || got != "[91 01 90 00]" {
+		t.Fatalf("expected the posts of carl and stanny but got %s in %s mode", got, feed.Mode)
+	}
+
+	resp := do(t, http.MethodGet, server.URL+"/api/posts", "", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+	for _, query := range []string{"?limit=-1", "?mode=everyone", "?self=maybe"} {
+		resp = do(t, http.MethodGet, server.URL+"/api/posts"+query, oski, "")
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s but was %d", query, resp.StatusCode)
+		}
+	}
+}
+
+func TestGetFeedFallsBackToDiscover(t *testing.T) {
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}}}
+	server, store := newFeedServer(t, friends)
+	seed(store, oski, 1)
+	seed(store, stanny, 1)
+
+	//carl has no friends yet
+	feed := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", carl, ""))
+	if got := fmt.Sprint(labels(feed)); feed.Mode != "discover" || got != "[50 00]" {
+		t.Fatalf("expected the discover feed but got %s in %s mode", got, feed.Mode)
+	}
+
+	//the friends-service is down
+	friends.mu.Lock()
+	friends.err = errors.New("connection refused")
+	friends.mu.Unlock()
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?self=false", oski, ""))
+	if got := fmt.Sprint(labels(feed)); feed.Mode != "discover" || got != "[00]" {
+		t.Fatalf("expected the discover feed but got %s in %s mode", got, feed.Mode)
+	}
+}
+
+//inTimeline reports whether postID is in the timeline of userID
+func inTimeline(feeds *MemoryTimelines, userID string, postID string) bool {
+	feeds.mu.Lock()
+	defer feeds.mu.Unlock()
+	return feeds.entries[userID][postID]
+}
+
+func TestTimelines(t *testing.T) {
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}, stanny: {oski}}}
+	server, store, feeds := newTimelineServer(t, friends)
+	seed(store, carl, 2)
+	setFriends := func(userID string, ids ...string) {
+		friends.mu.Lock()
+		defer friends.mu.Unlock()
+		friends.friends[userID] = ids
+	}
+	newPost := func(userID string) Post {
+		t.Helper()
+		resp := do(t, http.MethodPost, server.URL+"/api/posts/create", userID, `{"postBody":"hello"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 but was %d", resp.StatusCode)
+		}
+		fanOuts.Wait()
+		found, _ := store.UserPosts(context.Background(), userID, allVisibilities, Cursor{}, 100)
+		return found[len(found)-1]
+	}
+
+	//a new post is pushed to the author and their friends
+	post := newPost(stanny)
+	if !inTimeline(feeds, oski, post.PostID) || !inTimeline(feeds, stanny, post.PostID) || inTimeline(feeds, carl, post.PostID) {
+		t.Fatal("expected the post in the timelines of stanny and oski only")
+	}
+	feed := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", oski, ""))
+	if feed.Mode != "friends" || len(feed.Posts) != 1 || feed.Posts[0].PostID != post.PostID {
+		t.Fatalf("expected stanny's post but got %+v", feed)
+	}
+
+	//a new friend's recent posts are copied, the unfriended are pruned
+	setFriends(oski, carl)
+	setFriends(carl, oski)
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", oski, ""))
+	if got := fmt.Sprint(labels(feed)); got != "[91 90]" {
+		t.Fatalf("expected carl's posts but got %s", got)
+	}
+	if inTimeline(feeds, oski, post.PostID) {
+		t.Fatal("expected stanny's post to be pruned")
+	}
+
+	//a deleted post leaves every timeline
+	post = newPost(carl)
+	if !inTimeline(feeds, oski, post.PostID) {
+		t.Fatal("expected carl's post in oski's timeline")
+	}
+	do(t, http.MethodDelete, server.URL+"/api/posts/delete/"+post.PostID, carl, "")
+	if inTimeline(feeds, oski, post.PostID) || inTimeline(feeds, carl, post.PostID) {
+		t.Fatal("expected the deleted post to leave the timelines")
+	}
+}
+
+func TestTimelinesPullLargeFriendLists(t *testing.T) {
+	defer func(limit int) { fanoutLimit = limit }(fanoutLimit)
+	fanoutLimit = 1
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny, carl}, stanny: {oski}, carl: {oski}}}
+	server, _, feeds := newTimelineServer(t, friends)
+
+	//oski has too many friends, the post is read at read time
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"hello"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, server.URL+"/api/posts/create", stanny, `{"postBody":"hi"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 but was %d", resp.StatusCode)
+	}
+	fanOuts.Wait()
+	pulled, _ := feeds.Pulled(context.Background(), []string{oski, stanny})
+	if fmt.Sprint(pulled) != "["+oski+"]" {
+		t.Fatalf("expected oski to be pulled but got %v", pulled)
+	}
+
+	//stanny's feed merges the pulled posts with the timeline
+	feed := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", stanny, ""))
+	if len(feed.Posts) != 2 || feed.Posts[0].PostBody != "hi" || feed.Posts[1].AuthorID != oski {
+		t.Fatalf("expected stanny's and oski's posts but got %+v", feed)
+	}
+	//oski's feed is read from the posts
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", oski, ""))
+	if len(feed.Posts) != 2 {
+		t.Fatalf("expected oski's and stanny's posts but got %+v", feed)
+	}
+	feeds.mu.Lock()
+	defer feeds.mu.Unlock()
+	if feeds.authors[oski] != nil {
+		t.Fatalf("expected oski's timeline to be unused but it was synced with %v", feeds.authors[oski])
+	}
+}
+
+func TestTimelinesRetryFailedFanOuts(t *testing.T) {
+	defer func(delay time.Duration) { fanoutRetryDelay = delay }(fanoutRetryDelay)
+	fanoutRetryDelay = time.Millisecond
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}, stanny: {oski}}, failures: 2}
+	server, store, feeds := newTimelineServer(t, friends)
+	create := func(body string) Post {
+		t.Helper()
+		resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"`+body+`"}`)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 but was %d", resp.StatusCode)
+		}
+		fanOuts.Wait()
+		found, _ := store.UserPosts(context.Background(), oski, allVisibilities, Cursor{}, 100)
+		return found[len(found)-1]
+	}
+	isPulled := func() bool {
+		pulled, _ := feeds.Pulled(context.Background(), []string{oski})
+		return len(pulled) > 0
+	}
+
+	//a blip of the friends service delays the fan-out
+	post := create("hello")
+	if !inTimeline(feeds, stanny, post.PostID) || isPulled() {
+		t.Fatal("expected the retried post in stanny's timeline and oski to stay pushed")
+	}
+	decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", stanny, ""))
+
+	//a fan-out failing every retry pulls the author, so the post is still read
+	friends.mu.Lock()
+	friends.err = errors.New("friends unavailable")
+	friends.calls = 0
+	friends.mu.Unlock()
+	post = create("again")
+	if friends.calls != 1+fanoutRetries || !isPulled() {
+		t.Fatalf("expected oski to be pulled after %d attempts, %d were made", 1+fanoutRetries, friends.calls)
+	}
+	friends.mu.Lock()
+	friends.err = nil
+	friends.mu.Unlock()
+	feed := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", stanny, ""))
+	if len(feed.Posts) != 2 || feed.Posts[0].PostID != post.PostID {
+		t.Fatalf("expected the post that failed to fan out in stanny's feed but got %+v", feed)
+	}
+
+	//the next fan-out pushes the author again and the timeline copies what it missed
+	latest := create("back")
+	if isPulled() {
+		t.Fatal("expected oski to leave the pulled authors")
+	}
+	feed = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts", stanny, ""))
+	if len(feed.Posts) != 3 || feed.Posts[0].PostID != latest.PostID || !inTimeline(feeds, stanny, post.PostID) {
+		t.Fatalf("expected the three posts from stanny's timeline but got %+v", feed)
+	}
+}
+
+//TestWaitFanOuts checks that the retries stop at shutdown, pulling the author
+func TestWaitFanOuts(t *testing.T) {
+	defer func() { stopFanOuts, stopFanOutsOnce = make(chan struct{}), sync.Once{} }()
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}}, err: errors.New("friends unavailable")}
+	server, _, feeds := newTimelineServer(t, friends)
+
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"hello"}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201 but was %d", resp.StatusCode)
+	}
+	start := time.Now()
+	WaitFanOuts()
+	if time.Since(start) >= fanoutRetryDelay {
+		t.Fatalf("expected the retries to stop right away, waited %s", time.Since(start))
+	}
+	pulled, _ := feeds.Pulled(context.Background(), []string{oski})
+	if len(pulled) != 1 {
+		t.Fatal("expected oski to be pulled when the service stops")
+	}
+}
+
+func TestCachedFriends(t *testing.T) {
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}, stanny: {oski}}}
+	cache := NewCachedFriends(friends, time.Hour, 1)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		listed, err := cache.Friends(ctx, oski, "token")
+		if err != nil || fmt.Sprint(listed) != "["+stanny+"]" {
+			t.Fatalf("unexpected friends %v: %v", listed, err)
+		}
+	}
+	if friends.calls != 1 {
+		t.Fatalf("expected the friends to be listed once but was %d times", friends.calls)
+	}
+	//a full cache makes room
+	cache.Friends(ctx, stanny, "token")
+	cache.Friends(ctx, oski, "token")
+	if friends.calls != 3 || len(cache.entries) != 1 {
+		t.Fatalf("expected oski's list to be evicted, %d calls and %d entries", friends.calls, len(cache.entries))
+	}
+
+	//an expired list is used while the friends-service fails
+	cache = NewCachedFriends(friends, 0, 10)
+	cache.Friends(ctx, oski, "token")
+	friends.err = errors.New("connection refused")
+	listed, err := cache.Friends(ctx, oski, "token")
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("expected the expired list but got %v: %v", listed, err)
+	}
+	_, err = cache.Friends(ctx, stanny, "token")
+	if err == nil {
+		t.Fatal("expected the error without a list to fall back to")
+	}
+}
+
+func TestFriendsClient(t *testing.T) {
+	friendsService := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("access_token")
+		if r.URL.Path != "/api/friends" || err != nil || cookie.Value != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `["%s"]`, stanny)
+	}))
+	t.Cleanup(friendsService.Close)
+	client := NewFriendsClient(friendsService.URL+"/", time.Second)
+
+	listed, err := client.Friends(context.Background(), oski, "token")
+	if err != nil || fmt.Sprint(listed) != "["+stanny+"]" {
+		t.Fatalf("unexpected friends %v: %v", listed, err)
+	}
+	_, err = client.Friends(context.Background(), oski, "forged")
+	if err == nil {
+		t.Fatal("expected an error when the friends-service rejects the token")
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 1)
+	postID := oski[:8] + "-00"
+
+	resp := do(t, http.MethodDelete, server.URL+"/api/posts/delete/"+postID, stanny, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 when deleting someone else's post but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodDelete, server.URL+"/api/posts/delete/"+postID, oski, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	if _, err := store.PostAuthor(context.Background(), postID); err != ErrPostNotFound {
+		t.Fatalf("the post wasn't deleted: %v", err)
+	}
+	resp = do(t, http.MethodDelete, server.URL+"/api/posts/delete/"+postID, oski, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted post but was %d", resp.StatusCode)
+	}
+}
+
+func TestEditPost(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 1)
+	store.CreatePost(context.Background(), Post{PostBody: "Go Bears", PostID: "recent", AuthorID: oski, PostTime: time.Now()})
+
+	resp := do(t, http.MethodPut, server.URL+"/api/posts/recent", stanny, `{"postBody":"Go Trees"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 when editing someone else's post but was %d", resp.StatusCode)
+	}
+	for _, body := range []string{`{"postBody":" "}`, `{"postBody":"x","mood":"happy"}`, `{"postBody":"x","visibility":"private"}`} {
+		resp = do(t, http.MethodPut, server.URL+"/api/posts/recent", oski, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s but was %d", body, resp.StatusCode)
+		}
+	}
+	if post, _ := store.Post(context.Background(), "recent"); post.Visibility != VisibilityPublic || post.EditedAt != nil {
+		t.Fatalf("expected the rejected edits to leave the post alone but got %+v", post)
+	}
+	resp = do(t, http.MethodPut, server.URL+"/api/posts/missing", oski, `{"postBody":"Go Bears!"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing post but was %d", resp.StatusCode)
+	}
+
+	for _, body := range []string{"Go Bears!", "Go Bears!!"} {
+		resp = do(t, http.MethodPut, server.URL+"/api/posts/recent", oski, `{"postBody":"`+body+`"}`)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 but was %d", resp.StatusCode)
+		}
+		edited := Post{}
+		json.NewDecoder(resp.Body).Decode(&edited)
+		if edited.PostBody != body || edited.EditedAt == nil || edited.AuthorID != oski {
+			t.Fatalf("unexpected edited post %+v", edited)
+		}
+	}
+
+	resp = do(t, http.MethodGet, server.URL+"/api/posts/recent/revisions", stanny, "")
+	history := map[string][]Revision{}
+	json.NewDecoder(resp.Body).Decode(&history)
+	if got := history["revisions"]; len(got) != 3 || got[0].PostBody != "Go Bears" || got[2].Revision != 3 || got[2].PostBody != "Go Bears!!" {
+		t.Fatalf("unexpected revisions %+v", got)
+	}
+
+	//an old post can't be edited, its only revision is the original
+	resp = do(t, http.MethodPut, server.URL+"/api/posts/"+oski[:8]+"-00", oski, `{"postBody":"Go Bears!"}`)
+	p := problem.Problem{}
+	json.NewDecoder(resp.Body).Decode(&p)
+	if resp.StatusCode != http.StatusForbidden || p.Code != CodeEditWindowClosed {
+		t.Fatalf("expected 403 edit_window_closed but was %d %q", resp.StatusCode, p.Code)
+	}
+	resp = do(t, http.MethodGet, server.URL+"/api/posts/"+oski[:8]+"-00/revisions", oski, "")
+	json.NewDecoder(resp.Body).Decode(&history)
+	if got := history["revisions"]; len(got) != 1 || got[0].PostBody != "post 0" {
+		t.Fatalf("unexpected revisions %+v", got)
+	}
+
+	//without an edit window posts can always be edited
+	defer func(window time.Duration) { editWindow = window }(editWindow)
+	editWindow = 0
+	resp = do(t, http.MethodPut, server.URL+"/api/posts/"+oski[:8]+"-00", oski, `{"postBody":"Go Bears!"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 without an edit window but was %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, server.URL+"/api/posts/missing/revisions", oski, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing post but was %d", resp.StatusCode)
+	}
+}
+
+func TestReactions(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 2)
+	postURL := server.URL + "/api/posts/" + oski[:8] + "-00/reactions/"
+
+	//reacting twice with a kind counts once
+	for _, reaction := range []struct{ userID, kind string }{{stanny, "like"}, {stanny, "like"}, {stanny, "wow"}, {carl, "like"}, {oski, "like"}} {
+		resp := do(t, http.MethodPut, postURL+reaction.kind, reaction.userID, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200 but was %d", resp.StatusCode)
+		}
+	}
+	resp := do(t, http.MethodPut, postURL+"like", stanny, "")
+	summary := Reactions{}
+	json.NewDecoder(resp.Body).Decode(&summary)
+	if summary.Counts["like"] != 3 || summary.Counts["wow"] != 1 || len(summary.Mine) != 2 || summary.Mine[0] != "like" || summary.Mine[1] != "wow" {
+		t.Fatalf("unexpected reactions %+v", summary)
+	}
+
+	//the pages count the reactions of every post and flag the caller's
+	page := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts?mode=discover", stanny, ""))
+	if len(page.Posts) != 2 || page.Posts[1].Reactions["like"] != 3 || len(page.Posts[1].MyReactions) != 2 || page.Posts[0].Reactions == nil || len(page.Posts[0].Reactions) != 0 {
+		t.Fatalf("unexpected reactions on the feed %+v", page.Posts)
+	}
+	page = decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski, oski, ""))
+	if page.Posts[0].Reactions["wow"] != 1 || len(page.Posts[0].MyReactions) != 1 || page.Posts[0].MyReactions[0] != "like" {
+		t.Fatalf("unexpected reactions on the posts %+v", page.Posts)
+	}
+
+	resp = do(t, http.MethodDelete, postURL+"like", stanny, "")
+	json.NewDecoder(resp.Body).Decode(&summary)
+	if resp.StatusCode != http.StatusOK || summary.Counts["like"] != 2 || len(summary.Mine) != 1 {
+		t.Fatalf("unexpected reactions after removing one %d %+v", resp.StatusCode, summary)
+	}
+	resp = do(t, http.MethodDelete, postURL+"like", stanny, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected removing a missing reaction to succeed but was %d", resp.StatusCode)
+	}
+
+	//who reacted, newest first
+	resp = do(t, http.MethodGet, postURL+"like?limit=1", stanny, "")
+	first := ReactionPage{}
+	json.NewDecoder(resp.Body).Decode(&first)
+	if len(first.Reactions) != 1 || first.Reactions[0].UserID != oski || first.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	resp = do(t, http.MethodGet, postURL+"like?limit=1&cursor="+first.NextCursor, stanny, "")
+	rest := ReactionPage{}
+	json.NewDecoder(resp.Body).Decode(&rest)
+	if len(rest.Reactions) != 1 || rest.Reactions[0].UserID != carl || rest.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v", rest)
+	}
+
+	for _, tc := range []struct {
+		method string
+		url    string
+		status int
+	}{
+		{http.MethodPut, postURL + "meh", http.StatusBadRequest},
+		{http.MethodGet, postURL + "meh", http.StatusBadRequest},
+		{http.MethodPut, server.URL + "/api/posts/missing/reactions/like", http.StatusNotFound},
+		{http.MethodDelete, server.URL + "/api/posts/missing/reactions/like", http.StatusNotFound},
+		{http.MethodGet, server.URL + "/api/posts/missing/reactions/like", http.StatusNotFound},
+	} {
+		resp = do(t, tc.method, tc.url, stanny, "")
+		if resp.StatusCode != tc.status {
+			t.Fatalf("expected %d for %s %s but was %d", tc.status, tc.method, tc.url, resp.StatusCode)
+		}
+	}
+
+	//deleting the post deletes its reactions
+	store.DeletePost(context.Background(), oski[:8]+"-00")
+	summaries, _ := store.Reactions(context.Background(), []string{oski[:8] + "-00"}, stanny)
+	if len(summaries[oski[:8]+"-00"].Counts) != 0 {
+		t.Fatalf("expected no reactions to a deleted post but got %+v", summaries)
+	}
+}
+
+func TestComments(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 2)
+	commentsURL := server.URL + "/api/posts/" + oski[:8] + "-00/comments"
+	comment := func(userID string, body string) Comment {
+		t.Helper()
+		resp := do(t, http.MethodPost, commentsURL, userID, body)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected 201 for %s but was %d", body, resp.StatusCode)
+		}
+		created := Comment{}
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created
+	}
+
+	first := comment(stanny, `{"body":"Go Bears!"}`)
+	second := comment(carl, `{"body":"Fear the tree"}`)
+	reply := comment(oski, `{"body":"Thanks","parentID":"`+first.CommentID+`"}`)
+	deepest := comment(carl, `{"body":"Sure","parentID":"`+reply.CommentID+`"}`)
+	if first.Depth != 1 || first.AuthorID != stanny || reply.Depth != 2 || reply.ParentID != first.CommentID || deepest.Depth != 3 {
+		t.Fatalf("unexpected comments %+v %+v %+v", first, reply, deepest)
+	}
+
+	//replies nest maxCommentDepth deep and stay on their post
+	for _, body := range []string{
+		`{"body":"Too deep","parentID":"` + deepest.CommentID + `"}`,
+		`{"body":"Lost","parentID":"missing"}`,
+		`{"body":" "}`,
+	} {
+		resp := do(t, http.MethodPost, commentsURL, stanny, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s but was %d", body, resp.StatusCode)
+		}
+	}
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/"+oski[:8]+"-01/comments", stanny, `{"body":"Elsewhere","parentID":"`+first.CommentID+`"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a reply to a comment of another post but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPost, server.URL+"/api/posts/missing/comments", stanny, `{"body":"Go Bears!"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing post but was %d", resp.StatusCode)
+	}
+
+	//the comments on the post come oldest first with their replies counted, then the replies
+	resp = do(t, http.MethodGet, commentsURL+"?limit=1", carl, "")
+	page := CommentPage{}
+	json.NewDecoder(resp.Body).Decode(&page)
+	if len(page.Comments) != 1 || page.Comments[0].CommentID != first.CommentID || page.Comments[0].Replies != 1 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	resp = do(t, http.MethodGet, commentsURL+"?limit=1&cursor="+page.NextCursor, carl, "")
+	page = CommentPage{}
+	json.NewDecoder(resp.Body).Decode(&page)
+	if len(page.Comments) != 1 || page.Comments[0].CommentID != second.CommentID || page.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	resp = do(t, http.MethodGet, commentsURL+"?parent="+first.CommentID, carl, "")
+	page = CommentPage{}
+	json.NewDecoder(resp.Body).Decode(&page)
+	if len(page.Comments) != 1 || page.Comments[0].CommentID != reply.CommentID {
+		t.Fatalf("unexpected replies %+v", page)
+	}
+
+	//the pages of posts count the comments of every depth
+	userPosts := decodePage(t, do(t, http.MethodGet, server.URL+"/api/posts/user/"+oski, oski, ""))
+	if userPosts.Posts[0].Comments != 4 || userPosts.Posts[1].Comments != 0 {
+		t.Fatalf("unexpected comment counts %+v", userPosts.Posts)
+	}
+
+	//only the author edits a comment
+	resp = do(t, http.MethodPut, commentsURL+"/"+first.CommentID, oski, `{"body":"Go Trees!"}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 when editing someone else's comment but was %d", resp.StatusCode)
+	}
+	for _, body := range []string{`{"body":" "}`, `{"body":"x","parentID":"` + first.CommentID + `"}`, `{"body":"x","depth":1}`} {
+		resp = do(t, http.MethodPut, commentsURL+"/"+first.CommentID, stanny, body)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s but was %d", body, resp.StatusCode)
+		}
+	}
+	resp = do(t, http.MethodPut, commentsURL+"/"+first.CommentID, stanny, `{"body":"Go Bears!!"}`)
+	edited := Comment{}
+	json.NewDecoder(resp.Body).Decode(&edited)
+	if resp.StatusCode != http.StatusOK || edited.Body != "Go Bears!!" || edited.EditedAt == nil || edited.Replies != 1 {
+		t.Fatalf("unexpected edited comment %d %+v", resp.StatusCode, edited)
+	}
+	resp = do(t, http.MethodPut, server.URL+"/api/posts/"+oski[:8]+"-01/comments/"+first.CommentID, stanny, `{"body":"Go Bears!!"}`)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a comment of another post but was %d", resp.StatusCode)
+	}
+
+	//the authors of the comment and of the post delete it, with its replies
+	resp = do(t, http.MethodDelete, commentsURL+"/"+second.CommentID, stanny, "")
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 when deleting someone else's comment but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodDelete, commentsURL+"/"+second.CommentID, carl, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 when deleting a comment but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodDelete, commentsURL+"/"+first.CommentID, oski, "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 when the author of the post deletes a comment but was %d", resp.StatusCode)
+	}
+	counts, _ := store.CommentCounts(context.Background(), []string{oski[:8] + "-00"})
+	if counts[oski[:8]+"-00"] != 0 {
+		t.Fatalf("expected the replies deleted with the comment but %d comments are left", counts[oski[:8]+"-00"])
+	}
+	resp = do(t, http.MethodDelete, commentsURL+"/"+reply.CommentID, oski, "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a deleted reply but was %d", resp.StatusCode)
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	friends := &fakeFriends{friends: map[string][]string{oski: {stanny}, stanny: {oski}}}
+	//the routes share the package variables, one server at a time
+	for _, name := range []string{"read", "timelines"} {
+		server, store := newFeedServer(t, friends)
+		if name == "timelines" {
+			server, store, _ = newTimelineServer(t, friends)
+		}
+		ids := map[string]string{}
+		for _, visibility := range []string{"", VisibilityPublic, VisibilityFriends, VisibilityPrivate} {
+			body := `{"postBody":"` + visibility + `","visibility":"` + visibility + `"}`
+			if visibility == "" {
+				//public by default
+				body = `{"postBody":"default"}`
+			}
+			resp := do(t, http.MethodPost, server.URL+"/api/posts/create", stanny, body)
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("%s: expected 201 for %s but was %d", name, body, resp.StatusCode)
+			}
+		}
+		found, _ := store.UserPosts(context.Background(), stanny, allVisibilities, Cursor{}, 100)
+		for _, post := range found {
+			ids[post.PostBody] = post.PostID
+		}
+		if len(ids) != 4 || store.posts[ids["default"]].Visibility != VisibilityPublic {
+			t.Fatalf("%s: unexpected posts %+v", name, found)
+		}
+
+		bodies := func(page Page) string {
+			result := []string{}
+			for _, post := range page.Posts {
+				result = append(result, post.PostBody)
+			}
+			return strings.Join(result, " ")
+		}
+		for _, tc := range []struct {
+			userID string
+			url    string
+			want   string
+		}{
+			{stanny, "/api/posts/user/" + stanny, "default public friends private"},
+			{oski, "/api/posts/user/" + stanny, "default public friends"},
+			{carl, "/api/posts/user/" + stanny, "default public"},
+			{stanny, "/api/posts", "private friends public default"},
+			{oski, "/api/posts", "friends public default"},
+			{oski, "/api/posts?mode=discover", "public default"},
+			{carl, "/api/posts", "public default"},
+		} {
+			page := decodePage(t, do(t, http.MethodGet, server.URL+tc.url, tc.userID, ""))
+			if got := bodies(page); got != tc.want {
+				t.Fatalf("%s: expected %q for %s but got %q", name, tc.want, tc.url, got)
+			}
+		}
+
+		//a post hidden from the caller can't be found
+		for _, tc := range []struct {
+			method string
+			userID string
+			url    string
+			status int
+		}{
+			{http.MethodGet, oski, "/api/posts/" + ids["friends"] + "/revisions", http.StatusOK},
+			{http.MethodGet, carl, "/api/posts/" + ids["friends"] + "/revisions", http.StatusNotFound},
+			{http.MethodGet, oski, "/api/posts/" + ids["private"] + "/revisions", http.StatusNotFound},
+			{http.MethodGet, stanny, "/api/posts/" + ids["private"] + "/revisions", http.StatusOK},
+			{http.MethodPut, carl, "/api/posts/" + ids["friends"] + "/reactions/like", http.StatusNotFound},
+			{http.MethodGet, oski, "/api/posts/" + ids["private"] + "/reactions/like", http.StatusNotFound},
+			{http.MethodGet, carl, "/api/posts/" + ids["friends"] + "/comments", http.StatusNotFound},
+			{http.MethodGet, carl, "/api/posts/" + ids["public"] + "/comments", http.StatusOK},
+		} {
+			resp := do(t, tc.method, server.URL+tc.url, tc.userID, "")
+			if resp.StatusCode != tc.status {
+				t.Fatalf("%s: expected %d for %s %s but was %d", name, tc.status, tc.method, tc.url, resp.StatusCode)
+			}
+		}
+		resp := do(t, http.MethodPost, server.URL+"/api/posts/"+ids["private"]+"/comments", oski, `{"body":"Go Bears!"}`)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 when commenting a hidden post but was %d", name, resp.StatusCode)
+		}
+	}
+
+	server, _ := newFeedServer(t, friends)
+	resp := do(t, http.MethodPost, server.URL+"/api/posts/create", oski, `{"postBody":"Go Bears!","visibility":"secret"}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown visibility but was %d", resp.StatusCode)
+	}
+
+	//an unfriended user loses access at once, whatever the cached friend list says
+	server, store := newFeedServer(t, NewCachedFriends(friends, time.Hour, 10))
+	store.CreatePost(context.Background(), Post{PostBody: "friends", PostID: "friends", AuthorID: stanny, PostTime: time.Now(), Visibility: VisibilityFriends})
+	if resp := do(t, http.MethodGet, server.URL+"/api/posts/id/friends", oski, ""); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected a friend to see the post but got %d", resp.StatusCode)
+	}
+	friends.mu.Lock()
+	friends.friends[oski] = nil
+	friends.mu.Unlock()
+	if resp := do(t, http.MethodGet, server.URL+"/api/posts/id/friends", oski, ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a former friend to get 404 but got %d", resp.StatusCode)
+	}
+}
+
+func TestGetPost(t *testing.T) {
+	server, store := newPostsServer(t)
+	seed(store, oski, 1)
+	seed(store, stanny, 1)
+	store.CreatePost(context.Background(), Post{PostBody: "secret", PostID: "private", AuthorID: oski, PostTime: time.Now(), Visibility: VisibilityPrivate})
+	postURL := server.URL + "/api/posts/id/" + oski[:8] + "-00"
+	do(t, http.MethodPut, server.URL+"/api/posts/"+oski[:8]+"-00/reactions/like", stanny, "")
+	do(t, http.MethodPost, server.URL+"/api/posts/"+oski[:8]+"-00/comments", carl, `{"body":"Go Bears!"}`)
+
+	get := func(url string, userID string, ifNoneMatch string) (*http.Response, Permalink) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken(t, userID)})
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		post := Permalink{}
+		if resp.StatusCode == http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(&post)
+		}
+		return resp, post
+	}
+
+	resp, post := get(postURL, stanny, "")
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" || post.PostBody != "post 0" || post.Visibility != VisibilityPublic {
+		t.Fatalf("unexpected post %d %q %+v", resp.StatusCode, etag, post)
+	}
+	if post.Author.UUID != oski || post.Author.FirstName != "Oski" || post.Reactions["like"] != 1 || len(post.MyReactions) != 1 || post.Comments != 1 {
+		t.Fatalf("expected the author, reactions and comments but got %+v %+v", post, post.Author)
+	}
+
+	//an unchanged post answers 304 to its ETag
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		resp, _ = get(postURL, stanny, ifNoneMatch)
+		if resp.StatusCode != http.StatusNotModified || resp.Header.Get("ETag") != etag {
+			t.Fatalf("expected 304 for %s but was %d", ifNoneMatch, resp.StatusCode)
+		}
+	}
+	resp, _ = get(postURL, stanny, `"other"`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for another ETag but was %d", resp.StatusCode)
+	}
+	do(t, http.MethodPut, server.URL+"/api/posts/"+oski[:8]+"-00/reactions/wow", carl, "")
+	resp, _ = get(postURL, stanny, etag)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") == etag {
+		t.Fatalf("expected a new ETag after a reaction but was %d %q", resp.StatusCode, resp.Header.Get("ETag"))
+	}
+	//myReactions differ between callers, and so do the ETags
+	resp, _ = get(postURL, carl, etag)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for the ETag of another user but was %d", resp.StatusCode)
+	}
+
+	//the post is still served when the profile can't be read
+	resp, post = get(server.URL+"/api/posts/id/"+stanny[:8]+"-00", oski, "")
+	if resp.StatusCode != http.StatusOK || post.Author.UUID != stanny || post.Author.FirstName != "" {
+		t.Fatalf("expected stanny's post without a name but got %d %+v", resp.StatusCode, post)
+	}
+
+	for _, url := range []string{server.URL + "/api/posts/id/private", server.URL + "/api/posts/id/missing"} {
+		resp, _ = get(url, stanny, "")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("expected 404 for %s but was %d", url, resp.StatusCode)
+		}
+	}
+	resp, post = get(server.URL+"/api/posts/id/private", oski, "")
+	if resp.StatusCode != http.StatusOK || post.Visibility != VisibilityPrivate {
+		t.Fatalf("expected the author to see a private post but got %d %+v", resp.StatusCode, post)
+	}
+
+	//a post without reactions or comments still has its counts
+	resp = do(t, http.MethodGet, server.URL+"/api/posts/id/private", oski, "")
+	counts := map[string]json.RawMessage{}
+	json.NewDecoder(resp.Body).Decode(&counts)
+	resp.Body.Close()
+	if string(counts["reactions"]) != "{}" || string(counts["myReactions"]) != "[]" || string(counts["comments"]) != "0" {
+		t.Fatalf("expected zero counts but got %s %s %s", counts["reactions"], counts["myReactions"], counts["comments"])
+	}
+	resp = do(t, http.MethodGet, postURL, "", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+}
+
+func TestRejectsForgedToken(t *testing.T) {
+	server, _ := newPostsServer(t)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/posts", nil)
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: "not-a-jwt"})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a malformed token but was %d", resp.StatusCode)
+	}
+}
+
+//TestOpenAPI checks that openapi.yaml documents exactly the routes of RegisterRoutes
+func TestOpenAPI(t *testing.T) {
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	err = RegisterRoutes(router, NewMemoryPostStore(), &fakeFriends{}, nil, authors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openapi.CheckRoutes(doc, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+//maxCommentDepth is how deep replies nest, 1 allows comments on the posts but no replies
+var maxCommentDepth = 3
+
+//CommentPage is one page of the comments on a post or of the replies to a comment, oldest first
+type CommentPage struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+//postComment returns the comment of the URL, ok is false when an error was already written to w,
+//which is 404 for a comment on another post
+func postComment(w http.ResponseWriter, r *http.Request) (comment Comment, ok bool) {
+	vars := mux.Vars(r)
+	comment, err := posts.Comment(r.Context(), vars["commentID"])
+	if err == ErrCommentNotFound || err == nil && comment.PostID != vars["postID"] {
+		problem.Error(w, r, http.StatusNotFound, CodeCommentNotFound, "the comment cannot be found/doesn't exists")
+		return Comment{}, false
+	}
+	if err != nil {
+		problem.Internal(w, r, "error getting the comment", err)
+		return Comment{}, false
+	}
+	return comment, true
+}
+
+func createComment(w http.ResponseWriter, r *http.Request) {
+	// Get the postID and the userID from the access_token, any user who may see the post may comment
+	postID := mux.Vars(r)["postID"]
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	_, ok = visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
+
+	// Decode the body and, for a reply, the parent comment
+	comment := Comment{}
+	if !validate.DecodeJSON(w, r, &comment) {
+		return
+	}
+	depth := 1
+	if comment.ParentID != "" {
+		parent, err := posts.Comment(r.Context(), comment.ParentID)
+		if err != nil && err != ErrCommentNotFound {
+			problem.Internal(w, r, "error getting the parent comment", err)
+			return
+		}
+		depth = parent.Depth + 1
+		fields := problem.Fields{}
+		if err == ErrCommentNotFound || parent.PostID != postID {
+			fields.Add("parentID", "invalid", "parentID must be a comment on the post")
+		} else if depth > maxCommentDepth {
+			fields.Add("parentID", "too_deep", fmt.Sprintf("replies nest %d deep at most", maxCommentDepth))
+		}
+		if len(fields) > 0 {
+			problem.Invalid(w, r, fields)
+			return
+		}
+	}
+
+	// Insert the comment with a new ID, the author and time always come from the server
+	comment = Comment{
+		CommentID: uuid.New().String(),
+		PostID:    postID,
+		ParentID:  comment.ParentID,
+		AuthorID:  userID,
+		Body:      comment.Body,
+		Depth:     depth,
+		CreatedAt: time.Now(),
+	}
+	err := posts.CreateComment(r.Context(), comment)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error inserting the comment into the database", err)
+		return
+	}
+	commentsCreated.Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+func getComments(w http.ResponseWriter, r *http.Request) {
+	// Get the postID, the parent comment and the page from the query
+	postID := mux.Vars(r)["postID"]
+	parentID := r.URL.Query().Get("parent")
+	fields := problem.Fields{}
+	after, limit := pageParams(r, &fields)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return
+	}
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	_, ok = visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
+
+	// Get a page of the comments on the post, or of the replies to parentID, oldest first
+	found, err := posts.Comments(r.Context(), postID, parentID, after, limit+1)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error obtaining the comments", err)
+		return
+	}
+
+	page := CommentPage{Comments: found}
+	if len(found) > limit {
+		page.Comments = found[:limit]
+		page.NextCursor = cursorAfterComment(page.Comments[limit-1]).encode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+func editComment(w http.ResponseWriter, r *http.Request) {
+	// Get the uuid from the access token and the new body
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	edit := CommentEdit{}
+	if !validate.DecodeJSON(w, r, &edit) {
+		return
+	}
+
+	// Only the author may edit a comment
+	comment, ok := postComment(w, r)
+	if !ok {
+		return
+	}
+	if uuid != comment.AuthorID {
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "only the author may edit a comment")
+		return
+	}
+
+	comment, err := posts.EditComment(r.Context(), comment.CommentID, edit.Body, time.Now())
+	if err == ErrCommentNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodeCommentNotFound, "the comment cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error editing the comment", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+func deleteComment(w http.ResponseWriter, r *http.Request) {
+	// Get the uuid from the access token
+	uuid, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	comment, ok := postComment(w, r)
+	if !ok {
+		return
+	}
+
+	// The author of the comment and the author of the post may delete it
+	if uuid != comment.AuthorID {
+		authorID, err := posts.PostAuthor(r.Context(), comment.PostID)
+		if err != nil && err != ErrPostNotFound {
+			problem.Internal(w, r, "error getting the authorID of the post", err)
+			return
+		}
+		if uuid != authorID {
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "only the author of the comment or of the post may delete a comment")
+			return
+		}
+	}
+
+	// Delete the comment and the replies to it
+	err := posts.DeleteComment(r.Context(), comment.CommentID)
+	if err != nil && err != ErrCommentNotFound {
+		problem.Internal(w, r, "error deleting the comment", err)
+		return
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+)
+
+//Config holds the posts-service settings, see the config package for how they are loaded
+type Config struct {
+	config.Common     `yaml:",inline"`
+	database.Settings `yaml:",inline"`
+
+	DatabaseDSN string `env:"DATABASE_DSN" yaml:"database_dsn" default:"root:root@tcp(172.28.1.2:3306)/postsDB?parseTime=true" required:"true" secret:"dsn"`
+
+	//PageSize is the number of posts of a page without a limit, MaxPageSize the largest limit
+	PageSize    int `env:"PAGE_SIZE" yaml:"page_size" default:"25"`
+	MaxPageSize int `env:"MAX_PAGE_SIZE" yaml:"max_page_size" default:"100"`
+
+	//FriendsURL is the friends-service the home feed asks for the friends of the user,
+	//their lists are cached for FriendsCacheTTL, FriendsCacheSize users at most
+	FriendsURL       string        `env:"FRIENDS_URL" yaml:"friends_url" default:"http://172.28.1.5:80"`
+	FriendsTimeout   time.Duration `env:"FRIENDS_TIMEOUT" yaml:"friends_timeout" default:"2s"`
+	FriendsCacheTTL  time.Duration `env:"FRIENDS_CACHE_TTL" yaml:"friends_cache_ttl" default:"1m"`
+	FriendsCacheSize int           `env:"FRIENDS_CACHE_SIZE" yaml:"friends_cache_size" default:"10000"`
+
+	//ProfilesURL is the profiles service the permalinks read the authors from
+	ProfilesURL     string        `env:"PROFILES_URL" yaml:"profiles_url" default:"http://172.28.1.4:80"`
+	ProfilesTimeout time.Duration `env:"PROFILES_TIMEOUT" yaml:"profiles_timeout" default:"2s"`
+
+	//EditWindow is how long after posting the author may edit a post, 0 for ever
+	EditWindow time.Duration `env:"EDIT_WINDOW" yaml:"edit_window" default:"15m"`
+
+	//MaxCommentDepth is how deep replies to comments nest, 1 allows no replies
+	MaxCommentDepth int `env:"MAX_COMMENT_DEPTH" yaml:"max_comment_depth" default:"3"`
+
+	//Timelines pushes new posts to the timelines of the friends of their author, up to
+	//FanoutLimit friends, and copies TimelineBackfill recent posts of a new friend
+	Timelines        bool `env:"TIMELINES" yaml:"timelines" default:"false"`
+	FanoutLimit      int  `env:"FANOUT_LIMIT" yaml:"fanout_limit" default:"1000"`
+	TimelineBackfill int  `env:"TIMELINE_BACKFILL" yaml:"timeline_backfill" default:"100"`
+}
+
+//Validate checks the page sizes, the edit window, the comment depth, the friends cache and the timelines
+//on top of the common settings
+func (c Config) Validate() error {
+	err := c.Common.Validate()
+	if err != nil {
+		return err
+	}
+	if c.PageSize < 1 || c.MaxPageSize < c.PageSize {
+		return errors.New("config: PAGE_SIZE must be positive and at most MAX_PAGE_SIZE")
+	}
+	if c.FriendsCacheSize < 1 {
+		return errors.New("config: FRIENDS_CACHE_SIZE must be positive")
+	}
+	if c.EditWindow < 0 {
+		return errors.New("config: EDIT_WINDOW can't be negative")
+	}
+	if c.MaxCommentDepth < 1 {
+		return errors.New("config: MAX_COMMENT_DEPTH must be positive")
+	}
+	if c.FanoutLimit < 0 || c.TimelineBackfill < 0 {
+		return errors.New("config: FANOUT_LIMIT and TIMELINE_BACKFILL can't be negative")
+	}
+	return nil
+}
+
+//Friends creates the FriendLister of cfg, the cached friends-service
+func (c Config) Friends() FriendLister {
+	return NewCachedFriends(NewFriendsClient(c.FriendsURL, c.FriendsTimeout), c.FriendsCacheTTL, c.FriendsCacheSize)
+}
+
+//Profiles creates the AuthorReader of cfg, the profiles service
+func (c Config) Profiles() AuthorReader {
+	return NewProfilesClient(c.ProfilesURL, c.ProfilesTimeout)
+}
+
+//Configure applies cfg to the api package
+func Configure(cfg Config) {
+	jwtKey = []byte(cfg.JWTSecret)
+	pageSize = cfg.PageSize
+	maxPageSize = cfg.MaxPageSize
+	editWindow = cfg.EditWindow
+	maxCommentDepth = cfg.MaxCommentDepth
+	fanoutLimit = cfg.FanoutLimit
+	timelineBackfill = cfg.TimelineBackfill
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+//Cursor is a position in the (time, ID) order of the pages, (postTime, postID) for posts,
+//(reactedAt, userID) for the reactions to a post and (createdAt, commentID) for its comments.
+//The zero Cursor is the start of the pages, oldest or newest first.
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+//errInvalidCursor is returned by decodeCursor for cursors it didn't encode
+var errInvalidCursor = errors.New("invalid cursor")
+
+//cursorAfter returns the cursor of post, the next page starts after it
+func cursorAfter(post Post) Cursor {
+	return Cursor{Time: post.PostTime, ID: post.PostID}
+}
+
+//cursorAfterReaction returns the cursor of reaction, the next page of reactions starts after it
+func cursorAfterReaction(reaction Reaction) Cursor {
+	return Cursor{Time: reaction.ReactedAt, ID: reaction.UserID}
+}
+
+//cursorAfterComment returns the cursor of comment, the next page of comments starts after it
+func cursorAfterComment(comment Comment) Cursor {
+	return Cursor{Time: comment.CreatedAt, ID: comment.CommentID}
+}
+
+//IsZero reports whether c is the start of the pages
+func (c Cursor) IsZero() bool {
+	return c.Time.IsZero() && c.ID == ""
+}
+
+//Precedes reports whether c comes before post, i.e. post is on the pages after c
+func (c Cursor) Precedes(post Post) bool {
+	return c.precedes(post.PostTime, post.PostID)
+}
+
+//precedes reports whether c comes before the position (t, id)
+func (c Cursor) precedes(t time.Time, id string) bool {
+	if t.Equal(c.Time) {
+		return c.ID < id
+	}
+	return c.Time.Before(t)
+}
+
+//Follows reports whether c comes after post, i.e. post is on the pages after c when the newest come first
+func (c Cursor) Follows(post Post) bool {
+	return c.follows(post.PostTime, post.PostID)
+}
+
+//follows reports whether c comes after the position (t, id), which the zero Cursor always does
+func (c Cursor) follows(t time.Time, id string) bool {
+	if c.IsZero() {
+		return true
+	}
+	if t.Equal(c.Time) {
+		return id < c.ID
+	}
+	return t.Before(c.Time)
+}
+
+//encode makes c opaque so clients can't build their own
+func (c Cursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(c.Time.UTC().Format(time.RFC3339Nano) + " " + c.ID))
+}
+
+//decodeCursor reads a cursor made by encode, the empty string is the zero Cursor
+func decodeCursor(encoded string) (Cursor, error) {
+	if encoded == "" {
+		return Cursor{}, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	at, id, ok := strings.Cut(string(raw), " ")
+	if !ok || id == "" {
+		return Cursor{}, errInvalidCursor
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return Cursor{}, errInvalidCursor
+	}
+	return Cursor{Time: parsed, ID: id}, nil
+}
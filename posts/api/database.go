@@ -1,31 +1,723 @@
 package api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
-	//MySQL driver
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
 )
 
+//DB represents the connection to the MySQL database
 var DB *sql.DB
 
-func InitDB() *sql.DB {
-
+//InitDB creates the MySQL database connection, waiting for the server to come up
+func InitDB(ctx context.Context, dsn string, settings database.Settings) (*sql.DB, error) {
 	log.Println("attempting connections")
 
 	var err error
-	
-	// We've decided to give the connection string for the rest of the microservices
-	DB, err = sql.Open("mysql", "root:root@tcp(172.28.1.2:3306)/postsDB?parseTime=true")
+	DB, err = database.Open(ctx, "mysql", dsn, settings)
+	if err != nil {
+		return nil, err
+	}
+	database.Publish("posts", DB)
+	metrics.RegisterDB("posts", DB)
+	return DB, nil
+}
+
+//MySQLPostStore keeps posts in the posts table
+type MySQLPostStore struct {
+	db *sql.DB
+}
+
+//NewMySQLPostStore creates a PostStore backed by db
+func NewMySQLPostStore(db *sql.DB) *MySQLPostStore {
+	return &MySQLPostStore{db: db}
+}
+
+func (s *MySQLPostStore) CreatePost(ctx context.Context, post Post) error {
+	defer metrics.TimeQuery("posts", "CreatePost")()
+	visibility := post.Visibility
+	if visibility == "" {
+		visibility = VisibilityPublic
+	}
+	result, err := s.db.ExecContext(ctx, "INSERT INTO posts (content, postID, authorID, postTime, visibility) VALUES (?,?,?,?,?)",
+		post.PostBody, post.PostID, post.AuthorID, post.PostTime, visibility)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("the post wasn't inserted")
+	}
+	return nil
+}
+
+func (s *MySQLPostStore) UserPosts(ctx context.Context, authorID string, visibilities []string, after Cursor, limit int) ([]Post, error) {
+	defer metrics.TimeQuery("posts", "UserPosts")()
+	if len(visibilities) == 0 {
+		return []Post{}, nil
+	}
+	return s.page(ctx, "authorID = ? AND visibility IN (?"+strings.Repeat(",?", len(visibilities)-1)+")",
+		append([]interface{}{authorID}, stringArgs(visibilities)...), after, false, limit)
+}
+
+func (s *MySQLPostStore) Discover(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error) {
+	defer metrics.TimeQuery("posts", "Discover")()
+	return s.page(ctx, "authorID != ? AND visibility = ?", []interface{}{userID, VisibilityPublic}, after, true, limit)
+}
+
+func (s *MySQLPostStore) AuthorsFeed(ctx context.Context, userID string, authorIDs []string, after Cursor, limit int) ([]Post, error) {
+	defer metrics.TimeQuery("posts", "AuthorsFeed")()
+	if len(authorIDs) == 0 {
+		return []Post{}, nil
+	}
+	return s.page(ctx, "authorID IN (?"+strings.Repeat(",?", len(authorIDs)-1)+") AND (authorID = ? OR visibility != ?)",
+		append(stringArgs(authorIDs), userID, VisibilityPrivate), after, true, limit)
+}
+
+//page seeks past the cursor on the (postTime, postID) indexes instead of counting an OFFSET,
+//condition filters the posts with its placeholders filled by args
+func (s *MySQLPostStore) page(ctx context.Context, condition string, args []interface{}, after Cursor, newestFirst bool, limit int) ([]Post, error) {
+	seek, order := "(postTime > ? OR (postTime = ? AND postID > ?))", "postTime, postID"
+	if newestFirst {
+		seek, order = "(postTime < ? OR (postTime = ? AND postID < ?))", "postTime DESC, postID DESC"
+	}
+	if !after.IsZero() {
+		condition += " AND " + seek
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	return queryPosts(ctx, s.db, "SELECT content, postID, authorID, postTime, editedAt, visibility FROM posts WHERE "+condition+
+		" ORDER BY "+order+" LIMIT ?", append(args, limit)...)
+}
+
+//queryPosts scans the content, postID, authorID, postTime, editedAt and visibility columns of query into posts
+func queryPosts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]Post, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []Post{}
+	for rows.Next() {
+		post := Post{}
+		var editedAt sql.NullTime
+		err = rows.Scan(&post.PostBody, &post.PostID, &post.AuthorID, &post.PostTime, &editedAt, &post.Visibility)
+		if err != nil {
+			return nil, err
+		}
+		if editedAt.Valid {
+			post.EditedAt = &editedAt.Time
+		}
+		result = append(result, post)
+	}
+	return result, rows.Err()
+}
+
+func (s *MySQLPostStore) PostAuthor(ctx context.Context, postID string) (string, error) {
+	defer metrics.TimeQuery("posts", "PostAuthor")()
+	var authorID string
+	err := s.db.QueryRowContext(ctx, "SELECT authorID FROM posts WHERE postID = ?", postID).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return "", ErrPostNotFound
+	}
+	return authorID, err
+}
+
+//DeletePost deletes the post with its revisions, reactions and comments in one transaction, the
+//post goes first so reactions and comments inserted meanwhile wait for it and find no post
+func (s *MySQLPostStore) DeletePost(ctx context.Context, postID string) error {
+	defer metrics.TimeQuery("posts", "DeletePost")()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM posts WHERE postID = ?", postID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrPostNotFound
+	}
+	for _, query := range []string{
+		"DELETE FROM post_revisions WHERE postID = ?",
+		"DELETE FROM post_reactions WHERE postID = ?",
+		"DELETE FROM comments WHERE postID = ?",
+	} {
+		_, err = tx.ExecContext(ctx, query, postID)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *MySQLPostStore) Post(ctx context.Context, postID string) (Post, error) {
+	defer metrics.TimeQuery("posts", "Post")()
+	found, err := queryPosts(ctx, s.db, "SELECT content, postID, authorID, postTime, editedAt, visibility FROM posts WHERE postID = ?", postID)
+	if err != nil {
+		return Post{}, err
+	}
+	if len(found) == 0 {
+		return Post{}, ErrPostNotFound
+	}
+	return found[0], nil
+}
+
+//EditPost locks the post so concurrent edits number their revisions one after the other
+func (s *MySQLPostStore) EditPost(ctx context.Context, postID string, body string, editedAt time.Time) (Post, error) {
+	defer metrics.TimeQuery("posts", "EditPost")()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Post{}, err
+	}
+	defer tx.Rollback()
+
+	post := Post{}
+	err = tx.QueryRowContext(ctx, "SELECT content, postID, authorID, postTime, visibility FROM posts WHERE postID = ? FOR UPDATE", postID).
+		Scan(&post.PostBody, &post.PostID, &post.AuthorID, &post.PostTime, &post.Visibility)
+	if err == sql.ErrNoRows {
+		return Post{}, ErrPostNotFound
+	}
+	if err != nil {
+		return Post{}, err
+	}
+	var revisions int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM post_revisions WHERE postID = ?", postID).Scan(&revisions)
+	if err != nil {
+		return Post{}, err
+	}
+
+	//the original is kept as the first revision on the first edit
+	if revisions == 0 {
+		_, err = tx.ExecContext(ctx, "INSERT INTO post_revisions (postID, revision, content, revisedAt) VALUES (?,?,?,?)",
+			postID, 1, post.PostBody, post.PostTime)
+		if err != nil {
+			return Post{}, err
+		}
+		revisions = 1
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO post_revisions (postID, revision, content, revisedAt) VALUES (?,?,?,?)",
+		postID, revisions+1, body, editedAt)
+	if err != nil {
+		return Post{}, err
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE posts SET content = ?, editedAt = ? WHERE postID = ?", body, editedAt, postID)
+	if err != nil {
+		return Post{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return Post{}, err
+	}
+	post.PostBody = body
+	post.EditedAt = &editedAt
+	return post, nil
+}
+
+func (s *MySQLPostStore) Revisions(ctx context.Context, postID string) ([]Revision, error) {
+	defer metrics.TimeQuery("posts", "Revisions")()
+	rows, err := s.db.QueryContext(ctx, "SELECT revision, content, revisedAt FROM post_revisions WHERE postID = ? ORDER BY revision", postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []Revision{}
+	for rows.Next() {
+		revision := Revision{}
+		err = rows.Scan(&revision.Revision, &revision.PostBody, &revision.RevisedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revision)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		return result, nil
+	}
+
+	//an unedited post has no rows, its only version is the original
+	post, err := s.Post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	return []Revision{{Revision: 1, PostBody: post.PostBody, RevisedAt: post.PostTime}}, nil
+}
+
+//React inserts the reaction only while the post exists, the primary key of post_reactions keeps
+//a single reaction of each kind per user
+func (s *MySQLPostStore) React(ctx context.Context, postID string, kind string, userID string, reactedAt time.Time) error {
+	defer metrics.TimeQuery("posts", "React")()
+	result, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO post_reactions (postID, kind, userID, reactedAt) "+
+		"SELECT postID, ?, ?, ? FROM posts WHERE postID = ?", kind, userID, reactedAt, postID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows > 0 {
+		return err
+	}
+	//nothing was inserted, either the user already reacted or there's no post
+	_, err = s.PostAuthor(ctx, postID)
+	return err
+}
+
+func (s *MySQLPostStore) Unreact(ctx context.Context, postID string, kind string, userID string) error {
+	defer metrics.TimeQuery("posts", "Unreact")()
+	result, err := s.db.ExecContext(ctx, "DELETE FROM post_reactions WHERE postID = ? AND kind = ? AND userID = ?", postID, kind, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil || rows > 0 {
+		return err
+	}
+	_, err = s.PostAuthor(ctx, postID)
+	return err
+}
+
+func (s *MySQLPostStore) Reactions(ctx context.Context, postIDs []string, userID string) (map[string]Reactions, error) {
+	defer metrics.TimeQuery("posts", "Reactions")()
+	result := make(map[string]Reactions, len(postIDs))
+	for _, postID := range postIDs {
+		result[postID] = Reactions{Counts: make(map[string]int), Mine: []string{}}
+	}
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+	in := "postID IN (?" + strings.Repeat(",?", len(postIDs)-1) + ")"
+
+	rows, err := s.db.QueryContext(ctx, "SELECT postID, kind, COUNT(*) FROM post_reactions WHERE "+in+" GROUP BY postID, kind", stringArgs(postIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID, kind string
+		var count int
+		err = rows.Scan(&postID, &kind, &count)
+		if err != nil {
+			return nil, err
+		}
+		result[postID].Counts[kind] = count
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	mine, err := s.db.QueryContext(ctx, "SELECT postID, kind FROM post_reactions WHERE userID = ? AND "+in+" ORDER BY kind",
+		append([]interface{}{userID}, stringArgs(postIDs)...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer mine.Close()
+	for mine.Next() {
+		var postID, kind string
+		err = mine.Scan(&postID, &kind)
+		if err != nil {
+			return nil, err
+		}
+		summary := result[postID]
+		summary.Mine = append(summary.Mine, kind)
+		result[postID] = summary
+	}
+	return result, mine.Err()
+}
+
+func (s *MySQLPostStore) Reactors(ctx context.Context, postID string, kind string, after Cursor, limit int) ([]Reaction, error) {
+	defer metrics.TimeQuery("posts", "Reactors")()
+	query, args := "SELECT userID, reactedAt FROM post_reactions WHERE postID = ? AND kind = ?", []interface{}{postID, kind}
+	if !after.IsZero() {
+		query += " AND (reactedAt < ? OR (reactedAt = ? AND userID < ?))"
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	rows, err := s.db.QueryContext(ctx, query+" ORDER BY reactedAt DESC, userID DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []Reaction{}
+	for rows.Next() {
+		reaction := Reaction{}
+		err = rows.Scan(&reaction.UserID, &reaction.ReactedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, reaction)
+	}
+	err = rows.Err()
+	if err != nil || len(result) > 0 {
+		return result, err
+	}
+	//an empty page could also mean there's no post
+	_, err = s.PostAuthor(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+//CreateComment inserts the comment only while the post exists
+func (s *MySQLPostStore) CreateComment(ctx context.Context, comment Comment) error {
+	defer metrics.TimeQuery("posts", "CreateComment")()
+	result, err := s.db.ExecContext(ctx, "INSERT INTO comments (commentID, postID, parentID, authorID, content, depth, createdAt) "+
+		"SELECT ?, postID, ?, ?, ?, ?, ? FROM posts WHERE postID = ?",
+		comment.CommentID, comment.ParentID, comment.AuthorID, comment.Body, comment.Depth, comment.CreatedAt, comment.PostID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrPostNotFound
+	}
+	return nil
+}
+
+func (s *MySQLPostStore) Comment(ctx context.Context, commentID string) (Comment, error) {
+	defer metrics.TimeQuery("posts", "Comment")()
+	found, err := s.queryComments(ctx, "SELECT "+commentColumns+" FROM comments WHERE commentID = ?", commentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	if len(found) == 0 {
+		return Comment{}, ErrCommentNotFound
+	}
+	return found[0], nil
+}
+
+func (s *MySQLPostStore) Comments(ctx context.Context, postID string, parentID string, after Cursor, limit int) ([]Comment, error) {
+	defer metrics.TimeQuery("posts", "Comments")()
+	query, args := "SELECT "+commentColumns+" FROM comments WHERE postID = ? AND parentID = ?", []interface{}{postID, parentID}
+	if !after.IsZero() {
+		query += " AND (createdAt > ? OR (createdAt = ? AND commentID > ?))"
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	found, err := s.queryComments(ctx, query+" ORDER BY createdAt, commentID LIMIT ?", append(args, limit)...)
+	if err != nil || len(found) > 0 {
+		return found, err
+	}
+	//an empty page could also mean there's no post
+	_, err = s.PostAuthor(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+//commentColumns are the columns scanned by queryComments
+const commentColumns = "commentID, postID, parentID, authorID, content, depth, createdAt, editedAt"
+
+//queryComments scans the commentColumns of query into comments, counting their replies
+func (s *MySQLPostStore) queryComments(ctx context.Context, query string, args ...interface{}) ([]Comment, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	_, err = DB.Query("SELECT * FROM posts")
-	for err != nil {
-		log.Println("couldnt connect, waiting 20 seconds before retrying")
-		time.Sleep(20*time.Second)
-		DB, err = sql.Open("mysql", "root:root@tcp(172.28.1.2:3306)/postsDB?parseTime=true")
+	result := []Comment{}
+	for rows.Next() {
+		comment := Comment{}
+		var editedAt sql.NullTime
+		err = rows.Scan(&comment.CommentID, &comment.PostID, &comment.ParentID, &comment.AuthorID, &comment.Body,
+			&comment.Depth, &comment.CreatedAt, &editedAt)
+		if err != nil {
+			return nil, err
+		}
+		if editedAt.Valid {
+			comment.EditedAt = &editedAt.Time
+		}
+		result = append(result, comment)
+	}
+	err = rows.Err()
+	if err != nil || len(result) == 0 {
+		return result, err
+	}
+
+	commentIDs := make([]string, len(result))
+	for i, comment := range result {
+		commentIDs[i] = comment.CommentID
+	}
+	replies, err := s.db.QueryContext(ctx, "SELECT parentID, COUNT(*) FROM comments WHERE parentID IN (?"+
+		strings.Repeat(",?", len(commentIDs)-1)+") GROUP BY parentID", stringArgs(commentIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer replies.Close()
+	counts := make(map[string]int, len(result))
+	for replies.Next() {
+		var parentID string
+		var count int
+		err = replies.Scan(&parentID, &count)
+		if err != nil {
+			return nil, err
+		}
+		counts[parentID] = count
+	}
+	for i := range result {
+		result[i].Replies = counts[result[i].CommentID]
+	}
+	return result, replies.Err()
+}
+
+func (s *MySQLPostStore) EditComment(ctx context.Context, commentID string, body string, editedAt time.Time) (Comment, error) {
+	defer metrics.TimeQuery("posts", "EditComment")()
+	result, err := s.db.ExecContext(ctx, "UPDATE comments SET content = ?, editedAt = ? WHERE commentID = ?", body, editedAt, commentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return Comment{}, err
+	}
+	if rows == 0 {
+		return Comment{}, ErrCommentNotFound
+	}
+	return s.Comment(ctx, commentID)
+}
+
+//DeleteComment collects the replies depth after depth, then deletes them all with the comment
+func (s *MySQLPostStore) DeleteComment(ctx context.Context, commentID string) error {
+	defer metrics.TimeQuery("posts", "DeleteComment")()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	thread := []string{commentID}
+	for parents := thread; len(parents) > 0; {
+		rows, err := tx.QueryContext(ctx, "SELECT commentID FROM comments WHERE parentID IN (?"+
+			strings.Repeat(",?", len(parents)-1)+")", stringArgs(parents)...)
+		if err != nil {
+			return err
+		}
+		parents, err = scanStrings(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		thread = append(thread, parents...)
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM comments WHERE commentID IN (?"+
+		strings.Repeat(",?", len(thread)-1)+")", stringArgs(thread)...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrCommentNotFound
 	}
+	return tx.Commit()
+}
 
-	return DB
-}
\ No newline at end of file
+func (s *MySQLPostStore) CommentCounts(ctx context.Context, postIDs []string) (map[string]int, error) {
+	defer metrics.TimeQuery("posts", "CommentCounts")()
+	result := make(map[string]int, len(postIDs))
+	for _, postID := range postIDs {
+		result[postID] = 0
+	}
+	if len(postIDs) == 0 {
+		return result, nil
+	}
+	rows, err := s.db.QueryContext(ctx, "SELECT postID, COUNT(*) FROM comments WHERE postID IN (?"+
+		strings.Repeat(",?", len(postIDs)-1)+") GROUP BY postID", stringArgs(postIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID string
+		var count int
+		err = rows.Scan(&postID, &count)
+		if err != nil {
+			return nil, err
+		}
+		result[postID] = count
+	}
+	return result, rows.Err()
+}
+
+//MySQLTimelines keeps the timelines in the timelines table, next to the posts table they index
+type MySQLTimelines struct {
+	db *sql.DB
+}
+
+//NewMySQLTimelines creates a Timelines backed by db
+func NewMySQLTimelines(db *sql.DB) *MySQLTimelines {
+	return &MySQLTimelines{db: db}
+}
+
+func (t *MySQLTimelines) Push(ctx context.Context, post Post, userIDs []string) error {
+	defer metrics.TimeQuery("timelines", "Push")()
+	if len(userIDs) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, 4*len(userIDs))
+	for _, userID := range userIDs {
+		args = append(args, userID, post.PostID, post.AuthorID, post.PostTime)
+	}
+	_, err := t.db.ExecContext(ctx, "INSERT IGNORE INTO timelines (userID, postID, authorID, postTime) VALUES (?,?,?,?)"+
+		strings.Repeat(",(?,?,?,?)", len(userIDs)-1), args...)
+	return err
+}
+
+func (t *MySQLTimelines) Remove(ctx context.Context, postID string) error {
+	defer metrics.TimeQuery("timelines", "Remove")()
+	_, err := t.db.ExecContext(ctx, "DELETE FROM timelines WHERE postID = ?", postID)
+	return err
+}
+
+func (t *MySQLTimelines) MarkPulled(ctx context.Context, authorID string) error {
+	defer metrics.TimeQuery("timelines", "MarkPulled")()
+	_, err := t.db.ExecContext(ctx, "INSERT IGNORE INTO pulled_authors (authorID) VALUES (?)", authorID)
+	return err
+}
+
+//MarkPushed forgets that the timelines were synced with authorID, so they backfill the posts
+//written while it was pulled
+func (t *MySQLTimelines) MarkPushed(ctx context.Context, authorID string) error {
+	defer metrics.TimeQuery("timelines", "MarkPushed")()
+	_, err := t.db.ExecContext(ctx, "DELETE FROM timeline_authors WHERE authorID = ?", authorID)
+	if err != nil {
+		return err
+	}
+	_, err = t.db.ExecContext(ctx, "DELETE FROM pulled_authors WHERE authorID = ?", authorID)
+	return err
+}
+
+func (t *MySQLTimelines) Pulled(ctx context.Context, authorIDs []string) ([]string, error) {
+	defer metrics.TimeQuery("timelines", "Pulled")()
+	if len(authorIDs) == 0 {
+		return []string{}, nil
+	}
+	rows, err := t.db.QueryContext(ctx, "SELECT authorID FROM pulled_authors WHERE authorID IN (?"+
+		strings.Repeat(",?", len(authorIDs)-1)+")", stringArgs(authorIDs)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanStrings(rows)
+}
+
+func (t *MySQLTimelines) Sync(ctx context.Context, userID string, authorIDs []string, backfill int) error {
+	defer metrics.TimeQuery("timelines", "Sync")()
+	rows, err := t.db.QueryContext(ctx, "SELECT authorID FROM timeline_authors WHERE userID = ?", userID)
+	if err != nil {
+		return err
+	}
+	synced, err := scanStrings(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(synced))
+	for _, authorID := range synced {
+		known[authorID] = true
+	}
+	if sameSet(known, authorIDs) {
+		return nil
+	}
+
+	//prune the posts of the authors who aren't friends anymore
+	prune, args := "DELETE FROM %s WHERE userID = ?", []interface{}{userID}
+	if len(authorIDs) > 0 {
+		prune += " AND authorID NOT IN (?" + strings.Repeat(",?", len(authorIDs)-1) + ")"
+		args = append(args, stringArgs(authorIDs)...)
+	}
+	for _, table := range []string{"timelines", "timeline_authors"} {
+		_, err = t.db.ExecContext(ctx, fmt.Sprintf(prune, table), args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	//copy the recent posts of the new friends, the author is recorded last so a failure is retried
+	for _, authorID := range authorIDs {
+		if known[authorID] {
+			continue
+		}
+		_, err = t.db.ExecContext(ctx, "INSERT IGNORE INTO timelines (userID, postID, authorID, postTime) "+
+			"SELECT ?, postID, authorID, postTime FROM posts WHERE authorID = ? ORDER BY postTime DESC, postID DESC LIMIT ?",
+			userID, authorID, backfill)
+		if err != nil {
+			return err
+		}
+		_, err = t.db.ExecContext(ctx, "INSERT IGNORE INTO timeline_authors (userID, authorID) VALUES (?,?)", userID, authorID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *MySQLTimelines) Timeline(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error) {
+	defer metrics.TimeQuery("timelines", "Timeline")()
+	query, args := "SELECT p.content, p.postID, p.authorID, p.postTime, p.editedAt, p.visibility FROM timelines t JOIN posts p ON p.postID = t.postID "+
+		"WHERE t.userID = ? AND (p.authorID = ? OR p.visibility != ?)", []interface{}{userID, userID, VisibilityPrivate}
+	if !after.IsZero() {
+		query += " AND (t.postTime < ? OR (t.postTime = ? AND t.postID < ?))"
+		args = append(args, after.Time, after.Time, after.ID)
+	}
+	return queryPosts(ctx, t.db, query+" ORDER BY t.postTime DESC, t.postID DESC LIMIT ?", append(args, limit)...)
+}
+
+//sameSet reports whether values holds the keys of set and nothing else
+func sameSet(set map[string]bool, values []string) bool {
+	found := make(map[string]bool, len(values))
+	for _, value := range values {
+		if !set[value] {
+			return false
+		}
+		found[value] = true
+	}
+	return len(found) == len(set)
+}
+
+//stringArgs passes values to the placeholders of a query
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return args
+}
+
+//scanStrings reads the only column of rows
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	result := []string{}
+	for rows.Next() {
+		var value string
+		err := rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value)
+	}
+	return result, rows.Err()
+}
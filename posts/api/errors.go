@@ -0,0 +1,8 @@
+package api
+
+//The error codes of the posts service, next to the shared ones of the problem package
+const (
+	CodePostNotFound     = "post_not_found"
+	CodeEditWindowClosed = "edit_window_closed"
+	CodeCommentNotFound  = "comment_not_found"
+)
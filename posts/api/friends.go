@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+)
+
+//FriendLister lists the friends of a user for the home feed
+type FriendLister interface {
+	//Friends returns the userIDs of the friends of userID, accessToken is the caller's access_token
+	Friends(ctx context.Context, userID string, accessToken string) ([]string, error)
+	//Refresh is Friends bypassing any cache, for the checks a stale list would get wrong
+	Refresh(ctx context.Context, userID string, accessToken string) ([]string, error)
+}
+
+//friendLister is the FriendLister used by the handlers, set by RegisterRoutes
+var friendLister FriendLister
+
+//FriendsFunc adapts a function to a FriendLister, e.g. to read an in-process friends graph
+type FriendsFunc func(ctx context.Context, userID string, accessToken string) ([]string, error)
+
+func (f FriendsFunc) Friends(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	return f(ctx, userID, accessToken)
+}
+
+func (f FriendsFunc) Refresh(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	return f(ctx, userID, accessToken)
+}
+
+//FriendsClient asks the friends-service, which knows the user from the access token it is passed
+type FriendsClient struct {
+	url    string
+	client *http.Client
+}
+
+//NewFriendsClient creates a FriendLister calling the friends-service at url, giving up after timeout
+func NewFriendsClient(url string, timeout time.Duration) *FriendsClient {
+	return &FriendsClient{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Transport: tracing.Transport(http.DefaultTransport), Timeout: timeout},
+	}
+}
+
+func (c *FriendsClient) Friends(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	defer metrics.TimeQuery("friends", "Friends")()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/friends", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken})
+	req.Header.Set("Accept", "application/json")
+	logging.PropagateRequestID(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("friends-service answered %s", resp.Status)
+	}
+	friends := []string{}
+	err = json.NewDecoder(resp.Body).Decode(&friends)
+	if err != nil {
+		return nil, fmt.Errorf("decoding the friends: %w", err)
+	}
+	return friends, nil
+}
+
+//Refresh is Friends, the client keeps nothing
+func (c *FriendsClient) Refresh(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	return c.Friends(ctx, userID, accessToken)
+}
+
+//CachedFriends keeps the friend lists of next for ttl, so a feed doesn't wait for the
+//friends-service on every page. An expired list is still used while next fails.
+type CachedFriends struct {
+	next FriendLister
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]cachedFriendList
+}
+
+type cachedFriendList struct {
+	friends []string
+	expires time.Time
+}
+
+//NewCachedFriends caches up to size friend lists of next for ttl
+func NewCachedFriends(next FriendLister, ttl time.Duration, size int) *CachedFriends {
+	return &CachedFriends{next: next, ttl: ttl, size: size, entries: make(map[string]cachedFriendList)}
+}
+
+func (c *CachedFriends) Friends(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	c.mu.Lock()
+	entry, found := c.entries[userID]
+	c.mu.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		friendsCacheRequests.WithLabelValues("hit").Inc()
+		return entry.friends, nil
+	}
+	friendsCacheRequests.WithLabelValues("miss").Inc()
+
+	friends, err := c.next.Friends(ctx, userID, accessToken)
+	if err != nil {
+		if found {
+			slog.WarnContext(ctx, "error listing the friends, using the expired list", "err", err)
+			return entry.friends, nil
+		}
+		return nil, err
+	}
+	c.store(userID, friends)
+	return friends, nil
+}
+
+//Refresh lists the friends of userID from next, replacing the cached list
+func (c *CachedFriends) Refresh(ctx context.Context, userID string, accessToken string) ([]string, error) {
+	friends, err := c.next.Friends(ctx, userID, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	c.store(userID, friends)
+	return friends, nil
+}
+
+//store caches the friends of userID for ttl
+func (c *CachedFriends) store(userID string, friends []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[userID] = cachedFriendList{friends: friends, expires: time.Now().Add(c.ttl)}
+}
+
+//evict makes room for an entry, dropping the expired lists or else any one
+func (c *CachedFriends) evict() {
+	now := time.Now()
+	for userID, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, userID)
+		}
+	}
+	for userID := range c.entries {
+		if len(c.entries) < c.size {
+			return
+		}
+		delete(c.entries, userID)
+	}
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//MemoryPostStore is an in-memory PostStore, used for tests and local development
+type MemoryPostStore struct {
+	mu        sync.Mutex
+	posts     map[string]Post
+	revisions map[string][]Revision
+	reactions map[string]map[reactionKey]time.Time
+	comments  map[string]Comment
+}
+
+//reactionKey is the kind and the user of a reaction to a post, a user reacts once with each kind
+type reactionKey struct {
+	kind   string
+	userID string
+}
+
+//NewMemoryPostStore creates an empty MemoryPostStore
+func NewMemoryPostStore() *MemoryPostStore {
+	return &MemoryPostStore{
+		posts:     make(map[string]Post),
+		revisions: make(map[string][]Revision),
+		reactions: make(map[string]map[reactionKey]time.Time),
+		comments:  make(map[string]Comment),
+	}
+}
+
+func (s *MemoryPostStore) CreatePost(ctx context.Context, post Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[post.PostID]; ok {
+		return errors.New("duplicate postID")
+	}
+	//like the default of the visibility column
+	if post.Visibility == "" {
+		post.Visibility = VisibilityPublic
+	}
+	s.posts[post.PostID] = post
+	return nil
+}
+
+func (s *MemoryPostStore) UserPosts(ctx context.Context, authorID string, visibilities []string, after Cursor, limit int) ([]Post, error) {
+	visible := make(map[string]bool, len(visibilities))
+	for _, visibility := range visibilities {
+		visible[visibility] = true
+	}
+	return s.page(func(post Post) bool { return post.AuthorID == authorID && visible[post.Visibility] }, after, false, limit), nil
+}
+
+func (s *MemoryPostStore) Discover(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error) {
+	return s.page(func(post Post) bool { return post.AuthorID != userID && post.Visibility == VisibilityPublic }, after, true, limit), nil
+}
+
+func (s *MemoryPostStore) AuthorsFeed(ctx context.Context, userID string, authorIDs []string, after Cursor, limit int) ([]Post, error) {
+	authors := make(map[string]bool, len(authorIDs))
+	for _, authorID := range authorIDs {
+		authors[authorID] = true
+	}
+	return s.page(func(post Post) bool { return authors[post.AuthorID] && visibleToFriend(post, userID) }, after, true, limit), nil
+}
+
+//page returns the matching posts ordered like the MySQL queries
+func (s *MemoryPostStore) page(match func(Post) bool, after Cursor, newestFirst bool, limit int) []Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matching := []Post{}
+	for _, post := range s.posts {
+		if !match(post) {
+			continue
+		}
+		if newestFirst && after.Follows(post) || !newestFirst && after.Precedes(post) {
+			matching = append(matching, post)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		older := matching[i].PostTime.Before(matching[j].PostTime)
+		if matching[i].PostTime.Equal(matching[j].PostTime) {
+			older = matching[i].PostID < matching[j].PostID
+		}
+		return older != newestFirst
+	})
+
+	if limit < len(matching) {
+		matching = matching[:limit]
+	}
+	return matching
+}
+
+func (s *MemoryPostStore) PostAuthor(ctx context.Context, postID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[postID]
+	if !ok {
+		return "", ErrPostNotFound
+	}
+	return post.AuthorID, nil
+}
+
+func (s *MemoryPostStore) DeletePost(ctx context.Context, postID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[postID]; !ok {
+		return ErrPostNotFound
+	}
+	delete(s.posts, postID)
+	delete(s.revisions, postID)
+	delete(s.reactions, postID)
+	for commentID, comment := range s.comments {
+		if comment.PostID == postID {
+			delete(s.comments, commentID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryPostStore) Post(ctx context.Context, postID string) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[postID]
+	if !ok {
+		return Post{}, ErrPostNotFound
+	}
+	return post, nil
+}
+
+func (s *MemoryPostStore) EditPost(ctx context.Context, postID string, body string, editedAt time.Time) (Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[postID]
+	if !ok {
+		return Post{}, ErrPostNotFound
+	}
+	revisions := s.revisions[postID]
+	if len(revisions) == 0 {
+		revisions = []Revision{{Revision: 1, PostBody: post.PostBody, RevisedAt: post.PostTime}}
+	}
+	s.revisions[postID] = append(revisions, Revision{Revision: len(revisions) + 1, PostBody: body, RevisedAt: editedAt})
+	post.PostBody = body
+	post.EditedAt = &editedAt
+	s.posts[postID] = post
+	return post, nil
+}
+
+func (s *MemoryPostStore) Revisions(ctx context.Context, postID string) ([]Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post, ok := s.posts[postID]
+	if !ok {
+		return nil, ErrPostNotFound
+	}
+	if len(s.revisions[postID]) == 0 {
+		return []Revision{{Revision: 1, PostBody: post.PostBody, RevisedAt: post.PostTime}}, nil
+	}
+	return append([]Revision{}, s.revisions[postID]...), nil
+}
+
+func (s *MemoryPostStore) React(ctx context.Context, postID string, kind string, userID string, reactedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[postID]; !ok {
+		return ErrPostNotFound
+	}
+	if s.reactions[postID] == nil {
+		s.reactions[postID] = make(map[reactionKey]time.Time)
+	}
+	key := reactionKey{kind: kind, userID: userID}
+	if _, ok := s.reactions[postID][key]; !ok {
+		s.reactions[postID][key] = reactedAt
+	}
+	return nil
+}
+
+func (s *MemoryPostStore) Unreact(ctx context.Context, postID string, kind string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[postID]; !ok {
+		return ErrPostNotFound
+	}
+	delete(s.reactions[postID], reactionKey{kind: kind, userID: userID})
+	return nil
+}
+
+func (s *MemoryPostStore) Reactions(ctx context.Context, postIDs []string, userID string) (map[string]Reactions, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]Reactions, len(postIDs))
+	for _, postID := range postIDs {
+		summary := Reactions{Counts: make(map[string]int), Mine: []string{}}
+		for key := range s.reactions[postID] {
+			summary.Counts[key.kind]++
+			if key.userID == userID {
+				summary.Mine = append(summary.Mine, key.kind)
+			}
+		}
+		sort.Strings(summary.Mine)
+		result[postID] = summary
+	}
+	return result, nil
+}
+
+func (s *MemoryPostStore) Reactors(ctx context.Context, postID string, kind string, after Cursor, limit int) ([]Reaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[postID]; !ok {
+		return nil, ErrPostNotFound
+	}
+	result := []Reaction{}
+	for key, reactedAt := range s.reactions[postID] {
+		if key.kind == kind && after.follows(reactedAt, key.userID) {
+			result = append(result, Reaction{UserID: key.userID, ReactedAt: reactedAt})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return cursorAfterReaction(result[i]).follows(result[j].ReactedAt, result[j].UserID)
+	})
+	if limit < len(result) {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (s *MemoryPostStore) CreateComment(ctx context.Context, comment Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[comment.PostID]; !ok {
+		return ErrPostNotFound
+	}
+	if _, ok := s.comments[comment.CommentID]; ok {
+		return errors.New("duplicate commentID")
+	}
+	s.comments[comment.CommentID] = comment
+	return nil
+}
+
+func (s *MemoryPostStore) Comment(ctx context.Context, commentID string) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return Comment{}, ErrCommentNotFound
+	}
+	comment.Replies = s.replies(commentID)
+	return comment, nil
+}
+
+//replies counts the direct replies to commentID
+func (s *MemoryPostStore) replies(commentID string) int {
+	count := 0
+	for _, comment := range s.comments {
+		if comment.ParentID == commentID {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *MemoryPostStore) Comments(ctx context.Context, postID string, parentID string, after Cursor, limit int) ([]Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[postID]; !ok {
+		return nil, ErrPostNotFound
+	}
+	result := []Comment{}
+	for _, comment := range s.comments {
+		if comment.PostID == postID && comment.ParentID == parentID && after.precedes(comment.CreatedAt, comment.CommentID) {
+			result = append(result, comment)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return cursorAfterComment(result[i]).precedes(result[j].CreatedAt, result[j].CommentID)
+	})
+	if limit < len(result) {
+		result = result[:limit]
+	}
+	for i := range result {
+		result[i].Replies = s.replies(result[i].CommentID)
+	}
+	return result, nil
+}
+
+func (s *MemoryPostStore) EditComment(ctx context.Context, commentID string, body string, editedAt time.Time) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment, ok := s.comments[commentID]
+	if !ok {
+		return Comment{}, ErrCommentNotFound
+	}
+	comment.Body = body
+	comment.EditedAt = &editedAt
+	s.comments[commentID] = comment
+	comment.Replies = s.replies(commentID)
+	return comment, nil
+}
+
+func (s *MemoryPostStore) DeleteComment(ctx context.Context, commentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.comments[commentID]; !ok {
+		return ErrCommentNotFound
+	}
+	//delete the replies depth after depth
+	for deleted := []string{commentID}; len(deleted) > 0; {
+		parents := make(map[string]bool, len(deleted))
+		for _, parentID := range deleted {
+			parents[parentID] = true
+			delete(s.comments, parentID)
+		}
+		deleted = nil
+		for id, comment := range s.comments {
+			if parents[comment.ParentID] {
+				deleted = append(deleted, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryPostStore) CommentCounts(ctx context.Context, postIDs []string) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]int, len(postIDs))
+	for _, postID := range postIDs {
+		result[postID] = 0
+	}
+	for _, comment := range s.comments {
+		if _, ok := result[comment.PostID]; ok {
+			result[comment.PostID]++
+		}
+	}
+	return result, nil
+}
+
+//MemoryTimelines is an in-memory Timelines over the posts of a MemoryPostStore
+type MemoryTimelines struct {
+	store *MemoryPostStore
+
+	mu      sync.Mutex
+	entries map[string]map[string]bool
+	authors map[string]map[string]bool
+	pulled  map[string]bool
+}
+
+//NewMemoryTimelines creates empty timelines of the posts of store
+func NewMemoryTimelines(store *MemoryPostStore) *MemoryTimelines {
+	return &MemoryTimelines{
+		store:   store,
+		entries: make(map[string]map[string]bool),
+		authors: make(map[string]map[string]bool),
+		pulled:  make(map[string]bool),
+	}
+}
+
+func (t *MemoryTimelines) Push(ctx context.Context, post Post, userIDs []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, userID := range userIDs {
+		if t.entries[userID] == nil {
+			t.entries[userID] = make(map[string]bool)
+		}
+		t.entries[userID][post.PostID] = true
+	}
+	return nil
+}
+
+func (t *MemoryTimelines) Remove(ctx context.Context, postID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, entries := range t.entries {
+		delete(entries, postID)
+	}
+	return nil
+}
+
+func (t *MemoryTimelines) MarkPulled(ctx context.Context, authorID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pulled[authorID] = true
+	return nil
+}
+
+func (t *MemoryTimelines) MarkPushed(ctx context.Context, authorID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.pulled, authorID)
+	for _, authors := range t.authors {
+		delete(authors, authorID)
+	}
+	return nil
+}
+
+func (t *MemoryTimelines) Pulled(ctx context.Context, authorIDs []string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := []string{}
+	for _, authorID := range authorIDs {
+		if t.pulled[authorID] {
+			result = append(result, authorID)
+		}
+	}
+	return result, nil
+}
+
+func (t *MemoryTimelines) Sync(ctx context.Context, userID string, authorIDs []string, backfill int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if synced, ok := t.authors[userID]; ok && sameSet(synced, authorIDs) {
+		return nil
+	}
+	if t.entries[userID] == nil {
+		t.entries[userID] = make(map[string]bool)
+	}
+	authors := make(map[string]bool, len(authorIDs))
+	for _, authorID := range authorIDs {
+		authors[authorID] = true
+		if !t.authors[userID][authorID] {
+			for _, post := range t.store.page(func(post Post) bool { return post.AuthorID == authorID }, Cursor{}, true, backfill) {
+				t.entries[userID][post.PostID] = true
+			}
+		}
+	}
+	for postID := range t.entries[userID] {
+		authorID, err := t.store.PostAuthor(ctx, postID)
+		if err != nil || !authors[authorID] {
+			delete(t.entries[userID], postID)
+		}
+	}
+	t.authors[userID] = authors
+	return nil
+}
+
+func (t *MemoryTimelines) Timeline(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error) {
+	t.mu.Lock()
+	entries := make(map[string]bool, len(t.entries[userID]))
+	for postID := range t.entries[userID] {
+		entries[postID] = true
+	}
+	t.mu.Unlock()
+	return t.store.page(func(post Post) bool { return entries[post.PostID] && visibleToFriend(post, userID) }, after, true, limit), nil
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+//the business metrics of posts, the HTTP and database ones come from the metrics package
+var (
+	postsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bearchat_posts_created_total",
+		Help: "Posts created.",
+	})
+	postsEdited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bearchat_posts_edited_total",
+		Help: "Posts edited.",
+	})
+	commentsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "bearchat_comments_created_total",
+		Help: "Comments and replies created.",
+	})
+	reactionsAdded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_reactions_added_total",
+		Help: "Reactions added to posts, by kind.",
+	}, []string{"kind"})
+	friendsCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_friends_cache_requests_total",
+		Help: "Friend lists read from the cache, by result (hit or miss).",
+	}, []string{"result"})
+	feedFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_feed_fallbacks_total",
+		Help: "Home feeds served in discover mode instead of friends, by reason (no_friends or unavailable).",
+	}, []string{"reason"})
+	timelineFanouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bearchat_timeline_fanouts_total",
+		Help: "New posts by fan-out result (pushed to the timelines, pulled at read time, or failed after the retries).",
+	}, []string{"result"})
+)
@@ -0,0 +1,8 @@
+package api
+
+import _ "embed"
+
+//OpenAPI is the OpenAPI 3 specification of the routes of RegisterRoutes
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
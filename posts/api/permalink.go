@@ -0,0 +1,81 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/gorilla/mux"
+)
+
+//Permalink is a post on its permalink, with the profile of the author
+type Permalink struct {
+	Post
+	Author Author `json:"author"`
+}
+
+func getPost(w http.ResponseWriter, r *http.Request) {
+	// Get the postID, any user who may see the post may read it
+	postID := mux.Vars(r)["postID"]
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	post, ok := visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
+
+	// Add the reactions, the comments and the author
+	found := []Post{post}
+	err := withCounts(r.Context(), found, userID)
+	if err != nil {
+		problem.Internal(w, r, "error counting the reactions and comments", err)
+		return
+	}
+	permalink := Permalink{Post: found[0]}
+	permalink.Author, err = authorReader.Author(r.Context(), post.AuthorID)
+	if err != nil {
+		//the post is still worth showing without the name of its author
+		slog.WarnContext(r.Context(), "error reading the profile of the author", "err", err)
+		permalink.Author = Author{UUID: post.AuthorID}
+	}
+
+	// The ETag is the hash of the body, which depends on the caller through myReactions
+	body, err := json.Marshal(permalink)
+	if err != nil {
+		problem.Internal(w, r, "error encoding the post", err)
+		return
+	}
+	etag := entityTag(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Vary", "Cookie")
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+//entityTag returns the strong ETag of body
+func entityTag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+//matchesETag reports whether the If-None-Match header lists etag, comparing weakly as RFC 9110 asks
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
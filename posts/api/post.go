@@ -2,10 +2,79 @@ package api
 
 import "time"
 
+//The visibilities of a post: to every user, to the friends of the author or to the author alone
+const (
+	VisibilityPublic  = "public"
+	VisibilityFriends = "friends"
+	VisibilityPrivate = "private"
+)
+
+//Post is a post of the feed, clients only send PostBody and it must fit the content column,
+//and on creation the Visibility, public when it's empty
 type Post struct {
-	PostBody  string    `json:"postBody"`
+	PostBody  string    `json:"postBody" validate:"notblank,max=255,nocontrol"`
 	PostID   string    `json:"postID"`
 	AuthorID string    `json:"AuthorID"`
 	PostTime time.Time `json:"postTime"`
 	PostAuthor string `json:"postAuthor"`
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+	Visibility string `json:"visibility" validate:"omitempty,oneof=public friends private"`
+	//Reactions counts the reactions by kind and MyReactions lists the kinds of the caller, both are
+	//filled in by withCounts, empty rather than missing, on every post served
+	Reactions   map[string]int `json:"reactions"`
+	MyReactions []string       `json:"myReactions"`
+	//Comments counts the comments of every depth, filled in by withCounts too
+	Comments int `json:"comments"`
+}
+
+//Author is the public part of the profile of the author of a post
+type Author struct {
+	UUID      string `json:"uuid"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+//Comment is a comment on a post or a reply to another comment of the post, clients only send
+//Body and, for a reply, ParentID. Depth is 1 for a comment on the post and grows with every reply.
+type Comment struct {
+	CommentID string     `json:"commentID"`
+	PostID    string     `json:"postID"`
+	ParentID  string     `json:"parentID,omitempty"`
+	AuthorID  string     `json:"authorID"`
+	Body      string     `json:"body" validate:"notblank,max=255,nocontrol"`
+	Depth     int        `json:"depth"`
+	Replies   int        `json:"replies"`
+	CreatedAt time.Time  `json:"createdAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"`
+}
+
+//Reaction is a user reacting to a post, once at most with each kind
+type Reaction struct {
+	UserID    string    `json:"userID"`
+	ReactedAt time.Time `json:"reactedAt"`
+}
+
+//Reactions sums up the reactions to a post, Counts by kind and the kinds Mine of the caller
+type Reactions struct {
+	Counts map[string]int `json:"reactions"`
+	Mine   []string       `json:"myReactions"`
+}
+
+//PostEdit is the body of an edit, only the body of a post can be changed, so a visibility or any
+//other field is rejected as unknown
+type PostEdit struct {
+	PostBody string `json:"postBody" validate:"notblank,max=255,nocontrol"`
+}
+
+//CommentEdit is the body of an edit of a comment, only its body can be changed, so a parentID or
+//any other field is rejected as unknown
+type CommentEdit struct {
+	Body string `json:"body" validate:"notblank,max=255,nocontrol"`
+}
+
+//Revision is one version of the body of a post, the first one is the original
+type Revision struct {
+	Revision  int       `json:"revision"`
+	PostBody  string    `json:"postBody"`
+	RevisedAt time.Time `json:"revisedAt"`
 }
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
+)
+
+//AuthorReader reads the profiles of the authors of posts
+type AuthorReader interface {
+	//Author returns the profile of userID, with only the UUID when the user has none
+	Author(ctx context.Context, userID string) (Author, error)
+}
+
+//authorReader is the AuthorReader used by the handlers, set by RegisterRoutes
+var authorReader AuthorReader
+
+//AuthorsFunc adapts a function to an AuthorReader, e.g. to read an in-process profile store
+type AuthorsFunc func(ctx context.Context, userID string) (Author, error)
+
+func (f AuthorsFunc) Author(ctx context.Context, userID string) (Author, error) {
+	return f(ctx, userID)
+}
+
+//ProfilesClient asks the profiles service for the profiles of the authors
+type ProfilesClient struct {
+	url    string
+	client *http.Client
+}
+
+//NewProfilesClient creates an AuthorReader calling the profiles service at url, giving up after timeout
+func NewProfilesClient(url string, timeout time.Duration) *ProfilesClient {
+	return &ProfilesClient{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Transport: tracing.Transport(http.DefaultTransport), Timeout: timeout},
+	}
+}
+
+func (c *ProfilesClient) Author(ctx context.Context, userID string) (Author, error) {
+	defer metrics.TimeQuery("profiles", "Author")()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/profile/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return Author{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	logging.PropagateRequestID(req)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return Author{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Author{UUID: userID}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Author{}, fmt.Errorf("profiles service answered %s", resp.Status)
+	}
+	author := Author{}
+	err = json.NewDecoder(resp.Body).Decode(&author)
+	if err != nil {
+		return Author{}, fmt.Errorf("decoding the profile: %w", err)
+	}
+	author.UUID = userID
+	return author, nil
+}
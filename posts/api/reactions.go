@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/gorilla/mux"
+)
+
+//reactionKinds are the reactions a user can add to a post, each at most once
+var reactionKinds = []string{"like", "love", "haha", "wow", "sad", "angry"}
+
+//ReactionPage is one page of the users who reacted to a post with a kind, newest first
+type ReactionPage struct {
+	Reactions  []Reaction `json:"reactions"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+//reactionKind reads the kind of the URL, adding it to fields unless it's one of reactionKinds
+func reactionKind(r *http.Request, fields *problem.Fields) string {
+	kind := mux.Vars(r)["kind"]
+	for _, known := range reactionKinds {
+		if kind == known {
+			return kind
+		}
+	}
+	fields.Add("kind", "invalid", "kind must be one of "+strings.Join(reactionKinds, ", "))
+	return kind
+}
+
+func react(w http.ResponseWriter, r *http.Request) {
+	// Get the postID and the kind of reaction, any user who may see the post may react
+	postID := mux.Vars(r)["postID"]
+	fields := problem.Fields{}
+	kind := reactionKind(r, &fields)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return
+	}
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	_, ok = visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
+
+	// Add the reaction on PUT and remove it on DELETE, both can be repeated
+	var err error
+	if r.Method == http.MethodPut {
+		err = posts.React(r.Context(), postID, kind, userID, time.Now())
+	} else {
+		err = posts.Unreact(r.Context(), postID, kind, userID)
+	}
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error updating the reactions", err)
+		return
+	}
+	if r.Method == http.MethodPut {
+		reactionsAdded.WithLabelValues(kind).Inc()
+	}
+
+	// Answer with the reactions to the post now
+	summaries, err := posts.Reactions(r.Context(), []string{postID}, userID)
+	if err != nil {
+		problem.Internal(w, r, "error counting the reactions", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries[postID])
+}
+
+func getReactions(w http.ResponseWriter, r *http.Request) {
+	// Get the postID, the kind and the page from the query
+	postID := mux.Vars(r)["postID"]
+	fields := problem.Fields{}
+	kind := reactionKind(r, &fields)
+	after, limit := pageParams(r, &fields)
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return
+	}
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
+	_, ok = visiblePost(w, r, userID, postID)
+	if !ok {
+		return
+	}
+
+	// Get a page of the users who reacted with kind, newest first, after the cursor
+	found, err := posts.Reactors(r.Context(), postID, kind, after, limit+1)
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error obtaining the reactions", err)
+		return
+	}
+
+	page := ReactionPage{Reactions: found}
+	if len(found) > limit {
+		page.Reactions = found[:limit]
+		page.NextCursor = cursorAfterReaction(page.Reactions[limit-1]).encode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
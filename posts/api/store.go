@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//ErrPostNotFound is returned by a PostStore when the requested post does not exist
+var ErrPostNotFound = errors.New("post not found")
+
+//ErrCommentNotFound is returned by a PostStore when the requested comment does not exist
+var ErrCommentNotFound = errors.New("comment not found")
+
+//PostStore persists the posts of every user
+type PostStore interface {
+	//CreatePost stores a new post
+	CreatePost(ctx context.Context, post Post) error
+	//UserPosts returns up to limit posts written by authorID with one of visibilities, oldest first, after the cursor
+	UserPosts(ctx context.Context, authorID string, visibilities []string, after Cursor, limit int) ([]Post, error)
+	//Discover returns up to limit public posts not written by userID, newest first, after the cursor
+	Discover(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error)
+	//AuthorsFeed returns up to limit posts written by any of authorIDs, the friends of userID or userID,
+	//newest first, after the cursor. The private posts are only the ones of userID.
+	AuthorsFeed(ctx context.Context, userID string, authorIDs []string, after Cursor, limit int) ([]Post, error)
+	//PostAuthor returns the authorID of the post
+	PostAuthor(ctx context.Context, postID string) (string, error)
+	//Post returns the post
+	Post(ctx context.Context, postID string) (Post, error)
+	//EditPost replaces the body of the post, keeping every version of it as a revision
+	EditPost(ctx context.Context, postID string, body string, editedAt time.Time) (Post, error)
+	//Revisions returns every version of the body of the post, oldest first
+	Revisions(ctx context.Context, postID string) ([]Revision, error)
+	//React records that userID reacted to the post with kind, reacting again with a kind changes nothing
+	React(ctx context.Context, postID string, kind string, userID string, reactedAt time.Time) error
+	//Unreact removes the reaction of userID to the post with kind, if any
+	Unreact(ctx context.Context, postID string, kind string, userID string) error
+	//Reactions sums up the reactions to each of postIDs, Mine being the kinds of userID
+	Reactions(ctx context.Context, postIDs []string, userID string) (map[string]Reactions, error)
+	//Reactors returns up to limit reactions to the post with kind, newest first, after the cursor
+	Reactors(ctx context.Context, postID string, kind string, after Cursor, limit int) ([]Reaction, error)
+	//CreateComment stores a new comment on an existing post
+	CreateComment(ctx context.Context, comment Comment) error
+	//Comment returns the comment
+	Comment(ctx context.Context, commentID string) (Comment, error)
+	//Comments returns up to limit replies to parentID on the post, or its comments when parentID is
+	//empty, oldest first, after the cursor, with their number of replies
+	Comments(ctx context.Context, postID string, parentID string, after Cursor, limit int) ([]Comment, error)
+	//EditComment replaces the body of the comment
+	EditComment(ctx context.Context, commentID string, body string, editedAt time.Time) (Comment, error)
+	//DeleteComment removes the comment and the replies to it, at every depth
+	DeleteComment(ctx context.Context, commentID string) error
+	//CommentCounts counts the comments of every depth on each of postIDs
+	CommentCounts(ctx context.Context, postIDs []string) (map[string]int, error)
+	//DeletePost removes the post and its revisions, reactions and comments
+	DeletePost(ctx context.Context, postID string) error
+}
+
+//posts is the store used by the handlers, set by RegisterRoutes
+var posts PostStore
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+//fanoutLimit is the most friends a post is pushed to, past it the posts of the author are
+//read at read time, as is the feed of a user with more friends. timelineBackfill is the
+//number of recent posts of a new friend copied into a timeline. A failed fan-out is retried
+//fanoutRetries times, waiting fanoutRetryDelay and then twice as long each time.
+var (
+	fanoutLimit      = 1000
+	timelineBackfill = 100
+	fanoutRetries    = 4
+	fanoutRetryDelay = time.Second
+)
+
+//Timelines materializes the friends feed of every user: a post is pushed to the timelines of
+//the friends of its author when it's written, so reading a feed is a single range scan
+type Timelines interface {
+	//Push adds post to the timelines of userIDs
+	Push(ctx context.Context, post Post, userIDs []string) error
+	//Remove takes the post out of every timeline
+	Remove(ctx context.Context, postID string) error
+	//MarkPulled records that the posts of authorID are no longer pushed but read with the timelines
+	MarkPulled(ctx context.Context, authorID string) error
+	//MarkPushed records that the posts of authorID are pushed again, every timeline copies the
+	//recent ones on its next Sync
+	MarkPushed(ctx context.Context, authorID string) error
+	//Pulled returns which of authorIDs were marked pulled
+	Pulled(ctx context.Context, authorIDs []string) ([]string, error)
+	//Sync makes authorIDs the authors of the timeline of userID, pruning the posts of the authors
+	//missing from it and copying up to backfill recent posts of the new ones. It writes nothing
+	//when the authors didn't change.
+	Sync(ctx context.Context, userID string, authorIDs []string, backfill int) error
+	//Timeline returns up to limit posts of the timeline of userID, newest first, after the cursor
+	Timeline(ctx context.Context, userID string, after Cursor, limit int) ([]Post, error)
+}
+
+//timelines is the Timelines used by the handlers, nil computes every feed at read time
+var timelines Timelines
+
+//fanOuts are the fan-outs running in the background, stopFanOuts cuts their retries short
+var (
+	fanOuts         sync.WaitGroup
+	stopFanOuts     = make(chan struct{})
+	stopFanOutsOnce sync.Once
+)
+
+//WaitFanOuts stops retrying the failed fan-outs and waits for the ones in flight, register it
+//with server.OnShutdown after the database so that it runs before the pool is closed
+func WaitFanOuts() error {
+	stopFanOutsOnce.Do(func() { close(stopFanOuts) })
+	fanOuts.Wait()
+	return nil
+}
+
+//fanOut pushes a new post of userID to the timelines of the author and their friends in the
+//background, an author with more than fanoutLimit friends is pulled instead. A failed attempt is
+//retried, and once the retries run out or the service stops the author is pulled, so the friends
+//still read the post. The next fan-out of the author pushes them again.
+func fanOut(r *http.Request, userID string, post Post) {
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return
+	}
+	//the fan-out outlives the request and must not see the stores of a later RegisterRoutes
+	lister, feeds := friendLister, timelines
+	ctx := context.WithoutCancel(r.Context())
+	fanOuts.Add(1)
+	go func() {
+		defer fanOuts.Done()
+		err := pushPost(ctx, lister, feeds, userID, cookie.Value, post)
+		delay := fanoutRetryDelay
+		for attempt := 0; err != nil && attempt < fanoutRetries; attempt++ {
+			slog.WarnContext(ctx, "error fanning out the post, retrying", "postID", post.PostID, "err", err)
+			if !waitRetry(delay) {
+				break
+			}
+			delay *= 2
+			err = pushPost(ctx, lister, feeds, userID, cookie.Value, post)
+		}
+		if err == nil {
+			return
+		}
+		timelineFanouts.WithLabelValues("failed").Inc()
+		pullErr := feeds.MarkPulled(ctx, userID)
+		if pullErr != nil {
+			slog.ErrorContext(ctx, "error fanning out the post, it's missing from the timelines", "postID", post.PostID, "err", err, "pullErr", pullErr)
+			return
+		}
+		slog.ErrorContext(ctx, "error fanning out the post, the author is pulled", "postID", post.PostID, "err", err)
+	}()
+}
+
+//waitRetry waits delay before the next attempt of a fan-out, false when the service is stopping
+func waitRetry(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stopFanOuts:
+		return false
+	}
+}
+
+//pushPost makes one attempt at fanning out post. It marks the author pulled for a friend list
+//longer than fanoutLimit, and pushed again when a pulled author has few enough friends.
+func pushPost(ctx context.Context, lister FriendLister, feeds Timelines, userID string, accessToken string, post Post) error {
+	//a private post only goes to the timeline of its author
+	friends := []string{}
+	if post.Visibility != VisibilityPrivate {
+		//a cached list could miss a new friend, who would never get the post
+		var err error
+		friends, err = lister.Refresh(ctx, userID, accessToken)
+		if err != nil {
+			return fmt.Errorf("listing the friends: %w", err)
+		}
+		if len(friends) > fanoutLimit {
+			timelineFanouts.WithLabelValues("pulled").Inc()
+			return feeds.MarkPulled(ctx, userID)
+		}
+	}
+	pulled, err := feeds.Pulled(ctx, []string{userID})
+	if err != nil {
+		return err
+	}
+	if len(pulled) > 0 {
+		//the friends of a private post weren't listed, the author stays pulled until the next post
+		if post.Visibility == VisibilityPrivate {
+			timelineFanouts.WithLabelValues("pulled").Inc()
+			return nil
+		}
+		err = feeds.MarkPushed(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("pushing the author again: %w", err)
+		}
+	}
+
+	//Push ignores the timelines already holding the post, so a retry can repeat it
+	err = feeds.Push(ctx, post, append(append(make([]string, 0, len(friends)+1), friends...), userID))
+	if err != nil {
+		return fmt.Errorf("pushing the post: %w", err)
+	}
+	timelineFanouts.WithLabelValues("pushed").Inc()
+	return nil
+}
+
+//timelineFeed reads a page of the friends feed of userID written by authors from the timeline,
+//merged with the posts of the pulled authors. The first page brings the timeline up to date
+//with authors when they changed, which prunes the unfriended and backfills the new friends.
+func timelineFeed(ctx context.Context, userID string, authors []string, after Cursor, limit int) ([]Post, error) {
+	pulled, err := timelines.Pulled(ctx, authors)
+	if err != nil {
+		return nil, err
+	}
+	if after.IsZero() {
+		err = timelines.Sync(ctx, userID, without(authors, pulled), timelineBackfill)
+		if err != nil {
+			return nil, err
+		}
+	}
+	feed, err := timelines.Timeline(ctx, userID, after, limit)
+	if err != nil || len(pulled) == 0 {
+		return feed, err
+	}
+	read, err := posts.AuthorsFeed(ctx, userID, pulled, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return mergeNewestFirst(feed, read, limit), nil
+}
+
+//without returns the userIDs not in excluded
+func without(userIDs []string, excluded []string) []string {
+	skip := make(map[string]bool, len(excluded))
+	for _, userID := range excluded {
+		skip[userID] = true
+	}
+	result := []string{}
+	for _, userID := range userIDs {
+		if !skip[userID] {
+			result = append(result, userID)
+		}
+	}
+	return result
+}
+
+//mergeNewestFirst merges two pages into up to limit posts, newest first, once each
+func mergeNewestFirst(a []Post, b []Post, limit int) []Post {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := []Post{}
+	for _, post := range append(append([]Post{}, a...), b...) {
+		if !seen[post.PostID] {
+			seen[post.PostID] = true
+			merged = append(merged, post)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return cursorAfter(merged[i]).Follows(merged[j])
+	})
+	if limit < len(merged) {
+		merged = merged[:limit]
+	}
+	return merged
+}
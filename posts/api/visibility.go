@@ -0,0 +1,77 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+)
+
+//allVisibilities are the visibilities of the posts their author sees
+var allVisibilities = []string{VisibilityPublic, VisibilityFriends, VisibilityPrivate}
+
+//visibleToFriend reports whether userID, the author of post or one of their friends, may see it
+func visibleToFriend(post Post, userID string) bool {
+	return post.AuthorID == userID || post.Visibility != VisibilityPrivate
+}
+
+//isFriend reports whether authorID is a friend of userID, the caller of r. The friend list is
+//read past the cache, so a former friend loses access right away. A friend list that can't be
+//read counts as no friends, so only the public posts are shown.
+func isFriend(r *http.Request, userID string, authorID string) bool {
+	cookie, err := r.Cookie("access_token")
+	if err != nil {
+		return false
+	}
+	friends, err := friendLister.Refresh(r.Context(), userID, cookie.Value)
+	if err != nil {
+		slog.WarnContext(r.Context(), "error listing the friends, showing the public posts only", "err", err)
+		return false
+	}
+	for _, friendID := range friends {
+		if friendID == authorID {
+			return true
+		}
+	}
+	return false
+}
+
+//visibilities returns the visibilities of the posts of authorID userID may see
+func visibilities(r *http.Request, userID string, authorID string) []string {
+	if userID == authorID {
+		return allVisibilities
+	}
+	if isFriend(r, userID, authorID) {
+		return []string{VisibilityPublic, VisibilityFriends}
+	}
+	return []string{VisibilityPublic}
+}
+
+//canView reports whether userID may see post
+func canView(r *http.Request, userID string, post Post) bool {
+	switch {
+	case post.AuthorID == userID || post.Visibility == VisibilityPublic:
+		return true
+	case post.Visibility == VisibilityFriends:
+		return isFriend(r, userID, post.AuthorID)
+	}
+	return false
+}
+
+//visiblePost returns the post when userID may see it, ok is false when an error was already
+//written to w, which is 404 for a post hidden from userID so its existence doesn't leak
+func visiblePost(w http.ResponseWriter, r *http.Request, userID string, postID string) (post Post, ok bool) {
+	post, err := posts.Post(r.Context(), postID)
+	if err == nil && !canView(r, userID, post) {
+		err = ErrPostNotFound
+	}
+	if err == ErrPostNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodePostNotFound, "the post cannot be found/doesn't exists")
+		return Post{}, false
+	}
+	if err != nil {
+		problem.Internal(w, r, "error getting the post", err)
+		return Post{}, false
+	}
+	return post, true
+}
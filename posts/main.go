@@ -1,51 +1,109 @@
 package main
 
 import (
+	"expvar"
 	"log"
-	"net/http"
+	"os"
 
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/cors"
+	"github.com/BearCloud/fa20-project-dev/backend/common/csrf"
+	"github.com/BearCloud/fa20-project-dev/backend/common/health"
+	"github.com/BearCloud/fa20-project-dev/backend/common/logging"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
+	"github.com/BearCloud/fa20-project-dev/backend/common/migrate"
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/server"
+	"github.com/BearCloud/fa20-project-dev/backend/common/tracing"
 	"github.com/BearCloud/fa20-project-dev/backend/posts/api"
+	"github.com/BearCloud/fa20-project-dev/backend/posts/migrations"
 	"github.com/gorilla/mux"
 )
 
 func main() {
-	//init db
-	DB := api.InitDB()
-	defer DB.Close()
+	//Load the configuration from the environment, .env and CONFIG_FILE
+	cfg := api.Config{}
+	config.MustLoad("posts-service", &cfg)
+	api.Configure(cfg)
 
-	//ping the database to make sure it's up
-	err := DB.Ping()
+	//SIGTERM stops the service, even while it waits for the database
+	ctx, stop := server.SignalContext()
+	defer stop()
+
+	//answer health probes while connecting, the api is served once it's ready
+	checker := health.New("posts-service")
+	srv := server.New(cfg.ListenAddr, checker, cfg.Server)
+
+	//export the spans of TRACING_EXPORTER, the last ones once everything else has stopped
+	flushTraces, err := tracing.Setup(ctx, "posts-service", cfg.Trace)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	srv.OnShutdown("tracing", flushTraces)
+	migrating := len(os.Args) > 1 && os.Args[1] == "migrate"
+	if !migrating {
+		ctx = srv.Start(ctx)
+	}
+
+	//Connect to the database, retrying with backoff until it's up
+	DB, err := api.InitDB(ctx, cfg.DatabaseDSN, cfg.Settings)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	//"main migrate up|down [steps]|status" manages the schema and exits
+	if migrating {
+		err = migrate.Command(DB, migrations.FS, os.Args[2:])
+		DB.Close()
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		return
+	}
+
+	//bring the schema up to date before serving requests
+	err = migrate.Up(DB, migrations.FS)
 	if err != nil {
-		panic(err.Error())
+		log.Fatal(err.Error())
 	}
+
 	// Create a new mux for routing api calls
 	router := mux.NewRouter()
-	router.Use(CORS)
+	problem.Routes(router)
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware)
+	router.Use(metrics.Middleware)
+	router.Use(cors.Middleware(cfg.CORSOrigins, "GET, POST, PUT, DELETE, OPTIONS"))
+	router.Use(csrf.New([]byte(cfg.CSRFSecret), cfg.Cookies()).Protect)
+
+	//pool statistics are published through expvar, the Prometheus metrics on /metrics
+	router.Handle("/debug/vars", expvar.Handler())
+	router.Handle("/metrics", metrics.Handler())
 
-	err = api.RegisterRoutes(router)
+	//the specification of the API on /openapi.yaml and /openapi.json
+	openapi.Routes(router, api.OpenAPI)
+
+	//TIMELINES materializes the home feeds when posts are written instead of when they are read
+	var timelines api.Timelines
+	if cfg.Timelines {
+		timelines = api.NewMySQLTimelines(DB)
+	}
+	err = api.RegisterRoutes(router, api.NewMySQLPostStore(DB), cfg.Friends(), timelines, cfg.Profiles())
 	if err != nil {
 		log.Fatal("Error registering API endpoints")
 	}
 
+	//the pool is closed once the requests in flight are done
+	srv.OnShutdown("database", DB.Close)
+	//the fan-outs in flight still write the timelines, they are done before the pool is closed
+	srv.OnShutdown("fan-outs", api.WaitFanOuts)
+	checker.AddCheck("mysql", DB.PingContext)
+	checker.Ready(router)
+
 	log.Println("listening...")
-	log.Fatal(http.ListenAndServe(":80", router))
+	err = srv.Run(ctx)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
 }
-
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		// Set headers
-		w.Header().Set("Access-Control-Allow-Headers:", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		// Next
-		next.ServeHTTP(w, r)
-		return
-	})
-}
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS posts (
+    content VARCHAR(255),
+    postID VARCHAR(36) PRIMARY KEY,
+    authorID VARCHAR(36),
+    postTime DATETIME
+);
@@ -0,0 +1,2 @@
+DROP INDEX posts_page ON posts;
+DROP INDEX posts_author_page ON posts;
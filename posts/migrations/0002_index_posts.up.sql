@@ -0,0 +1,2 @@
+CREATE INDEX posts_author_page ON posts (authorID, postTime, postID);
+CREATE INDEX posts_page ON posts (postTime, postID);
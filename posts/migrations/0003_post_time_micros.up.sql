@@ -0,0 +1 @@
+ALTER TABLE posts MODIFY postTime DATETIME(6);
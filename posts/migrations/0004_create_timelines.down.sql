@@ -0,0 +1,3 @@
+DROP TABLE pulled_authors;
+DROP TABLE timeline_authors;
+DROP TABLE timelines;
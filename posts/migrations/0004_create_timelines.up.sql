@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS timelines (
+    userID VARCHAR(36) NOT NULL,
+    postID VARCHAR(36) NOT NULL,
+    authorID VARCHAR(36) NOT NULL,
+    postTime DATETIME(6) NOT NULL,
+    PRIMARY KEY (userID, postTime, postID)
+);
+CREATE INDEX timelines_post ON timelines (postID);
+CREATE INDEX timelines_author ON timelines (userID, authorID);
+CREATE TABLE IF NOT EXISTS timeline_authors (
+    userID VARCHAR(36) NOT NULL,
+    authorID VARCHAR(36) NOT NULL,
+    PRIMARY KEY (userID, authorID)
+);
+CREATE TABLE IF NOT EXISTS pulled_authors (
+    authorID VARCHAR(36) PRIMARY KEY
+);
@@ -0,0 +1,8 @@
+ALTER TABLE posts ADD COLUMN editedAt DATETIME(6) NULL;
+CREATE TABLE IF NOT EXISTS post_revisions (
+    postID VARCHAR(36) NOT NULL,
+    revision INT NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    revisedAt DATETIME(6) NOT NULL,
+    PRIMARY KEY (postID, revision)
+);
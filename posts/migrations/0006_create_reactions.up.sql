@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS post_reactions (
+    postID VARCHAR(36) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    userID VARCHAR(36) NOT NULL,
+    reactedAt DATETIME(6) NOT NULL,
+    PRIMARY KEY (postID, kind, userID)
+);
+CREATE INDEX post_reactions_time ON post_reactions (postID, kind, reactedAt, userID);
+CREATE INDEX post_reactions_user ON post_reactions (userID, postID);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS comments (
+    commentID VARCHAR(36) PRIMARY KEY,
+    postID VARCHAR(36) NOT NULL,
+    parentID VARCHAR(36) NOT NULL,
+    authorID VARCHAR(36) NOT NULL,
+    content VARCHAR(255) NOT NULL,
+    depth INT NOT NULL,
+    createdAt DATETIME(6) NOT NULL,
+    editedAt DATETIME(6) NULL
+);
+CREATE INDEX comments_page ON comments (postID, parentID, createdAt, commentID);
+CREATE INDEX comments_parent ON comments (parentID);
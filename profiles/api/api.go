@@ -1,97 +1,101 @@
 package api
 
 import (
-	"log"
-	"net/http"
 	"encoding/json"
-	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/BearCloud/fa20-project-dev/backend/common/validate"
 	"github.com/gorilla/mux"
 )
 
-func RegisterRoutes(router *mux.Router) error {
+//RegisterRoutes initializes the api endpoints, the handlers keep their profiles in store
+func RegisterRoutes(router *mux.Router, store ProfileStore) error {
+	profiles = store
+
 	router.HandleFunc("/api/profile/{uuid}", getProfile).Methods(http.MethodGet)
 	router.HandleFunc("/api/profile/{uuid}", updateProfile).Methods(http.MethodPut)
 
 	return nil
 }
 
-func getUUID (w http.ResponseWriter, r *http.Request) (uuid string) {
+//getUUID returns the userID of the access_token, ok is false when an error was already written to w
+func getUUID(w http.ResponseWriter, r *http.Request) (uuid string, ok bool) {
 	cookie, err := r.Cookie("access_token")
 	if err != nil {
-		http.Error(w, errors.New("error obtaining cookie: " + err.Error()).Error(), http.StatusBadRequest)
-		log.Print(err.Error())
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated, "sign in first, the access_token cookie is missing")
+		return "", false
 	}
 	//validate the cookie
 	claims, err := ValidateToken(cookie.Value)
 	if err != nil {
-		http.Error(w, errors.New("error validating token: " + err.Error()).Error(), http.StatusUnauthorized)
-		log.Print(err.Error())
+		slog.WarnContext(r.Context(), "error validating token", "err", err)
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "invalid or expired access token")
+		return "", false
 	}
-	log.Println(claims)
 
-	return claims["UserID"].(string)
+	userID, ok := claims["UserID"].(string)
+	if !ok {
+		problem.Error(w, r, http.StatusUnauthorized, problem.CodeInvalidToken, "the token has no UserID")
+		return "", false
+	}
+	return userID, true
 }
 
 func getProfile(w http.ResponseWriter, r *http.Request) {
-
-	// Obtain the uuid from the url path and store it in a `uuid` variable
-	// Hint: mux.Vars()
+	// Obtain the uuid from the url path
 	uuid := mux.Vars(r)["uuid"]
-
-
-	// Initialize a new Profile variable
-	prof := Profile{}
+	fields := problem.Fields{}
+	validate.Var(&fields, "uuid", uuid, "uuid")
+	if len(fields) > 0 {
+		problem.Invalid(w, r, fields)
+		return
+	}
 
 	// Obtain all the information associated with the requested uuid
-	// Scan the information into the profile struct's variables
-	// Remember to pass in the address!
-	err := DB.QueryRow("SELECT * FROM users WHERE uuid = ?", uuid).Scan(&prof.Firstname, &prof.Lastname, &prof.Email, &prof.UUID)
-	
-	/*  Check for errors with querying the database
-		Return an Internal Server Error if such an error occurs
-	*/
-	if err != nil{
-		http.Error(w, errors.New("error validating token: " + err.Error()).Error(), http.StatusInternalServerError)
+	prof, err := profiles.GetProfile(r.Context(), uuid)
+	if err == ErrProfileNotFound {
+		problem.Error(w, r, http.StatusNotFound, CodeProfileNotFound, "this user has no profile")
+		return
+	}
+	if err != nil {
+		problem.Internal(w, r, "error retrieving the profile", err)
+		return
 	}
 
-  	//encode fetched data as json and serve to client
+	//encode fetched data as json and serve to client
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(prof)
-	return
 }
 
 func updateProfile(w http.ResponseWriter, r *http.Request) {
-	
-	// Obtain the requested uuid from the url path and store it in a `uuid` variable
+	// Obtain the requested uuid from the url path
 	uuid := mux.Vars(r)["uuid"]
 
 	// Obtain the userID from the cookie
-	userID := getUUID(w, r)
+	userID, ok := getUUID(w, r)
+	if !ok {
+		return
+	}
 
-	// If the two ID's don't match, return a StatusUnauthorized
+	// If the two ID's don't match, return a StatusForbidden
 	if userID != uuid {
-		http.Error(w, errors.New("uuid does not match").Error(), http.StatusUnauthorized)
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "only the owner may update a profile")
+		return
 	}
 
 	// Decode the Request Body's JSON data into a profile variable
 	updated_profile := Profile{}
-	err := json.NewDecoder(r.Body).Decode(&updated_profile)
-
-	// Return an InternalServerError if there is an error decoding the request body
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !validate.DecodeJSON(w, r, &updated_profile) {
+		return
 	}
 
-
-	// Insert the profile data into the users table
-	// Check db-server/initdb.sql for the scheme
-	// Make sure to use REPLACE INTO (as covered in the SQL homework)
-	_, err = DB.Exec("REPLACE INTO users (firstName, lastName, email, uuid) VALUES(?,?,?,?)", updated_profile.Firstname, updated_profile.Lastname, updated_profile.Email, updated_profile.UUID)
-
-	
-	// Return an internal server error if any errors occur when querying the database.
+	// The profile always belongs to the caller, whatever uuid the body names
+	updated_profile.UUID = uuid
+	err := profiles.PutProfile(r.Context(), updated_profile)
 	if err != nil {
-		http.Error(w, errors.New("error retrieving").Error(), http.StatusInternalServerError)
+		problem.Internal(w, r, "error storing the profile", err)
+		return
 	}
-
-	return
 }
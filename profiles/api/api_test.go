@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BearCloud/fa20-project-dev/backend/common/openapi"
+	"github.com/BearCloud/fa20-project-dev/backend/common/problem"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
+)
+
+const (
+	oski   = "5b1c9a36-5f0e-4c1b-9a55-8f7d3c1f4b2e"
+	stanny = "0d4b1b5e-2a3c-4f6e-8d9a-1c2b3d4e5f60"
+)
+
+//newProfilesServer starts every profile route against an in-memory store
+func newProfilesServer(t *testing.T) (*httptest.Server, *MemoryProfileStore) {
+	store := NewMemoryProfileStore()
+	router := mux.NewRouter()
+	err := RegisterRoutes(router, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//every response must match openapi.yaml
+	server := httptest.NewServer(openapi.Validate(doc, router, func(err error) { t.Error(err) }))
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func accessToken(t *testing.T, userID string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"UserID": userID,
+		"exp":    time.Now().Add(time.Minute).Unix(),
+	})
+	signed, err := token.SignedString(jwtKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+//do sends a request as userID, an empty userID sends no access_token
+func do(t *testing.T, method string, url string, userID string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != "" {
+		req.AddCookie(&http.Cookie{Name: "access_token", Value: accessToken(t, userID)})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestUpdateAndGetProfile(t *testing.T) {
+	server, _ := newProfilesServer(t)
+
+	resp := do(t, http.MethodPut, server.URL+"/api/profile/"+oski, oski,
+		`{"firstName":"Oski","lastName":"Bear","email":"oski@berkeley.edu","uuid":"`+stanny+`"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+
+	resp = do(t, http.MethodGet, server.URL+"/api/profile/"+oski, "", "")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 but was %d", resp.StatusCode)
+	}
+	profile := Profile{}
+	json.NewDecoder(resp.Body).Decode(&profile)
+	expected := Profile{Firstname: "Oski", Lastname: "Bear", Email: "oski@berkeley.edu", UUID: oski}
+	if profile != expected {
+		t.Fatalf("expected %+v but got %+v", expected, profile)
+	}
+
+	//the uuid in the body must not let a user overwrite someone else's profile
+	resp = do(t, http.MethodGet, server.URL+"/api/profile/"+stanny, "", "")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for a user without a profile but was %d", resp.StatusCode)
+	}
+}
+
+func TestUpdateProfileRequiresOwner(t *testing.T) {
+	server, store := newProfilesServer(t)
+	body := `{"firstName":"Oski","lastName":"Bear","email":"oski@berkeley.edu"}`
+
+	resp := do(t, http.MethodPut, server.URL+"/api/profile/"+oski, stanny, body)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 when updating someone else's profile but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPut, server.URL+"/api/profile/"+oski, "", body)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without an access token but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodPut, server.URL+"/api/profile/"+oski, oski, `not json`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid body but was %d", resp.StatusCode)
+	}
+	if _, err := store.GetProfile(context.Background(), oski); err != ErrProfileNotFound {
+		t.Fatalf("a rejected update was stored: %v", err)
+	}
+}
+
+func TestUpdateProfileValidatesTheBody(t *testing.T) {
+	server, store := newProfilesServer(t)
+
+	cases := []struct {
+		body  string
+		field string
+	}{
+		{`{"firstName":"Oski","email":"oski@"}`, "email"},
+		{`{"firstName":"0ski"}`, "firstName"},
+		{`{"lastName":"` + strings.Repeat("b", 256) + `"}`, "lastName"},
+		{`{"uuid":"oski"}`, "uuid"},
+		{`{"firstName":"Oski","nickname":"O"}`, "nickname"},
+	}
+	for _, c := range cases {
+		resp := do(t, http.MethodPut, server.URL+"/api/profile/"+oski, oski, c.body)
+		p := problem.Problem{}
+		json.NewDecoder(resp.Body).Decode(&p)
+		if resp.StatusCode != http.StatusBadRequest || len(p.Errors) != 1 || p.Errors[0].Field != c.field {
+			t.Errorf("update %s: expected %s to be invalid but got %d %+v", c.body, c.field, resp.StatusCode, p)
+		}
+	}
+	if _, err := store.GetProfile(context.Background(), oski); err != ErrProfileNotFound {
+		t.Fatalf("a rejected update was stored: %v", err)
+	}
+
+	resp := do(t, http.MethodPut, server.URL+"/api/profile/"+oski, oski, `{"firstName":"Oskï","lastName":"O'Bear-Smith"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for a valid profile but was %d", resp.StatusCode)
+	}
+	resp = do(t, http.MethodGet, server.URL+"/api/profile/not-a-uuid", "", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid uuid but was %d", resp.StatusCode)
+	}
+}
+
+//TestOpenAPI checks that openapi.yaml documents exactly the routes of RegisterRoutes
+func TestOpenAPI(t *testing.T) {
+	doc, err := openapi.Load(OpenAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	err = RegisterRoutes(router, NewMemoryProfileStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = openapi.CheckRoutes(doc, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
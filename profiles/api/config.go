@@ -0,0 +1,19 @@
+package api
+
+import (
+	"github.com/BearCloud/fa20-project-dev/backend/common/config"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+)
+
+//Config holds the profiles-service settings, see the config package for how they are loaded
+type Config struct {
+	config.Common     `yaml:",inline"`
+	database.Settings `yaml:",inline"`
+
+	DatabaseDSN string `env:"DATABASE_DSN" yaml:"database_dsn" default:"root:root@tcp(172.28.1.2:3306)/profiles" required:"true" secret:"dsn"`
+}
+
+//Configure applies cfg to the api package
+func Configure(cfg Config) {
+	jwtKey = []byte(cfg.JWTSecret)
+}
@@ -1,23 +1,55 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"log"
 
-	//MySQL driver
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/BearCloud/fa20-project-dev/backend/common/database"
+	"github.com/BearCloud/fa20-project-dev/backend/common/metrics"
 )
 
+//DB represents the connection to the MySQL database
 var DB *sql.DB
 
-func InitDB() *sql.DB {
+//InitDB creates the MySQL database connection, waiting for the server to come up
+func InitDB(ctx context.Context, dsn string, settings database.Settings) (*sql.DB, error) {
 	log.Println("attempting connections")
-	var err error
-	DB, err = sql.Open("mysql", "root:root@tcp(172.28.1.2:3306)/profiles")
 
+	var err error
+	DB, err = database.Open(ctx, "mysql", dsn, settings)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
+	}
+	database.Publish("profiles", DB)
+	metrics.RegisterDB("profiles", DB)
+	return DB, nil
+}
+
+//MySQLProfileStore keeps profiles in the users table
+type MySQLProfileStore struct {
+	db *sql.DB
+}
+
+//NewMySQLProfileStore creates a ProfileStore backed by db
+func NewMySQLProfileStore(db *sql.DB) *MySQLProfileStore {
+	return &MySQLProfileStore{db: db}
+}
+
+func (s *MySQLProfileStore) GetProfile(ctx context.Context, uuid string) (Profile, error) {
+	defer metrics.TimeQuery("profiles", "GetProfile")()
+	profile := Profile{}
+	err := s.db.QueryRowContext(ctx, "SELECT firstName, lastName, email, uuid FROM users WHERE uuid = ?", uuid).
+		Scan(&profile.Firstname, &profile.Lastname, &profile.Email, &profile.UUID)
+	if err == sql.ErrNoRows {
+		return Profile{}, ErrProfileNotFound
 	}
+	return profile, err
+}
 
-	return DB
+func (s *MySQLProfileStore) PutProfile(ctx context.Context, profile Profile) error {
+	defer metrics.TimeQuery("profiles", "PutProfile")()
+	_, err := s.db.ExecContext(ctx, "REPLACE INTO users (firstName, lastName, email, uuid) VALUES (?,?,?,?)",
+		profile.Firstname, profile.Lastname, profile.Email, profile.UUID)
+	return err
 }
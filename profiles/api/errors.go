@@ -0,0 +1,6 @@
+package api
+
+//The error codes of the profiles service, next to the shared ones of the problem package
+const (
+	CodeProfileNotFound = "profile_not_found"
+)
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"context"
+	"sync"
+)
+
+//MemoryProfileStore is an in-memory ProfileStore, used for tests and local development
+type MemoryProfileStore struct {
+	mu       sync.Mutex
+	profiles map[string]Profile
+}
+
+//NewMemoryProfileStore creates an empty MemoryProfileStore
+func NewMemoryProfileStore() *MemoryProfileStore {
+	return &MemoryProfileStore{profiles: make(map[string]Profile)}
+}
+
+func (s *MemoryProfileStore) GetProfile(ctx context.Context, uuid string) (Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	profile, ok := s.profiles[uuid]
+	if !ok {
+		return Profile{}, ErrProfileNotFound
+	}
+	return profile, nil
+}
+
+func (s *MemoryProfileStore) PutProfile(ctx context.Context, profile Profile) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profiles[profile.UUID] = profile
+	return nil
+}
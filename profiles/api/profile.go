@@ -1,8 +1,9 @@
 package api
 
+//Profile is the public profile of a user, the rules of validate apply to the updates
 type Profile struct {
-  Firstname string    `json:"firstName"`
-  Lastname  string    `json:"lastName"`
-  Email     string    `json:"email"`
-  UUID      string    `json:"uuid"`
+  Firstname string    `json:"firstName" validate:"omitempty,max=255,name"`
+  Lastname  string    `json:"lastName" validate:"omitempty,max=255,name"`
+  Email     string    `json:"email" validate:"omitempty,max=255,email"`
+  UUID      string    `json:"uuid" validate:"omitempty,uuid"`
 }
@@ -0,0 +1,20 @@
+package api
+
+import (
+	"context"
+	"errors"
+)
+
+//ErrProfileNotFound is returned by a ProfileStore when the requested profile does not exist
+var ErrProfileNotFound = errors.New("profile not found")
+
+//ProfileStore persists the profile of every user
+type ProfileStore interface {
+	//GetProfile returns the profile of uuid
+	GetProfile(ctx context.Context, uuid string) (Profile, error)
+	//PutProfile creates or replaces the profile with the same UUID
+	PutProfile(ctx context.Context, profile Profile) error
+}
+
+//profiles is the store used by the handlers, set by RegisterRoutes
+var profiles ProfileStore
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS users (
+    firstName VARCHAR(255),
+    lastName VARCHAR(255),
+    email VARCHAR(255),
+    uuid VARCHAR(36) PRIMARY KEY
+);
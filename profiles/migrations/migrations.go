@@ -0,0 +1,8 @@
+// Package migrations embeds the schema of the profiles database, applied by the migrate package at startup.
+package migrations
+
+import "embed"
+
+//FS holds the versioned up and down migrations
+//go:embed *.sql
+var FS embed.FS
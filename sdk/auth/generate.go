@@ -0,0 +1,4 @@
+//Package auth is the client of the auth API, generated from auth-service/api/openapi.yaml
+package auth
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.5.1 -config oapi-codegen.yaml ../../auth-service/api/openapi.yaml
@@ -0,0 +1,4 @@
+//Package friends is the client of the friends API, generated from friends/api/openapi.yaml
+package friends
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.5.1 -config oapi-codegen.yaml ../../friends/api/openapi.yaml
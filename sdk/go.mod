@@ -0,0 +1,10 @@
+module github.com/BearCloud/fa20-project-dev/backend/sdk
+
+go 1.26.0
+
+require github.com/oapi-codegen/runtime v1.1.2
+
+require (
+	github.com/apapsch/go-jsonmerge/v2 v2.0.0 // indirect
+	github.com/google/uuid v1.5.0 // indirect
+)
@@ -0,0 +1,4 @@
+//Package posts is the client of the posts API, generated from posts/api/openapi.yaml
+package posts
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.5.1 -config oapi-codegen.yaml ../../posts/api/openapi.yaml
@@ -0,0 +1,4 @@
+//Package profiles is the client of the profiles API, generated from profiles/api/openapi.yaml
+package profiles
+
+//go:generate go run github.com/oapi-codegen/oapi-codegen/v2/cmd/oapi-codegen@v2.5.1 -config oapi-codegen.yaml ../../profiles/api/openapi.yaml
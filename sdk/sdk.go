@@ -0,0 +1,90 @@
+// Package sdk is the Go client of the BearChat APIs, for tools and integration tests.
+//
+// The auth, posts, profiles and friends packages are generated from the
+// api/openapi.yaml of each service, run go generate ./... after changing a
+// specification. Client bundles them into one session: they share the cookies
+// set by the auth service and send the CSRF token on every request.
+//
+//	c, _ := sdk.New("http://localhost:8000")
+//	c.Auth.CsrfTokenWithResponse(ctx)
+//	c.Auth.SigninWithResponse(ctx, auth.Credentials{Username: &name, Password: &password})
+//	feed, _ := c.Posts.GetFeedWithResponse(ctx, &posts.GetFeedParams{})
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/auth"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/friends"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/posts"
+	"github.com/BearCloud/fa20-project-dev/backend/sdk/profiles"
+)
+
+//The CSRF cookie set by GET /api/auth/csrf and the header echoing it, see common/csrf
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+//Servers are the base URLs of the services, all the same behind the gateway or bearchat
+type Servers struct {
+	Auth     string
+	Posts    string
+	Profiles string
+	Friends  string
+}
+
+//Client is a session with the BearChat APIs
+type Client struct {
+	Auth     *auth.ClientWithResponses
+	Posts    *posts.ClientWithResponses
+	Profiles *profiles.ClientWithResponses
+	Friends  *friends.ClientWithResponses
+
+	//HTTP sends the requests of every client, its Jar holds the session cookies
+	HTTP *http.Client
+}
+
+//New creates a session with the APIs served on server, e.g. the gateway on http://localhost:8000
+func New(server string) (*Client, error) {
+	return NewWithServers(Servers{Auth: server, Posts: server, Profiles: server, Friends: server})
+}
+
+//NewWithServers creates a session with services served on different base URLs
+func NewWithServers(servers Servers) (*Client, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{HTTP: &http.Client{Jar: jar}}
+
+	//the CSRF token of the session is the cookie the jar would send with the request
+	withCSRF := func(ctx context.Context, req *http.Request) error {
+		for _, cookie := range jar.Cookies(req.URL) {
+			if cookie.Name == csrfCookie {
+				req.Header.Set(csrfHeader, cookie.Value)
+			}
+		}
+		return nil
+	}
+
+	c.Auth, err = auth.NewClientWithResponses(servers.Auth, auth.WithHTTPClient(c.HTTP), auth.WithRequestEditorFn(withCSRF))
+	if err != nil {
+		return nil, err
+	}
+	c.Posts, err = posts.NewClientWithResponses(servers.Posts, posts.WithHTTPClient(c.HTTP), posts.WithRequestEditorFn(withCSRF))
+	if err != nil {
+		return nil, err
+	}
+	c.Profiles, err = profiles.NewClientWithResponses(servers.Profiles, profiles.WithHTTPClient(c.HTTP), profiles.WithRequestEditorFn(withCSRF))
+	if err != nil {
+		return nil, err
+	}
+	c.Friends, err = friends.NewClientWithResponses(servers.Friends, friends.WithHTTPClient(c.HTTP), friends.WithRequestEditorFn(withCSRF))
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}